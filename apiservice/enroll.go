@@ -2,6 +2,7 @@ package apiservice
 
 import (
 	"Asyn_CBDC/backend/enroll"
+	"crypto/rand"
 	"fmt"
 
 	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
@@ -20,6 +21,6 @@ func init() {
 }
 
 func Enroll() {
-	acc := enroll.NewEnroll().Init(params, hashFunc).Acc
+	acc := enroll.NewEnroll().Init(params, hashFunc, rand.Reader).Acc
 	fmt.Println("Enroll success! Acc: ", acc)
 }
@@ -0,0 +1,199 @@
+// Package bench measures what the proofs cost: for each circuit its
+// constraint count, setup, proving and verification time and proof size, and
+// for the online path the sigma protocols and range proofs of the sender and
+// the receiver. The circuits are measured with either proof backend, and
+// their constraint counts are held to a checked-in Budget per backend.
+package bench
+
+import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/issuer"
+	"Asyn_CBDC/backend/keys"
+	"Asyn_CBDC/backend/offlinetx"
+	"Asyn_CBDC/backend/onlinetx"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+)
+
+// Circuit is the cost of one circuit. Mode is the regulation mode of the
+// offline circuits, empty for enrollment.
+type Circuit struct {
+	Name        string        `json:"name"`
+	Mode        string        `json:"mode,omitempty"`
+	Constraints int           `json:"constraints"`
+	Setup       time.Duration `json:"setup_ns"`
+	Prove       time.Duration `json:"prove_ns"`
+	Verify      time.Duration `json:"verify_ns"`
+	ProofSize   int           `json:"proof_size"`
+}
+
+// Proof is the cost of one proof of the online path.
+type Proof struct {
+	Name      string        `json:"name"`
+	Prove     time.Duration `json:"prove_ns"`
+	Verify    time.Duration `json:"verify_ns"`
+	ProofSize int           `json:"proof_size"`
+}
+
+// Report is the outcome of Run. Backend is the proof backend of the circuits;
+// the online proofs do not depend on it.
+type Report struct {
+	Backend  keys.Backend `json:"backend"`
+	Circuits []Circuit    `json:"circuits"`
+	Online   []Proof      `json:"online"`
+}
+
+// Counts returns the constraint count of each circuit of r, by name.
+func (r Report) Counts() map[string]int {
+	counts := make(map[string]int, len(r.Circuits))
+	for _, c := range r.Circuits {
+		counts[c.Name] = c.Constraints
+	}
+	return counts
+}
+
+// WriteJSON writes r to w, indented.
+func (r Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+type target struct {
+	name    string
+	mode    string
+	circuit frontend.Circuit
+	witness func() (frontend.Circuit, error)
+}
+
+// targets are the enrollment circuit and the offline circuit of every
+// regulation mode, each with a witness drawn afresh. The offline accounts are
+// signed by is; only the witnesses need it.
+func targets(is *issuer.Issuer) []target {
+	curveid := ecctedwards.BN254
+	params, _ := twistededwards.GetCurveParams(curveid)
+	hashFunc := hash.MIMC_BN254
+
+	ts := []target{{
+		name:    enroll.CircuitName,
+		circuit: enroll.NewCircuit(),
+		witness: func() (frontend.Circuit, error) {
+			var e enroll.Enroll
+			return e.Init(params, hashFunc, rand.Reader).Assignment(), nil
+		},
+	}}
+	for mode := offlinetx.NoRegulation; mode <= offlinetx.FreqLimit; mode++ {
+		name, _ := mode.Circuit()
+		ts = append(ts, target{
+			name:    name,
+			mode:    mode.String(),
+			circuit: offlinetx.NewCircuit(mode),
+			witness: func() (frontend.Circuit, error) {
+				o := offlinetx.Offline{Mode: mode, Issuer: is}
+				o, err := o.Execution(params, hashFunc, curveid, rand.Reader)
+				if err != nil {
+					return nil, err
+				}
+				return o.Assignment(mode), nil
+			},
+		})
+	}
+	return ts
+}
+
+// Constraints compiles every circuit for b and returns its constraint count,
+// by name. It is much cheaper than Run.
+func Constraints(b keys.Backend) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, t := range targets(nil) {
+		cs, err := b.Compile(t.circuit)
+		if err != nil {
+			return nil, fmt.Errorf("bench: compile %s: %w", t.name, err)
+		}
+		counts[t.name] = cs.GetNbConstraints()
+	}
+	return counts, nil
+}
+
+// Run sets up, proves and verifies every circuit with b and a fresh setup,
+// then measures the online path. The test accounts are signed by is.
+func Run(b keys.Backend, is *issuer.Issuer) (Report, error) {
+	r := Report{Backend: b}
+	for _, t := range targets(is) {
+		c, err := measure(b, t)
+		if err != nil {
+			return Report{}, err
+		}
+		r.Circuits = append(r.Circuits, c)
+	}
+	ms, err := onlinetx.Measure(is)
+	if err != nil {
+		return Report{}, err
+	}
+	for _, m := range ms {
+		r.Online = append(r.Online, Proof{Name: m.Name, Prove: m.Prove, Verify: m.Verify, ProofSize: m.Size})
+	}
+	return r, nil
+}
+
+// measure times a fresh setup of t with b. The setup of PLONK includes
+// drawing the SRS, which a keys.Store does once for all circuits.
+func measure(b keys.Backend, t target) (Circuit, error) {
+	c := Circuit{Name: t.name, Mode: t.mode}
+	cs, err := b.Compile(t.circuit)
+	if err != nil {
+		return c, fmt.Errorf("bench: compile %s: %w", t.name, err)
+	}
+	c.Constraints = cs.GetNbConstraints()
+
+	start := time.Now()
+	k, err := b.Setup(cs)
+	if err != nil {
+		return c, fmt.Errorf("bench: setup %s: %w", t.name, err)
+	}
+	c.Setup = time.Since(start)
+
+	assignment, err := t.witness()
+	if err != nil {
+		return c, fmt.Errorf("bench: witness of %s: %w", t.name, err)
+	}
+	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return c, err
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return c, err
+	}
+	start = time.Now()
+	proof, err := k.Prove(witness)
+	if err != nil {
+		return c, fmt.Errorf("bench: prove %s: %w", t.name, err)
+	}
+	c.Prove = time.Since(start)
+
+	start = time.Now()
+	if err := keys.Verify(proof, k.VK, publicWitness); err != nil {
+		return c, fmt.Errorf("bench: verify %s: %w", t.name, err)
+	}
+	c.Verify = time.Since(start)
+
+	n, err := proof.WriteTo(io.Discard)
+	if err != nil {
+		return c, err
+	}
+	c.ProofSize = int(n)
+	return c, nil
+}
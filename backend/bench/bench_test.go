@@ -0,0 +1,83 @@
+package bench
+
+import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/keys"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// TestBudget holds the circuits to budget.json. Raise a budget only together
+// with the change that needs it.
+func TestBudget(t *testing.T) {
+	b, err := LoadBudget("budget.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, backend := range []keys.Backend{keys.Groth16, keys.PLONK} {
+		counts, err := Constraints(backend)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(counts) != len(b[backend]) {
+			t.Errorf("%s: %d circuits, %d budgets", backend, len(counts), len(b[backend]))
+		}
+		if err := b.Check(backend, counts); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	b := Budget{keys.Groth16: {"a": 10, "b": 20}, keys.PLONK: {"a": 30}}
+	if err := b.Check(keys.Groth16, map[string]int{"a": 10, "b": 5}); err != nil {
+		t.Errorf("within budget: %v", err)
+	}
+	if err := b.Check(keys.Groth16, map[string]int{"a": 11}); !errors.Is(err, ErrOverBudget) {
+		t.Errorf("over budget: %v", err)
+	}
+	if err := b.Check(keys.Groth16, map[string]int{"c": 1}); !errors.Is(err, ErrOverBudget) {
+		t.Errorf("no budget: %v", err)
+	}
+	if err := b.Check(keys.PLONK, map[string]int{"a": 30}); err != nil {
+		t.Errorf("within the plonk budget: %v", err)
+	}
+	if err := b.Check(keys.PLONK, map[string]int{"b": 5}); !errors.Is(err, ErrOverBudget) {
+		t.Errorf("no plonk budget: %v", err)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	var ts []target
+	for _, tg := range targets(nil) {
+		if tg.name == enroll.CircuitName {
+			ts = append(ts, tg)
+		}
+	}
+	if len(ts) != 1 {
+		t.Fatalf("%d enrollment targets", len(ts))
+	}
+	for _, backend := range []keys.Backend{keys.Groth16, keys.PLONK} {
+		c, err := measure(backend, ts[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Constraints == 0 || c.Setup <= 0 || c.Prove <= 0 || c.Verify <= 0 || c.ProofSize == 0 {
+			t.Fatalf("%s: %+v", backend, c)
+		}
+
+		var buf bytes.Buffer
+		if err := (Report{Backend: backend, Circuits: []Circuit{c}}).WriteJSON(&buf); err != nil {
+			t.Fatal(err)
+		}
+		var r Report
+		if err := json.Unmarshal(buf.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Backend != backend || len(r.Circuits) != 1 || r.Circuits[0] != c || r.Counts()[enroll.CircuitName] != c.Constraints {
+			t.Errorf("round trip %+v", r)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package bench
+
+import (
+	"Asyn_CBDC/backend/keys"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// BudgetFile is the budget checked in next to this package.
+const BudgetFile = "backend/bench/budget.json"
+
+var ErrOverBudget = errors.New("bench: constraint count over budget")
+
+// Budget is the largest constraint count allowed for each circuit, by backend
+// then by name: R1CS and SparseR1CS counts differ. A circuit that grows
+// fails the check until its budget is raised in the same change.
+type Budget map[keys.Backend]map[string]int
+
+// LoadBudget reads a budget written as a JSON object of counts per backend.
+func LoadBudget(path string) (Budget, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Budget
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("bench: bad budget %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Check returns ErrOverBudget naming every circuit of counts, compiled for
+// backend, that exceeds b or has no budget at all.
+func (b Budget) Check(backend keys.Backend, counts map[string]int) error {
+	var over []string
+	for name, n := range counts {
+		max, ok := b[backend][name]
+		switch {
+		case !ok:
+			over = append(over, fmt.Sprintf("%s has %d constraints and no budget", name, n))
+		case n > max:
+			over = append(over, fmt.Sprintf("%s has %d constraints, budget %d", name, n, max))
+		}
+	}
+	if len(over) == 0 {
+		return nil
+	}
+	sort.Strings(over)
+	return fmt.Errorf("%w for %s: %s", ErrOverBudget, backend, strings.Join(over, "; "))
+}
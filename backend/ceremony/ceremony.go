@@ -0,0 +1,234 @@
+// Package ceremony replaces the single-party groth16.Setup of the circuits by
+// a multi-party computation, on top of gnark's mpcsetup. Phase 1 is a powers
+// of tau shared by every circuit; phase 2 is run per circuit. Each participant
+// reads the last contribution, adds its own and publishes the result; the
+// setup is sound as long as one of them destroyed its randomness. Anyone can
+// verify the transcripts, and Extract turns them into keys for a keys.Store.
+package ceremony
+
+import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/keys"
+	"Asyn_CBDC/backend/offlinetx"
+	"errors"
+	"fmt"
+	"math/bits"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/fft"
+	"github.com/consensys/gnark/backend/groth16/bn254/mpcsetup"
+	"github.com/consensys/gnark/constraint"
+	cs "github.com/consensys/gnark/constraint/bn254"
+	"github.com/consensys/gnark/frontend"
+)
+
+// MaxPower is the power of the phase 1 that serves every circuit: the
+// offline circuits of HoldingLimit and FreqLimit have more than 2^16
+// constraints.
+const MaxPower = 17
+
+var (
+	ErrNoContribution = errors.New("ceremony: transcript has no contribution")
+	ErrBadTranscript  = errors.New("ceremony: transcript does not start from the initial state")
+	ErrTooSmall       = errors.New("ceremony: phase 1 too small for the circuit")
+	ErrCommitments    = errors.New("ceremony: circuit uses commitments, which mpcsetup does not support")
+)
+
+// Circuits are the names of the circuits set up by the ceremony: enrollment
+// and the offline circuit of every regulation mode.
+func Circuits() []string {
+	names := []string{enroll.CircuitName}
+	for mode := offlinetx.NoRegulation; mode <= offlinetx.FreqLimit; mode++ {
+		name, _ := mode.Circuit()
+		names = append(names, name)
+	}
+	return names
+}
+
+func newCircuit(name string) (frontend.Circuit, error) {
+	if name == enroll.CircuitName {
+		return enroll.NewCircuit(), nil
+	}
+	for mode := offlinetx.NoRegulation; mode <= offlinetx.FreqLimit; mode++ {
+		if n, _ := mode.Circuit(); n == name {
+			return offlinetx.NewCircuit(mode), nil
+		}
+	}
+	return nil, fmt.Errorf("ceremony: unknown circuit %q", name)
+}
+
+// Compile returns the R1CS of the circuit name.
+func Compile(name string) (*cs.R1CS, error) {
+	circuit, err := newCircuit(name)
+	if err != nil {
+		return nil, err
+	}
+	ccs, err := keys.Groth16.Compile(circuit)
+	if err != nil {
+		return nil, fmt.Errorf("ceremony: compile %s: %w", name, err)
+	}
+	r1cs := ccs.(*cs.R1CS)
+	if c, ok := r1cs.CommitmentInfo.(constraint.Groth16Commitments); ok && len(c) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrCommitments, name)
+	}
+	return r1cs, nil
+}
+
+// Power is the log2 of the domain of r1cs, the power of tau its phase 2
+// needs.
+func Power(r1cs *cs.R1CS) int {
+	n := fft.NewDomain(uint64(r1cs.GetNbConstraints())).Cardinality
+	return bits.Len64(n) - 1
+}
+
+// InitPhase1 returns the initial state of a phase 1 of 2^power, the largest
+// domain of the circuits it will serve.
+func InitPhase1(power int) *mpcsetup.Phase1 {
+	p := mpcsetup.InitPhase1(power)
+	return &p
+}
+
+func power1(p *mpcsetup.Phase1) int {
+	return bits.Len(uint(len(p.Parameters.G1.AlphaTau))) - 1
+}
+
+// VerifyPhase1 checks a phase 1 transcript: chain[0] is the initial state and
+// every later entry a valid contribution on top of the one before.
+func VerifyPhase1(chain []*mpcsetup.Phase1) error {
+	if len(chain) < 2 {
+		return ErrNoContribution
+	}
+	n := len(chain[0].Parameters.G1.AlphaTau)
+	if n < 2 || n&(n-1) != 0 {
+		return fmt.Errorf("%w: phase 1 of %d powers", ErrBadTranscript, n)
+	}
+	for i, p := range chain {
+		if len(p.Parameters.G1.Tau) != 2*n-1 || len(p.Parameters.G1.AlphaTau) != n ||
+			len(p.Parameters.G1.BetaTau) != n || len(p.Parameters.G2.Tau) != n {
+			return fmt.Errorf("ceremony: phase 1 contribution %d is not of 2^%d powers", i, power1(chain[0]))
+		}
+	}
+	if !initial1(chain[0]) {
+		return fmt.Errorf("%w: phase 1", ErrBadTranscript)
+	}
+	if err := mpcsetup.VerifyPhase1(chain[0], chain[1], chain[2:]...); err != nil {
+		return fmt.Errorf("ceremony: phase 1: %w", err)
+	}
+	return nil
+}
+
+// initial1 reports whether p is the initial state of phase 1: every power is
+// a generator, τ=α=β=1. Its public keys are random, so is its hash.
+func initial1(p *mpcsetup.Phase1) bool {
+	_, _, g1, g2 := curve.Generators()
+	for _, v := range [][]curve.G1Affine{p.Parameters.G1.Tau, p.Parameters.G1.AlphaTau, p.Parameters.G1.BetaTau} {
+		for i := range v {
+			if v[i] != g1 {
+				return false
+			}
+		}
+	}
+	for i := range p.Parameters.G2.Tau {
+		if p.Parameters.G2.Tau[i] != g2 {
+			return false
+		}
+	}
+	return p.Parameters.G2.Beta == g2
+}
+
+// equal2 reports whether a and b hold the same parameters.
+func equal2(a, b *mpcsetup.Phase2) bool {
+	if a.Parameters.G1.Delta != b.Parameters.G1.Delta || a.Parameters.G2.Delta != b.Parameters.G2.Delta ||
+		len(a.Parameters.G1.L) != len(b.Parameters.G1.L) || len(a.Parameters.G1.Z) != len(b.Parameters.G1.Z) {
+		return false
+	}
+	for i := range a.Parameters.G1.L {
+		if a.Parameters.G1.L[i] != b.Parameters.G1.L[i] {
+			return false
+		}
+	}
+	for i := range a.Parameters.G1.Z {
+		if a.Parameters.G1.Z[i] != b.Parameters.G1.Z[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// truncate cuts srs1 down to the domain of r1cs. The powers of a smaller
+// domain are a prefix of those of a larger one.
+func truncate(srs1 *mpcsetup.Phase1, r1cs *cs.R1CS) (*mpcsetup.Phase1, error) {
+	n := 1 << Power(r1cs)
+	if len(srs1.Parameters.G1.AlphaTau) < n {
+		return nil, fmt.Errorf("%w: 2^%d, need 2^%d", ErrTooSmall, power1(srs1), Power(r1cs))
+	}
+	t := new(mpcsetup.Phase1)
+	t.Parameters.G1.Tau = srs1.Parameters.G1.Tau[:2*n-1]
+	t.Parameters.G1.AlphaTau = srs1.Parameters.G1.AlphaTau[:n]
+	t.Parameters.G1.BetaTau = srs1.Parameters.G1.BetaTau[:n]
+	t.Parameters.G2.Tau = srs1.Parameters.G2.Tau[:n]
+	t.Parameters.G2.Beta = srs1.Parameters.G2.Beta
+	t.PublicKeys = srs1.PublicKeys
+	t.Hash = srs1.Hash
+	return t, nil
+}
+
+// InitPhase2 returns the initial state of the phase 2 of r1cs on top of the
+// final phase 1 srs1, and the evaluations Extract needs. Its parameters are
+// deterministic: verifiers recompute them.
+func InitPhase2(r1cs *cs.R1CS, srs1 *mpcsetup.Phase1) (*mpcsetup.Phase2, *mpcsetup.Phase2Evaluations, error) {
+	srs1, err := truncate(srs1, r1cs)
+	if err != nil {
+		return nil, nil, err
+	}
+	p, evals := mpcsetup.InitPhase2(r1cs, srs1)
+	return &p, &evals, nil
+}
+
+// VerifyPhase2 checks the phase 2 transcript of r1cs on top of the final
+// phase 1 srs1.
+func VerifyPhase2(r1cs *cs.R1CS, srs1 *mpcsetup.Phase1, chain []*mpcsetup.Phase2) error {
+	_, err := verifyPhase2(r1cs, srs1, chain)
+	return err
+}
+
+func verifyPhase2(r1cs *cs.R1CS, srs1 *mpcsetup.Phase1, chain []*mpcsetup.Phase2) (*mpcsetup.Phase2Evaluations, error) {
+	if len(chain) < 2 {
+		return nil, ErrNoContribution
+	}
+	init, evals, err := InitPhase2(r1cs, srs1)
+	if err != nil {
+		return nil, err
+	}
+	if !equal2(init, chain[0]) {
+		return nil, fmt.Errorf("%w: phase 2", ErrBadTranscript)
+	}
+	for i, p := range chain {
+		if len(p.Parameters.G1.L) != len(init.Parameters.G1.L) || len(p.Parameters.G1.Z) != len(init.Parameters.G1.Z) {
+			return nil, fmt.Errorf("ceremony: phase 2 contribution %d is not for this circuit", i)
+		}
+	}
+	if err := mpcsetup.VerifyPhase2(chain[0], chain[1], chain[2:]...); err != nil {
+		return nil, fmt.Errorf("ceremony: phase 2: %w", err)
+	}
+	return evals, nil
+}
+
+// Extract verifies both transcripts of r1cs and returns the keys they
+// produce.
+func Extract(r1cs *cs.R1CS, phase1 []*mpcsetup.Phase1, phase2 []*mpcsetup.Phase2) (keys.Keys, error) {
+	if err := VerifyPhase1(phase1); err != nil {
+		return keys.Keys{}, err
+	}
+	srs1 := phase1[len(phase1)-1]
+	evals, err := verifyPhase2(r1cs, srs1, phase2)
+	if err != nil {
+		return keys.Keys{}, err
+	}
+	srs1, err = truncate(srs1, r1cs)
+	if err != nil {
+		return keys.Keys{}, err
+	}
+	pk, vk := mpcsetup.ExtractKeys(srs1, phase2[len(phase2)-1], evals, r1cs.GetNbConstraints())
+	return keys.Keys{Backend: keys.Groth16, CS: r1cs, PK: &pk, VK: &vk}, nil
+}
@@ -0,0 +1,183 @@
+package ceremony
+
+import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/keys"
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend/groth16/bn254/mpcsetup"
+	cs "github.com/consensys/gnark/constraint/bn254"
+	"github.com/consensys/gnark/frontend"
+)
+
+// contribute1 runs n contributions on top of p0 the way participants do,
+// through files, and returns the paths of the whole transcript.
+func contribute1(t *testing.T, dir string, p0 *mpcsetup.Phase1, n int) []string {
+	t.Helper()
+	paths := []string{filepath.Join(dir, "phase1.0")}
+	if err := WritePhase1(paths[0], p0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		prev, err := ReadPhase1(paths[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev[0].Contribute()
+		paths = append(paths, filepath.Join(dir, fmt.Sprintf("phase1.%d", i)))
+		if err := WritePhase1(paths[i], prev[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+func contribute2(t *testing.T, dir string, p0 *mpcsetup.Phase2, n int) []string {
+	t.Helper()
+	paths := []string{filepath.Join(dir, "phase2.0")}
+	if err := WritePhase2(paths[0], p0); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= n; i++ {
+		prev, err := ReadPhase2(paths[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		prev[0].Contribute()
+		paths = append(paths, filepath.Join(dir, fmt.Sprintf("phase2.%d", i)))
+		if err := WritePhase2(paths[i], prev[0]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
+
+type cubeCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *cubeCircuit) Define(api frontend.API) error {
+	x3 := api.Mul(c.X, c.X, c.X)
+	api.AssertIsEqual(api.Add(x3, c.X, 5), c.Y)
+	return nil
+}
+
+// TestCeremony sets up a small circuit with two contributions to each phase,
+// on a phase 1 one power larger than it needs, and proves with the extracted
+// keys. The CBDC circuits take minutes per phase 2, see TestCircuits.
+func TestCeremony(t *testing.T) {
+	dir := t.TempDir()
+	ccs, err := keys.Groth16.Compile(&cubeCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r1cs := ccs.(*cs.R1CS)
+	power := Power(r1cs)
+
+	phase1, err := ReadPhase1(contribute1(t, dir, InitPhase1(power+1), 2)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPhase1(phase1); err != nil {
+		t.Fatal(err)
+	}
+	srs1 := phase1[len(phase1)-1]
+
+	p0, _, err := InitPhase2(r1cs, srs1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	phase2, err := ReadPhase2(contribute2(t, dir, p0, 2)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyPhase2(r1cs, srs1, phase2); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := Extract(r1cs, phase1, phase2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	witness, err := frontend.NewWitness(&cubeCircuit{X: 3, Y: 35}, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := k.Prove(witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Verify(proof, k.VK, publicWitness); err != nil {
+		t.Fatal(err)
+	}
+
+	// a store holding the ceremony keys hands them out instead of running a setup
+	store := keys.NewStore(filepath.Join(dir, "keys"))
+	if err := store.Write("cube", k); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.Setup("cube", &cubeCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vk1, vk2 bytes.Buffer
+	k.VK.WriteTo(&vk1)
+	stored.VK.WriteTo(&vk2)
+	if !bytes.Equal(vk1.Bytes(), vk2.Bytes()) {
+		t.Error("store replaced the ceremony keys")
+	}
+
+	// transcripts that must be rejected
+	if err := VerifyPhase1(phase1[:1]); !errors.Is(err, ErrNoContribution) {
+		t.Errorf("phase 1 without contribution: %v", err)
+	}
+	if err := VerifyPhase1(phase1[1:]); !errors.Is(err, ErrBadTranscript) {
+		t.Errorf("phase 1 without its initial state: %v", err)
+	}
+	if err := VerifyPhase1([]*mpcsetup.Phase1{phase1[0], phase1[2]}); err == nil {
+		t.Error("phase 1 skipping a contribution accepted")
+	}
+	if err := VerifyPhase2(r1cs, phase1[1], phase2); !errors.Is(err, ErrBadTranscript) {
+		t.Errorf("phase 2 on another phase 1: %v", err)
+	}
+	if err := VerifyPhase2(r1cs, srs1, []*mpcsetup.Phase2{phase2[0], phase2[2]}); err == nil {
+		t.Error("phase 2 skipping a contribution accepted")
+	}
+	if _, err := Extract(r1cs, phase1, phase2[:1]); !errors.Is(err, ErrNoContribution) {
+		t.Errorf("extract without phase 2 contribution: %v", err)
+	}
+	if _, _, err := InitPhase2(r1cs, InitPhase1(power-1)); !errors.Is(err, ErrTooSmall) {
+		t.Errorf("phase 1 too small: %v", err)
+	}
+}
+
+// TestCircuits checks that every CBDC circuit compiles without commitments
+// and fits the largest phase 1.
+func TestCircuits(t *testing.T) {
+	names := Circuits()
+	if len(names) != 5 || names[0] != enroll.CircuitName {
+		t.Fatalf("circuits %v", names)
+	}
+	for _, name := range names {
+		r1cs, err := Compile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p := Power(r1cs); p > MaxPower {
+			t.Errorf("%s needs 2^%d powers, more than 2^%d", name, p, MaxPower)
+		}
+	}
+	if _, err := Compile("nope"); err == nil {
+		t.Error("unknown circuit compiled")
+	}
+}
@@ -0,0 +1,75 @@
+package ceremony
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/consensys/gnark/backend/groth16/bn254/mpcsetup"
+)
+
+// WritePhase1 writes a phase 1 state to path.
+func WritePhase1(path string, p *mpcsetup.Phase1) error {
+	return writeFile(path, p)
+}
+
+// ReadPhase1 reads the phase 1 states at paths, in order.
+func ReadPhase1(paths ...string) ([]*mpcsetup.Phase1, error) {
+	chain := make([]*mpcsetup.Phase1, len(paths))
+	for i, path := range paths {
+		chain[i] = new(mpcsetup.Phase1)
+		if err := readFile(path, chain[i]); err != nil {
+			return nil, err
+		}
+	}
+	return chain, nil
+}
+
+// WritePhase2 writes a phase 2 state to path.
+func WritePhase2(path string, p *mpcsetup.Phase2) error {
+	return writeFile(path, p)
+}
+
+// ReadPhase2 reads the phase 2 states at paths, in order.
+func ReadPhase2(paths ...string) ([]*mpcsetup.Phase2, error) {
+	chain := make([]*mpcsetup.Phase2, len(paths))
+	for i, path := range paths {
+		chain[i] = new(mpcsetup.Phase2)
+		if err := readFile(path, chain[i]); err != nil {
+			return nil, err
+		}
+	}
+	return chain, nil
+}
+
+// writeFile writes w to a temporary file renamed into place, so that a
+// participant never publishes half a contribution.
+func writeFile(path string, w io.WriterTo) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := w.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func readFile(path string, r io.ReaderFrom) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := r.ReadFrom(f); err != nil {
+		return fmt.Errorf("ceremony: read %s: %w", path, err)
+	}
+	return nil
+}
@@ -1,12 +1,12 @@
 package enroll
 
 import (
+	"Asyn_CBDC/backend/generator"
 	"Asyn_CBDC/backend/util"
 
 	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/std/algebra/native/twistededwards"
-	"github.com/consensys/gnark/std/hash/mimc"
 )
 
 type enrollCircuit struct {
@@ -24,31 +24,28 @@ func (circuit *enrollCircuit) Define(api frontend.API) error {
 	curvepara := ecctedwards.BN254
 
 	curve, err := twistededwards.NewEdCurve(api, curvepara)
-	mimc, err := mimc.NewMiMC(api)
 	if err != nil {
 		return err
 	}
 
 	//TK=g2*tk
-	_g2, _ := twistededwards.GetCurveParams(curvepara)
-	tacpk := util.CalculateTK(curve, _g2, circuit.TacSk)
-	api.AssertIsEqual(tacpk.X, circuit.ExpectedTacPk.X)
+	g2 := generator.InCircuit(generator.G2)
+	tacpk := util.CalculateTK(curve, g2, circuit.TacSk)
+	util.AssertPointEqual(api, tacpk, circuit.ExpectedTacPk)
 
-	//delta0=mimc(tk,seq)
-	mimc.Write(circuit.TacSk, circuit.Seq)
-	delta_0 := mimc.Sum()
-	_g1, _ := twistededwards.GetCurveParams(curvepara)
-	g1 := twistededwards.Point{X: _g1.Base[0], Y: _g1.Base[1]}
+	//delta0=mimc(tk,seq), every account starts at FirstSeq
+	api.AssertIsEqual(circuit.Seq, FirstSeq)
+	delta_0, err := util.CalculateDelta(api, circuit.TacSk, circuit.Seq)
+	if err != nil {
+		return err
+	}
+	g1 := generator.InCircuit(generator.G1)
 
 	plaintext := curve.ScalarMul(g1, delta_0)
 
-	_h, _ := twistededwards.GetCurveParams(curvepara)
-	acc0 := util.EncryptAcc(curve, plaintext, circuit.PublicKey, circuit.Randomness, _h)
-	c1 := acc0.A
-	c2 := acc0.B
-
-	api.AssertIsEqual(c1.X, circuit.ExpectedAcc.A.X)
-	api.AssertIsEqual(c2.X, circuit.ExpectedAcc.B.X)
+	h := generator.InCircuit(generator.H)
+	acc0 := util.EncryptAcc(curve, plaintext, circuit.PublicKey, circuit.Randomness, h)
+	util.AssertAccountEqual(api, acc0, circuit.ExpectedAcc)
 
 	return nil
 }
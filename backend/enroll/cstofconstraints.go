@@ -1,26 +1,69 @@
 package enroll
 
 import (
+	"Asyn_CBDC/backend/keys"
 	"Asyn_CBDC/backend/util"
+	"crypto/rand"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
 	"github.com/consensys/gnark-crypto/hash"
-	"github.com/consensys/gnark/backend/groth16"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/consensys/gnark/std/algebra/native/twistededwards"
 )
 
-func T_Enroll() {
+// CircuitName identifies the enrollment circuit in a keys.Store.
+const CircuitName = "enroll"
+
+// NewCircuit returns the enrollment circuit, to compile or set up.
+func NewCircuit() frontend.Circuit {
+	return &enrollCircuit{}
+}
+
+// Assignment is the witness proving the enrollment of enroll.
+func (enroll Enroll) Assignment() frontend.Circuit {
+	assignment := enrollAssignment(enroll)
+	return &assignment
+}
+
+// SetupKeys loads the enrollment keys from store, running the setup on first use.
+func SetupKeys(store keys.Store) (keys.Keys, error) {
+	return store.Setup(CircuitName, NewCircuit())
+}
+
+func T_Enroll(store keys.Store) error {
 	curveid := ecctedwards.BN254
 
 	params, _ := twistededwards.GetCurveParams(curveid)
 	hashFunc := hash.MIMC_BN254
 
 	var enroll Enroll
-	enroll = enroll.Init(params, hashFunc)
+	enroll = enroll.Init(params, hashFunc, rand.Reader)
+
+	assignment := enrollAssignment(enroll)
+
+	k, err := SetupKeys(store)
+	if err != nil {
+		return err
+	}
+
+	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return err
+	}
+	publicWitness, err := witness.Public()
+	if err != nil {
+		return err
+	}
+	proof, err := k.Prove(witness)
+	if err != nil {
+		return err
+	}
+
+	return keys.Verify(proof, k.VK, publicWitness)
+}
 
+func enrollAssignment(enroll Enroll) enrollCircuit {
 	var assignment enrollCircuit
 	assignment.TacSk = enroll.Tracesk.Sk
 	_TK := enroll.Tracepk
@@ -37,20 +80,5 @@ func T_Enroll() {
 
 	assignment.ExpectedAcc = acc
 
-	var circuit enrollCircuit
-
-	r1cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-
-	pk, vk, err := groth16.Setup(r1cs)
-
-	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	publicWitness, err := witness.Public()
-	proof, err := groth16.Prove(r1cs, pk, witness)
-
-	err = groth16.Verify(proof, vk, publicWitness)
-	if err != nil {
-		// invalid proof
-	}
-	//2780
-	//*
+	return assignment
 }
@@ -1,16 +1,20 @@
 package enroll
 
 import (
+	"Asyn_CBDC/backend/generator"
 	"Asyn_CBDC/backend/util"
+	"io"
 	"math/big"
-	mathrand "math/rand"
-	"time"
 
 	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
 	"github.com/consensys/gnark-crypto/hash"
 	"github.com/consensys/gnark/std/algebra/native/twistededwards"
 )
 
+// FirstSeq is the sequence number of a freshly enrolled account. Each offline
+// spend moves the account to the next one.
+const FirstSeq = 0
+
 type Enroll struct {
 	G0      curve.PointAffine
 	G2      curve.PointAffine
@@ -31,25 +35,21 @@ func NewEnroll() Enroll {
 	return Enroll{}
 }
 
-func (enroll Enroll) Init(params *twistededwards.CurveParams, hash hash.Hash) Enroll {
-	enroll.G0.X.SetBigInt(params.Base[0])
-	enroll.G0.Y.SetBigInt(params.Base[1])
+// Init creates a fresh wallet. Secret keys and randomness are drawn from rnd,
+// crypto/rand when rnd is nil.
+func (enroll Enroll) Init(params *twistededwards.CurveParams, hash hash.Hash, rnd io.Reader) Enroll {
+	enroll.G0 = generator.G0
 
-	mathrand.Seed(time.Now().UnixNano())
-	randint := mathrand.Intn(1100) + 10
-	tk := new(big.Int).Sub(params.Order, big.NewInt(int64(randint)))
-	enroll.G2.X.SetBigInt(params.Base[0])
-	enroll.G2.Y.SetBigInt(params.Base[1])
+	tk := util.RandomScalar(rnd, params.Order)
+	enroll.G2 = generator.G2
 	_TK := util.Calculate_TK(&enroll.G2, tk)
 
 	enroll.Tracesk = util.Privatekey{Sk: tk}
 	enroll.Tracepk = util.Publickey{Pk: *_TK}
 
 	modulus := params.Order
-	seq := new(big.Int).Sub(modulus, big.NewInt(1))
-	_data := tk.Bytes()
-	data := append(_data, seq.Bytes()...)
-	delta := util.Calculate_delta(data, hash)
+	seq := big.NewInt(FirstSeq)
+	delta := util.Calculate_delta(tk, seq, hash)
 	enroll.Delta = delta
 
 	enroll.Seq = seq
@@ -57,21 +57,15 @@ func (enroll Enroll) Init(params *twistededwards.CurveParams, hash hash.Hash) En
 	balance := new(big.Int).Sub(modulus, big.NewInt(0))
 	enroll.Bal = balance
 
-	enroll.G1.X.SetBigInt(params.Base[0])
-	enroll.G1.Y.SetBigInt(params.Base[1])
+	enroll.G1 = generator.G1
 
-	mathrand.Seed(time.Now().UnixNano())
-	randint = mathrand.Intn(1100) + 10
-	_sk := new(big.Int).Sub(modulus, big.NewInt(int64(randint)))
+	_sk := util.RandomScalar(rnd, modulus)
 	enroll.Sk = util.Privatekey{Sk: _sk}
-	enroll.H.X.SetBigInt(params.Base[0])
-	enroll.H.Y.SetBigInt(params.Base[1])
+	enroll.H = generator.H
 
 	_pk := new(curve.PointAffine).ScalarMultiplication(&enroll.H, _sk)
 	enroll.Pk = util.Publickey{Pk: *_pk}
-	mathrand.Seed(time.Now().UnixNano())
-	randint = mathrand.Intn(1100) + 10
-	r := new(big.Int).Sub(modulus, big.NewInt(int64(randint)))
+	r := util.RandomScalar(rnd, modulus)
 	enroll.R = r
 
 	plain := new(curve.PointAffine).ScalarMultiplication(&enroll.G1, delta)
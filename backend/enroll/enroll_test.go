@@ -1,7 +1,108 @@
 package enroll
 
-import "testing"
+import (
+	"Asyn_CBDC/backend/keys"
+	"Asyn_CBDC/backend/util"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	"github.com/consensys/gnark/test"
+)
 
 func TestEnroll(t *testing.T) {
-	T_Enroll()
+	for _, b := range []keys.Backend{keys.Groth16, keys.PLONK} {
+		t.Run(string(b), func(t *testing.T) {
+			if err := T_Enroll(keys.NewStore(t.TempDir()).WithBackend(b)); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestEnrollSeeded(t *testing.T) {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+
+	e1 := NewEnroll().Init(params, hash.MIMC_BN254, util.NewSeededReader([]byte("wallet")))
+	e2 := NewEnroll().Init(params, hash.MIMC_BN254, util.NewSeededReader([]byte("wallet")))
+	if e1.Sk.Sk.Cmp(e2.Sk.Sk) != 0 || !e1.Acc[0].Equal(&e2.Acc[0]) {
+		t.Fatal("seeded enrollments differ")
+	}
+
+	e3 := NewEnroll().Init(params, hash.MIMC_BN254, nil)
+	if e1.Sk.Sk.Cmp(e3.Sk.Sk) == 0 || e1.Tracesk.Sk.Cmp(e3.Tracesk.Sk) == 0 {
+		t.Fatal("crypto/rand enrollment reused the seeded keys")
+	}
+}
+
+// twin is (x,-y), the point an X-only comparison cannot tell from (x,y)
+func twin(p twistededwards.Point) twistededwards.Point {
+	y := p.Y.(fr.Element)
+	y.Neg(&y)
+	return twistededwards.Point{X: p.X, Y: y}
+}
+
+func TestEnrollRejectsNegatedPoints(t *testing.T) {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	e := NewEnroll().Init(params, hash.MIMC_BN254, util.NewSeededReader([]byte("negated")))
+	field := ecc.BN254.ScalarField()
+
+	a := enrollAssignment(e)
+	if err := test.IsSolved(&enrollCircuit{}, &a, field); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := []func(*enrollCircuit){
+		func(c *enrollCircuit) { c.ExpectedAcc.A = twin(c.ExpectedAcc.A) },
+		func(c *enrollCircuit) { c.ExpectedAcc.B = twin(c.ExpectedAcc.B) },
+		func(c *enrollCircuit) { c.ExpectedTacPk = twin(c.ExpectedTacPk) },
+	}
+	for i, tamper := range tampered {
+		a := enrollAssignment(e)
+		tamper(&a)
+		if err := test.IsSolved(&enrollCircuit{}, &a, field); err == nil {
+			t.Errorf("tampered witness %d accepted", i)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	alice := NewEnroll().Init(params, hash.MIMC_BN254, util.NewSeededReader([]byte("alice")))
+	bob := NewEnroll().Init(params, hash.MIMC_BN254, util.NewSeededReader([]byte("bob")))
+	reg := NewRegistry()
+
+	if _, err := reg.Register("alice", alice.Pk, nil, 1); err != nil {
+		t.Fatal(err)
+	}
+	a := big.NewInt(42)
+	e, err := reg.Register("bob", bob.Pk, a, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Register("alice2", alice.Pk, nil, 3); !errors.Is(err, ErrAlreadyEnrolled) {
+		t.Errorf("key enrolled twice: %v", err)
+	}
+	carol := NewEnroll().Init(params, hash.MIMC_BN254, util.NewSeededReader([]byte("carol")))
+	if _, err := reg.Register("carol", carol.Pk, a, 3); err == nil {
+		t.Error("holding key assigned twice")
+	}
+
+	if got, ok := reg.Lookup(alice.Pk.Pk); !ok || got.ID != "alice" || got.Aux != nil {
+		t.Errorf("lookup alice: %+v, %v", got, ok)
+	}
+	if _, ok := reg.Lookup(carol.Pk.Pk); ok {
+		t.Error("carol found after a failed registration")
+	}
+	if got, ok := reg.LookupHolding(*e.Aux, *e.HeldPk); !ok || got.ID != "bob" {
+		t.Errorf("lookup bob: %+v, %v", got, ok)
+	}
+	if _, ok := reg.LookupHolding(*e.Aux, bob.Pk.Pk); ok {
+		t.Error("holding lookup matched the bare key")
+	}
 }
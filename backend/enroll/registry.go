@@ -0,0 +1,87 @@
+package enroll
+
+import (
+	"Asyn_CBDC/backend/generator"
+	"Asyn_CBDC/backend/util"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+)
+
+var ErrAlreadyEnrolled = errors.New("enroll: public key already enrolled")
+
+// Entry is an enrolled wallet. A wallet with a holding limit also has a
+// holding key a, assigned at enrollment: the bank signs Aux=h*a with each of
+// its accounts, so its transactions publish that Aux and re-randomize the key
+// they disclose to a*pk, and the registry keeps both.
+type Entry struct {
+	ID       string
+	Pk       curve.PointAffine
+	Aux      *curve.PointAffine // h*a, nil without a holding key
+	HeldPk   *curve.PointAffine // a*pk, nil without a holding key
+	Enrolled uint64             // Unix time
+}
+
+// Registry maps the public keys of enrolled wallets to their owners.
+type Registry struct {
+	mu    sync.RWMutex
+	byPk  map[curve.PointAffine]*Entry
+	byAux map[curve.PointAffine]*Entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		byPk:  make(map[curve.PointAffine]*Entry),
+		byAux: make(map[curve.PointAffine]*Entry),
+	}
+}
+
+// Register enrolls the wallet of public key pk as id at date. a is the holding
+// key of the wallet, nil for none.
+func (r *Registry) Register(id string, pk util.Publickey, a *big.Int, date uint64) (Entry, error) {
+	entry := Entry{ID: id, Pk: pk.Pk, Enrolled: date}
+	if a != nil {
+		h := generator.H
+		entry.Aux = new(curve.PointAffine).ScalarMultiplication(&h, a)
+		entry.HeldPk = new(curve.PointAffine).ScalarMultiplication(&pk.Pk, a)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.byPk[entry.Pk]; ok {
+		return Entry{}, fmt.Errorf("%w as %s", ErrAlreadyEnrolled, old.ID)
+	}
+	if entry.Aux != nil {
+		if old, ok := r.byAux[*entry.Aux]; ok {
+			return Entry{}, fmt.Errorf("enroll: holding key already assigned to %s", old.ID)
+		}
+		r.byAux[*entry.Aux] = &entry
+	}
+	r.byPk[entry.Pk] = &entry
+	return entry, nil
+}
+
+// Lookup returns the wallet of public key pk.
+func (r *Registry) Lookup(pk curve.PointAffine) (Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.byPk[pk]
+	if !ok {
+		return Entry{}, false
+	}
+	return *e, true
+}
+
+// LookupHolding returns the wallet whose holding key gives aux, provided it
+// re-randomizes its public key to heldPk.
+func (r *Registry) LookupHolding(aux, heldPk curve.PointAffine) (Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.byAux[aux]
+	if !ok || !e.HeldPk.Equal(&heldPk) {
+		return Entry{}, false
+	}
+	return *e, true
+}
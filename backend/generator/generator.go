@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+)
+
+// Domain separates these generators from any other hash-to-curve use.
+const Domain = "Asyn_CBDC/generator/v1"
+
+// domain-separation labels, one per independent base
+const (
+	LabelBalance = "balance"        // g0: account balance
+	LabelDelta   = "delta"          // g1: delta derived from the trace key
+	LabelTrace   = "trace"          // g2: trace public key
+	LabelKey     = "public-key"     // h: ElGamal / public key base
+	LabelDate    = "date"           // g of the date commitment
+	LabelDateR   = "date-blind"     // h of the date commitment
+	LabelAmount  = "amount"         // base of the amounts encrypted to the regulator
+	LabelCounter = "period-counter" // gc: period and counter of the frequency limit
+)
+
+// The generators used by every native computation and every circuit. Nobody
+// knows the discrete log of one to another: each is the hash of its label.
+var (
+	G0    = Derive(LabelBalance)
+	G1    = Derive(LabelDelta)
+	G2    = Derive(LabelTrace)
+	H     = Derive(LabelKey)
+	DateG = Derive(LabelDate)
+	DateH = Derive(LabelDateR)
+	Trans = Derive(LabelAmount)
+	Gc    = Derive(LabelCounter)
+)
+
+// Derive hashes label to a point of the prime order subgroup of BabyJubjub
+// with try-and-increment: y = SHA-256(Domain || label || counter), x is
+// recovered from the curve equation, and the point is multiplied by the
+// cofactor.
+func Derive(label string) curve.PointAffine {
+	params := curve.GetEdwardsCurve()
+	cofactor := params.Cofactor.BigInt(new(big.Int))
+
+	var one fr.Element
+	one.SetOne()
+	for counter := uint32(0); counter < 1<<16; counter++ {
+		var ctr [4]byte
+		binary.BigEndian.PutUint32(ctr[:], counter)
+		h := sha256.New()
+		h.Write([]byte(Domain))
+		h.Write([]byte{0})
+		h.Write([]byte(label))
+		h.Write(ctr[:])
+
+		var p curve.PointAffine
+		p.Y.SetBytes(h.Sum(nil))
+
+		// a*x^2 + y^2 = 1 + d*x^2*y^2  =>  x^2 = (1 - y^2) / (a - d*y^2)
+		var y2, num, den, x2 fr.Element
+		y2.Square(&p.Y)
+		num.Sub(&one, &y2)
+		den.Mul(&params.D, &y2)
+		den.Sub(&params.A, &den)
+		if den.IsZero() {
+			continue
+		}
+		x2.Div(&num, &den)
+		if p.X.Sqrt(&x2) == nil {
+			continue
+		}
+		if p.X.LexicographicallyLargest() {
+			p.X.Neg(&p.X)
+		}
+
+		p.ScalarMultiplication(&p, cofactor)
+		if p.IsZero() || !p.IsOnCurve() {
+			continue
+		}
+		return p
+	}
+	panic("generator: no point found for " + label)
+}
+
+// InCircuit returns p as a constant point for gnark circuits.
+func InCircuit(p curve.PointAffine) twistededwards.Point {
+	return twistededwards.Point{
+		X: p.X.BigInt(new(big.Int)),
+		Y: p.Y.BigInt(new(big.Int)),
+	}
+}
@@ -0,0 +1,70 @@
+package generator
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	"github.com/consensys/gnark/test"
+)
+
+func TestGenerators(t *testing.T) {
+	params := curve.GetEdwardsCurve()
+	all := map[string]curve.PointAffine{
+		"G0": G0, "G1": G1, "G2": G2, "H": H, "DateG": DateG, "DateH": DateH, "Trans": Trans, "Gc": Gc,
+	}
+	for name, p := range all {
+		if !p.IsOnCurve() {
+			t.Fatalf("%s is not on the curve", name)
+		}
+		var q curve.PointAffine
+		q.ScalarMultiplication(&p, &params.Order)
+		if !q.IsZero() {
+			t.Fatalf("%s is not in the prime order subgroup", name)
+		}
+		if p.Equal(&params.Base) {
+			t.Fatalf("%s is the curve base point", name)
+		}
+		for other, o := range all {
+			if other != name && p.Equal(&o) {
+				t.Fatalf("%s == %s", name, other)
+			}
+		}
+	}
+
+	again := Derive(LabelBalance)
+	if !again.Equal(&G0) {
+		t.Fatal("Derive is not deterministic")
+	}
+}
+
+type mulCircuit struct {
+	K   frontend.Variable
+	Res twistededwards.Point `gnark:",public"`
+}
+
+func (c *mulCircuit) Define(api frontend.API) error {
+	ed, err := twistededwards.NewEdCurve(api, ecctedwards.BN254)
+	if err != nil {
+		return err
+	}
+	p := ed.ScalarMul(InCircuit(G0), c.K)
+	api.AssertIsEqual(p.X, c.Res.X)
+	api.AssertIsEqual(p.Y, c.Res.Y)
+	return nil
+}
+
+func TestInCircuitMatchesNative(t *testing.T) {
+	k := big.NewInt(123456789)
+	var p curve.PointAffine
+	p.ScalarMultiplication(&G0, k)
+
+	assignment := mulCircuit{K: k, Res: twistededwards.Point{X: p.X, Y: p.Y}}
+	if err := test.IsSolved(&mulCircuit{}, &assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,82 @@
+package issuer
+
+import (
+	"Asyn_CBDC/backend/util"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark-crypto/signature"
+)
+
+// keyFile is the issuer on disk. It holds private keys: it is written 0600.
+type keyFile struct {
+	Root    []byte       `json:"root"`
+	Subkeys []subkeyFile `json:"subkeys"`
+}
+
+type subkeyFile struct {
+	Certificate Certificate `json:"certificate"`
+	Key         []byte      `json:"key"`
+}
+
+// Save writes the issuer to path, replacing it atomically.
+func (is *Issuer) Save(path string) error {
+	is.mu.Lock()
+	f := keyFile{Root: is.root.Bytes()}
+	for _, s := range is.subkeys {
+		f.Subkeys = append(f.Subkeys, subkeyFile{Certificate: s.cert, Key: s.key.Bytes()})
+	}
+	is.mu.Unlock()
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteSecret(path, data)
+}
+
+// Open reads an issuer written by Save. Every certificate must be signed by
+// the root key and match its private subkey.
+func Open(path string) (*Issuer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("issuer: %s: %w", path, err)
+	}
+	root, err := privateKey(f.Root)
+	if err != nil {
+		return nil, fmt.Errorf("issuer: %s: root key: %w", path, err)
+	}
+	is := &Issuer{root: root}
+	for i, s := range f.Subkeys {
+		key, err := privateKey(s.Key)
+		if err != nil {
+			return nil, fmt.Errorf("issuer: %s: subkey %d: %w", path, s.Certificate.Number, err)
+		}
+		if !bytes.Equal(key.Public().Bytes(), s.Certificate.PublicKey) {
+			return nil, fmt.Errorf("issuer: %s: subkey %d does not match its certificate", path, s.Certificate.Number)
+		}
+		if err := s.Certificate.Verify(root.Public()); err != nil {
+			return nil, fmt.Errorf("issuer: %s: %w", path, err)
+		}
+		if i > 0 && s.Certificate.Number != f.Subkeys[i-1].Certificate.Number+1 {
+			return nil, fmt.Errorf("issuer: %s: subkey %d out of order", path, s.Certificate.Number)
+		}
+		is.subkeys = append(is.subkeys, subkey{cert: s.Certificate, key: key})
+	}
+	return is, nil
+}
+
+func privateKey(b []byte) (signature.Signer, error) {
+	key := new(eddsa.PrivateKey)
+	if _, err := key.SetBytes(b); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,201 @@
+// Package issuer keeps the bank's signing keys. A long-lived root key
+// certifies numbered subkeys, each valid for a period of dates; accounts are
+// signed by the current subkey, and verifiers resolve the SigPublicKey of a
+// proof in a Registry of the certificates.
+package issuer
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark-crypto/signature"
+)
+
+// Hash is the hash of every issuer signature, the MiMC the circuits verify.
+const Hash = hash.MIMC_BN254
+
+var (
+	ErrNoSubkey       = errors.New("issuer: no signing subkey")
+	ErrBeyondValidity = errors.New("issuer: expiry beyond the validity of the subkey")
+	ErrBadCertificate = errors.New("issuer: certificate not signed by the root key")
+)
+
+// Certificate binds a subkey to its number and validity: the subkey signs from
+// NotBefore on, and only accounts expiring by NotAfter. Both are Unix times.
+type Certificate struct {
+	Number    uint32 `json:"number"`
+	NotBefore uint64 `json:"not_before"`
+	NotAfter  uint64 `json:"not_after"`
+	PublicKey []byte `json:"public_key"`
+	Signature []byte `json:"signature"` // root signature of the digest
+}
+
+// Digest is mimc(number,not_before,not_after,pk.X,pk.Y), the message signed
+// by the root key.
+func (c Certificate) Digest() ([]byte, error) {
+	pk, err := c.key()
+	if err != nil {
+		return nil, err
+	}
+	h := Hash.New()
+	var e fr.Element
+	for _, v := range []uint64{uint64(c.Number), c.NotBefore, c.NotAfter} {
+		b := e.SetUint64(v).Bytes()
+		h.Write(b[:])
+	}
+	x, y := pk.A.X.Bytes(), pk.A.Y.Bytes()
+	h.Write(x[:])
+	h.Write(y[:])
+	return h.Sum(nil), nil
+}
+
+// Key decodes the certified subkey.
+func (c Certificate) Key() (signature.PublicKey, error) {
+	return c.key()
+}
+
+func (c Certificate) key() (*eddsa.PublicKey, error) {
+	pk := new(eddsa.PublicKey)
+	if _, err := pk.SetBytes(c.PublicKey); err != nil {
+		return nil, fmt.Errorf("issuer: malformed subkey %d: %w", c.Number, err)
+	}
+	return pk, nil
+}
+
+// Verify checks that root signed c.
+func (c Certificate) Verify(root signature.PublicKey) error {
+	digest, err := c.Digest()
+	if err != nil {
+		return err
+	}
+	ok, err := root.Verify(c.Signature, digest, Hash.New())
+	if err != nil || !ok {
+		return fmt.Errorf("%w: subkey %d", ErrBadCertificate, c.Number)
+	}
+	return nil
+}
+
+// Covers reports whether an account expiring at expiry may be signed by the
+// subkey.
+func (c Certificate) Covers(expiry uint64) bool {
+	return c.NotBefore <= expiry && expiry <= c.NotAfter
+}
+
+func generateKey(rnd io.Reader) (*eddsa.PrivateKey, error) {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	return eddsa.GenerateKey(rnd)
+}
+
+type subkey struct {
+	cert Certificate
+	key  signature.Signer
+}
+
+// Issuer holds the root key and every subkey it certified. The last one is
+// current and signs; the others are kept so that their certificates can still
+// be published until they lapse.
+type Issuer struct {
+	mu      sync.Mutex
+	root    signature.Signer
+	subkeys []subkey
+}
+
+// New returns an issuer with a fresh root key and no subkey. Keys are drawn
+// from rnd, crypto/rand when rnd is nil.
+func New(rnd io.Reader) (*Issuer, error) {
+	root, err := generateKey(rnd)
+	if err != nil {
+		return nil, err
+	}
+	return &Issuer{root: root}, nil
+}
+
+// Root returns the root public key, the one verifiers pin.
+func (is *Issuer) Root() signature.PublicKey {
+	return is.root.Public()
+}
+
+// Rotate certifies a new subkey, valid from notBefore for accounts expiring by
+// notAfter, and makes it current. Older subkeys stop signing but their
+// certificates stay valid, so accounts they signed remain spendable until
+// they expire.
+func (is *Issuer) Rotate(notBefore, notAfter uint64, rnd io.Reader) (Certificate, error) {
+	if notAfter < notBefore {
+		return Certificate{}, errors.New("issuer: subkey expires before it starts")
+	}
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	var number uint32
+	if n := len(is.subkeys); n > 0 {
+		last := is.subkeys[n-1].cert
+		if notBefore < last.NotBefore {
+			return Certificate{}, fmt.Errorf("issuer: subkey starts before subkey %d", last.Number)
+		}
+		number = last.Number + 1
+	}
+	key, err := generateKey(rnd)
+	if err != nil {
+		return Certificate{}, err
+	}
+	cert := Certificate{
+		Number:    number,
+		NotBefore: notBefore,
+		NotAfter:  notAfter,
+		PublicKey: key.Public().Bytes(),
+	}
+	digest, err := cert.Digest()
+	if err != nil {
+		return Certificate{}, err
+	}
+	cert.Signature, err = is.root.Sign(digest, Hash.New())
+	if err != nil {
+		return Certificate{}, err
+	}
+	is.subkeys = append(is.subkeys, subkey{cert: cert, key: key})
+	return cert, nil
+}
+
+// Current returns the certificate of the signing subkey.
+func (is *Issuer) Current() (Certificate, error) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if len(is.subkeys) == 0 {
+		return Certificate{}, ErrNoSubkey
+	}
+	return is.subkeys[len(is.subkeys)-1].cert, nil
+}
+
+// Certificates returns the certificates of every subkey, oldest first.
+func (is *Issuer) Certificates() []Certificate {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	certs := make([]Certificate, len(is.subkeys))
+	for i, s := range is.subkeys {
+		certs[i] = s.cert
+	}
+	return certs
+}
+
+// Subkey returns the current subkey, checked to cover expiry, and its
+// certificate. It signs the account digest and whatever else the bank signs
+// for the same transaction, such as the date of FreqLimit.
+func (is *Issuer) Subkey(expiry uint64) (signature.Signer, Certificate, error) {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+	if len(is.subkeys) == 0 {
+		return nil, Certificate{}, ErrNoSubkey
+	}
+	s := is.subkeys[len(is.subkeys)-1]
+	if !s.cert.Covers(expiry) {
+		return nil, s.cert, fmt.Errorf("%w: %d not in [%d,%d] of subkey %d", ErrBeyondValidity, expiry, s.cert.NotBefore, s.cert.NotAfter, s.cert.Number)
+	}
+	return s.key, s.cert, nil
+}
@@ -0,0 +1,215 @@
+package issuer
+
+import (
+	"Asyn_CBDC/backend/util"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const day = 24 * 60 * 60
+
+func testIssuer(t *testing.T, seed string) *Issuer {
+	t.Helper()
+	rnd := util.NewSeededReader([]byte(seed))
+	is, err := New(rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := is.Rotate(0, 30*day, rnd); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := is.Rotate(20*day, 50*day, rnd); err != nil {
+		t.Fatal(err)
+	}
+	return is
+}
+
+func TestRotate(t *testing.T) {
+	is, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := is.Subkey(0); !errors.Is(err, ErrNoSubkey) {
+		t.Fatalf("no subkey: %v", err)
+	}
+
+	is = testIssuer(t, t.Name())
+	certs := is.Certificates()
+	if len(certs) != 2 || certs[0].Number != 0 || certs[1].Number != 1 {
+		t.Fatalf("certificates %+v", certs)
+	}
+	cur, err := is.Current()
+	if err != nil || cur.Number != 1 {
+		t.Fatalf("current %+v, %v", cur, err)
+	}
+	for _, c := range certs {
+		if err := c.Verify(is.Root()); err != nil {
+			t.Error(err)
+		}
+	}
+
+	key, cert, err := is.Subkey(40 * day)
+	if err != nil || cert.Number != 1 || string(key.Public().Bytes()) != string(cert.PublicKey) {
+		t.Fatalf("subkey %d, %v", cert.Number, err)
+	}
+	// the old subkey no longer signs, even in its own validity
+	if _, _, err := is.Subkey(10 * day); !errors.Is(err, ErrBeyondValidity) {
+		t.Errorf("expiry before the current subkey: %v", err)
+	}
+	if _, _, err := is.Subkey(51 * day); !errors.Is(err, ErrBeyondValidity) {
+		t.Errorf("expiry after the current subkey: %v", err)
+	}
+
+	if _, err := is.Rotate(10*day, 60*day, nil); err == nil {
+		t.Error("subkey starting before the current one accepted")
+	}
+	if _, err := is.Rotate(60*day, 50*day, nil); err == nil {
+		t.Error("subkey ending before it starts accepted")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	is := testIssuer(t, t.Name())
+	certs := is.Certificates()
+	reg := NewRegistry(is.Root())
+	for _, c := range certs {
+		if err := reg.Add(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old, err := certs[0].Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := certs[1].Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an account signed by the rotated subkey stays valid until its expiry
+	if c, err := reg.Resolve(old, 30*day, 25*day); err != nil || c.Number != 0 {
+		t.Errorf("rotated subkey: %d, %v", c.Number, err)
+	}
+	if c, err := reg.Resolve(cur, 45*day, 25*day); err != nil || c.Number != 1 {
+		t.Errorf("current subkey: %d, %v", c.Number, err)
+	}
+	if _, err := reg.Resolve(old, 30*day, 30*day+1); !errors.Is(err, ErrExpired) {
+		t.Errorf("expired account: %v", err)
+	}
+	if _, err := reg.Resolve(old, 45*day, 25*day); !errors.Is(err, ErrNotCovered) {
+		t.Errorf("expiry beyond the subkey: %v", err)
+	}
+	if _, err := reg.Resolve(nil, 10*day, 0); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("nil key: %v", err)
+	}
+
+	other := testIssuer(t, "other")
+	otherKey, _, err := other.Subkey(40 * day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Resolve(otherKey.Public(), 40*day, 0); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("key of another issuer: %v", err)
+	}
+	if err := reg.Add(other.Certificates()[1]); !errors.Is(err, ErrBadCertificate) {
+		t.Errorf("certificate of another root: %v", err)
+	}
+	stretched := certs[0]
+	stretched.NotAfter = 100 * day
+	if err := reg.Add(stretched); !errors.Is(err, ErrBadCertificate) {
+		t.Errorf("tampered validity: %v", err)
+	}
+
+	if n := reg.Prune(30*day + 1); n != 1 {
+		t.Errorf("pruned %d certificates, want 1", n)
+	}
+	if _, err := reg.Resolve(old, 30*day, 0); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("pruned subkey: %v", err)
+	}
+	if _, err := reg.Resolve(cur, 45*day, 31*day); err != nil {
+		t.Errorf("current subkey after prune: %v", err)
+	}
+}
+
+func TestSaveOpen(t *testing.T) {
+	is := testIssuer(t, t.Name())
+	path := filepath.Join(t.TempDir(), "issuer.json")
+	if err := is.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("stat %v, %v", fi, err)
+	}
+
+	loaded, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.Root().Equal(is.Root()) {
+		t.Error("root key changed")
+	}
+	want, got := is.Certificates(), loaded.Certificates()
+	if len(got) != len(want) {
+		t.Fatalf("%d certificates, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if string(got[i].PublicKey) != string(want[i].PublicKey) || string(got[i].Signature) != string(want[i].Signature) {
+			t.Errorf("certificate %d changed", i)
+		}
+	}
+
+	// the reloaded subkey signs for the same registry
+	key, _, err := loaded.Subkey(40 * day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, 32)
+	sig, err := key.Sign(msg, Hash.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := want[1].verifyWith(sig, msg); !ok || err != nil {
+		t.Errorf("signature of the reloaded subkey: %v, %v", ok, err)
+	}
+
+	if _, err := loaded.Rotate(40*day, 70*day, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := loaded.Current(); c.Number != 2 {
+		t.Errorf("rotated to %d, want 2", c.Number)
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	is := testIssuer(t, t.Name())
+	other := testIssuer(t, "other")
+	dir := t.TempDir()
+
+	// a subkey certified by another root
+	is.subkeys[1] = other.subkeys[1]
+	path := filepath.Join(dir, "mixed.json")
+	if err := is.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); !errors.Is(err, ErrBadCertificate) {
+		t.Errorf("mixed issuer: %v", err)
+	}
+
+	path = filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("corrupt file accepted")
+	}
+}
+
+func (c Certificate) verifyWith(sig, msg []byte) (bool, error) {
+	pk, err := c.Key()
+	if err != nil {
+		return false, err
+	}
+	return pk.Verify(sig, msg, Hash.New())
+}
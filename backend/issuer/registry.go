@@ -0,0 +1,77 @@
+package issuer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/signature"
+)
+
+var (
+	ErrUnknownKey = errors.New("issuer: signature key not in the registry")
+	ErrExpired    = errors.New("issuer: account signature expired")
+	ErrNotCovered = errors.New("issuer: expiry outside the validity of the subkey")
+)
+
+// Registry is the verifier's view of the issuer: the pinned root key and the
+// certificates it accepted, by subkey. Certificates of rotated subkeys stay
+// until Prune, so proofs on accounts they signed verify until expiry.
+type Registry struct {
+	mu    sync.RWMutex
+	root  signature.PublicKey
+	certs map[string]Certificate
+}
+
+// NewRegistry returns an empty registry pinned to root.
+func NewRegistry(root signature.PublicKey) *Registry {
+	return &Registry{root: root, certs: make(map[string]Certificate)}
+}
+
+// Add checks c against the root key and registers its subkey.
+func (r *Registry) Add(c Certificate) error {
+	if err := c.Verify(r.root); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certs[string(c.PublicKey)] = c
+	return nil
+}
+
+// Resolve returns the certificate of pk if an account signed by it expiring at
+// expiry is still spendable at now: the subkey is registered, its validity
+// covers expiry, and now is not past expiry.
+func (r *Registry) Resolve(pk signature.PublicKey, expiry, now uint64) (Certificate, error) {
+	if pk == nil {
+		return Certificate{}, ErrUnknownKey
+	}
+	r.mu.RLock()
+	c, ok := r.certs[string(pk.Bytes())]
+	r.mu.RUnlock()
+	if !ok {
+		return Certificate{}, ErrUnknownKey
+	}
+	if !c.Covers(expiry) {
+		return c, fmt.Errorf("%w: %d not in [%d,%d] of subkey %d", ErrNotCovered, expiry, c.NotBefore, c.NotAfter, c.Number)
+	}
+	if now > expiry {
+		return c, fmt.Errorf("%w at %d", ErrExpired, expiry)
+	}
+	return c, nil
+}
+
+// Prune drops the certificates that lapsed before now: every account their
+// subkey signed has expired. It returns how many were dropped.
+func (r *Registry) Prune(now uint64) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for k, c := range r.certs {
+		if c.NotAfter < now {
+			delete(r.certs, k)
+			n++
+		}
+	}
+	return n
+}
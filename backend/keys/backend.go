@@ -0,0 +1,160 @@
+package keys
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/plonk"
+	plonkbn254 "github.com/consensys/gnark/backend/plonk/bn254"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+)
+
+// Backend is the proof system a circuit is compiled for and proved with.
+type Backend string
+
+const (
+	// Groth16 compiles to R1CS and needs a setup per circuit.
+	Groth16 Backend = "groth16"
+	// PLONK compiles to SparseR1CS and sets every circuit up from one
+	// universal KZG SRS, see Store.
+	PLONK Backend = "plonk"
+)
+
+var ErrBackend = errors.New("keys: proof and key of different backends")
+
+// ParseBackend returns the backend named s.
+func ParseBackend(s string) (Backend, error) {
+	switch b := Backend(s); b {
+	case Groth16, PLONK:
+		return b, nil
+	}
+	return "", fmt.Errorf("keys: unknown backend %q", s)
+}
+
+// Proof is a proof of either backend.
+type Proof interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// ProvingKey is a proving key of either backend.
+type ProvingKey interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// VerifyingKey is a verifying key of either backend.
+type VerifyingKey interface {
+	io.WriterTo
+	io.ReaderFrom
+	NbPublicWitness() int
+}
+
+// Compile builds the constraint system of circuit over the BN254 scalar
+// field: an R1CS for Groth16, a SparseR1CS for PLONK.
+func (b Backend) Compile(circuit frontend.Circuit) (constraint.ConstraintSystem, error) {
+	switch b {
+	case Groth16:
+		return frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, circuit)
+	case PLONK:
+		return frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, circuit)
+	}
+	return nil, fmt.Errorf("keys: unknown backend %q", string(b))
+}
+
+func (b Backend) newCS() constraint.ConstraintSystem {
+	if b == PLONK {
+		return plonk.NewCS(ecc.BN254)
+	}
+	return groth16.NewCS(ecc.BN254)
+}
+
+func (b Backend) newProvingKey() ProvingKey {
+	if b == PLONK {
+		return plonk.NewProvingKey(ecc.BN254)
+	}
+	return groth16.NewProvingKey(ecc.BN254)
+}
+
+func (b Backend) newVerifyingKey() VerifyingKey {
+	if b == PLONK {
+		return plonk.NewVerifyingKey(ecc.BN254)
+	}
+	return groth16.NewVerifyingKey(ecc.BN254)
+}
+
+// NewProof returns an empty proof of b, to read one into.
+func (b Backend) NewProof() Proof {
+	if b == PLONK {
+		return plonk.NewProof(ecc.BN254)
+	}
+	return groth16.NewProof(ecc.BN254)
+}
+
+// Setup returns fresh keys for cs, compiled for b. A PLONK setup draws its
+// own SRS, which costs about as much as the setup itself: Store.Setup keeps
+// one SRS for all circuits instead.
+func (b Backend) Setup(cs constraint.ConstraintSystem) (Keys, error) {
+	return b.setup(cs, newSRS)
+}
+
+func (b Backend) setup(cs constraint.ConstraintSystem, srs func(constraint.ConstraintSystem) (kzg.SRS, kzg.SRS, error)) (Keys, error) {
+	k := Keys{Backend: b, CS: cs}
+	var err error
+	switch b {
+	case Groth16:
+		k.PK, k.VK, err = groth16.Setup(cs)
+	case PLONK:
+		canonical, lagrange, serr := srs(cs)
+		if serr != nil {
+			return Keys{}, serr
+		}
+		k.PK, k.VK, err = plonk.Setup(cs, canonical, lagrange)
+	default:
+		err = fmt.Errorf("keys: unknown backend %q", string(b))
+	}
+	if err != nil {
+		return Keys{}, err
+	}
+	return k, nil
+}
+
+// Prove proves witness, a full witness of the circuit of k.
+func (k Keys) Prove(witness witness.Witness) (Proof, error) {
+	switch pk := k.PK.(type) {
+	case *groth16bn254.ProvingKey:
+		return groth16.Prove(k.CS, pk, witness)
+	case *plonkbn254.ProvingKey:
+		return plonk.Prove(k.CS, pk, witness)
+	}
+	return nil, fmt.Errorf("keys: proving key %T", k.PK)
+}
+
+// Verify checks proof against vk and the public witness, with the backend of
+// vk.
+func Verify(proof Proof, vk VerifyingKey, publicWitness witness.Witness) error {
+	switch vk := vk.(type) {
+	case *groth16bn254.VerifyingKey:
+		p, ok := proof.(*groth16bn254.Proof)
+		if !ok {
+			return ErrBackend
+		}
+		return groth16.Verify(p, vk, publicWitness)
+	case *plonkbn254.VerifyingKey:
+		p, ok := proof.(*plonkbn254.Proof)
+		if !ok {
+			return ErrBackend
+		}
+		return plonk.Verify(p, vk, publicWitness)
+	}
+	return fmt.Errorf("keys: verifying key %T", vk)
+}
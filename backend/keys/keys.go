@@ -0,0 +1,267 @@
+package keys
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/frontend"
+)
+
+// Version of the key layout. Bump it whenever a circuit changes so that keys
+// written for the old constraint system are never picked up again.
+const Version = 10
+
+const (
+	csFile       = "circuit.r1cs"
+	pkFile       = "proving.key"
+	vkFile       = "verifying.key"
+	manifestFile = "manifest.json"
+)
+
+// DefaultDir is used when CBDC_KEYS_DIR is not set.
+const DefaultDir = "keys"
+
+var ErrNotFound = errors.New("keys: no keys for circuit")
+
+// Store keeps the setup artifacts of every circuit under
+// Dir/v<Version>/<backend>/<name>/. Backend is Groth16 when empty. PLONK keys
+// are set up from the universal SRS at Dir/kzg.srs, created on first use.
+type Store struct {
+	Dir     string
+	Backend Backend
+}
+
+// Keys holds everything produced by the setup of one circuit.
+type Keys struct {
+	Backend Backend
+	CS      constraint.ConstraintSystem
+	PK      ProvingKey
+	VK      VerifyingKey
+}
+
+type manifest struct {
+	Name          string `json:"name"`
+	Version       int    `json:"version"`
+	Backend       string `json:"backend"`
+	Curve         string `json:"curve"`
+	NbConstraints int    `json:"nb_constraints"`
+	NbPublic      int    `json:"nb_public"`
+	CSFingerprint string `json:"cs_fingerprint"`
+}
+
+// NewStore returns the Groth16 store at dir.
+func NewStore(dir string) Store {
+	return Store{Dir: dir}
+}
+
+// DefaultStore returns the store at $CBDC_KEYS_DIR, or DefaultDir, for the
+// backend $CBDC_BACKEND, Groth16 when unset.
+func DefaultStore() Store {
+	s := NewStore(DefaultDir)
+	if dir := os.Getenv("CBDC_KEYS_DIR"); dir != "" {
+		s.Dir = dir
+	}
+	s.Backend = Backend(os.Getenv("CBDC_BACKEND"))
+	return s
+}
+
+// WithBackend returns the store of backend b in the same directory.
+func (s Store) WithBackend(b Backend) Store {
+	s.Backend = b
+	return s
+}
+
+func (s Store) backend() Backend {
+	if s.Backend == "" {
+		return Groth16
+	}
+	return s.Backend
+}
+
+func (s Store) path(name string) string {
+	return filepath.Join(s.Dir, "v"+strconv.Itoa(Version), string(s.backend()), name)
+}
+
+// Setup returns the keys of name. On first use the circuit is compiled, set up
+// and written to the store; later calls load the stored artifacts after
+// checking that they still match the circuit.
+func (s Store) Setup(name string, circuit frontend.Circuit) (Keys, error) {
+	b := s.backend()
+	cs, err := b.Compile(circuit)
+	if err != nil {
+		return Keys{}, fmt.Errorf("keys: compile %s: %w", name, err)
+	}
+	fp, err := fingerprint(cs)
+	if err != nil {
+		return Keys{}, err
+	}
+
+	m, err := s.manifest(name)
+	switch {
+	case err == nil:
+		if m.CSFingerprint != fp {
+			return Keys{}, fmt.Errorf("keys: circuit %s changed since its keys were generated in %s, bump keys.Version", name, s.path(name))
+		}
+		return s.Load(name)
+	case !errors.Is(err, ErrNotFound):
+		return Keys{}, err
+	}
+
+	k, err := b.setup(cs, s.srs)
+	if err != nil {
+		return Keys{}, fmt.Errorf("keys: setup %s: %w", name, err)
+	}
+	if err := s.Write(name, k); err != nil {
+		return Keys{}, err
+	}
+	// another process may have won the race, always hand out what is on disk
+	return s.Load(name)
+}
+
+// Load reads the constraint system and both keys of name.
+func (s Store) Load(name string) (Keys, error) {
+	cs, pk, err := s.LoadProver(name)
+	if err != nil {
+		return Keys{}, err
+	}
+	vk, err := s.LoadVerifier(name)
+	if err != nil {
+		return Keys{}, err
+	}
+	return Keys{Backend: s.backend(), CS: cs, PK: pk, VK: vk}, nil
+}
+
+// LoadProver reads what a prover needs: the constraint system and the proving key.
+func (s Store) LoadProver(name string) (constraint.ConstraintSystem, ProvingKey, error) {
+	cs := s.backend().newCS()
+	if err := s.read(name, csFile, cs); err != nil {
+		return nil, nil, err
+	}
+	pk := s.backend().newProvingKey()
+	if err := s.read(name, pkFile, pk); err != nil {
+		return nil, nil, err
+	}
+	return cs, pk, nil
+}
+
+// LoadVerifier reads the verifying key of name only.
+func (s Store) LoadVerifier(name string) (VerifyingKey, error) {
+	vk := s.backend().newVerifyingKey()
+	if err := s.read(name, vkFile, vk); err != nil {
+		return nil, err
+	}
+	return vk, nil
+}
+
+// Write stores k under name. The artifacts are written to a temporary
+// directory which is renamed into place, so readers never see a partial set
+// and an existing set is never overwritten.
+func (s Store) Write(name string, k Keys) error {
+	if k.Backend != "" && k.Backend != s.backend() {
+		return fmt.Errorf("keys: write %s keys of %s to a %s store", name, k.Backend, s.backend())
+	}
+	dst := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "."+name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	fp, err := fingerprint(k.CS)
+	if err != nil {
+		return err
+	}
+	for file, w := range map[string]io.WriterTo{csFile: k.CS, pkFile: k.PK, vkFile: k.VK} {
+		if err := writeFile(filepath.Join(tmp, file), w); err != nil {
+			return fmt.Errorf("keys: write %s/%s: %w", name, file, err)
+		}
+	}
+	m := manifest{
+		Name:          name,
+		Version:       Version,
+		Backend:       string(s.backend()),
+		Curve:         ecc.BN254.String(),
+		NbConstraints: k.CS.GetNbConstraints(),
+		NbPublic:      k.VK.NbPublicWitness(),
+		CSFingerprint: fp,
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, manifestFile), data, 0o644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		if _, statErr := os.Stat(filepath.Join(dst, manifestFile)); statErr == nil {
+			return nil
+		}
+		return fmt.Errorf("keys: install %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s Store) manifest(name string) (manifest, error) {
+	var m manifest
+	data, err := os.ReadFile(filepath.Join(s.path(name), manifestFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, fmt.Errorf("%w %s in %s", ErrNotFound, name, s.Dir)
+	}
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("keys: bad manifest for %s: %w", name, err)
+	}
+	return m, nil
+}
+
+func (s Store) read(name, file string, r io.ReaderFrom) error {
+	f, err := os.Open(filepath.Join(s.path(name), file))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w %s in %s", ErrNotFound, name, s.Dir)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := r.ReadFrom(f); err != nil {
+		return fmt.Errorf("keys: read %s/%s: %w", name, file, err)
+	}
+	return nil
+}
+
+func writeFile(path string, w io.WriterTo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := w.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func fingerprint(cs constraint.ConstraintSystem) (string, error) {
+	var buf bytes.Buffer
+	if _, err := cs.WriteTo(&buf); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,159 @@
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+)
+
+type cubeCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *cubeCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X, c.X), c.Y)
+	return nil
+}
+
+type squareCircuit struct {
+	X frontend.Variable
+	Y frontend.Variable `gnark:",public"`
+}
+
+func (c *squareCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(api.Mul(c.X, c.X), c.Y)
+	return nil
+}
+
+func TestSetupPersistsKeys(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := NewStore(dir).Setup("cube", &cubeCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewStore(dir).Setup("cube", &cubeCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vk1, vk2 bytes.Buffer
+	first.VK.WriteTo(&vk1)
+	second.VK.WriteTo(&vk2)
+	if !bytes.Equal(vk1.Bytes(), vk2.Bytes()) {
+		t.Fatal("second setup did not reuse the stored verifying key")
+	}
+}
+
+func TestSeparateProverAndVerifier(t *testing.T) {
+	for _, b := range []Backend{Groth16, PLONK} {
+		t.Run(string(b), func(t *testing.T) {
+			store := NewStore(t.TempDir()).WithBackend(b)
+			if _, err := store.Setup("cube", &cubeCircuit{}); err != nil {
+				t.Fatal(err)
+			}
+
+			// prover side
+			cs, pk, err := store.LoadProver("cube")
+			if err != nil {
+				t.Fatal(err)
+			}
+			witness, err := frontend.NewWitness(&cubeCircuit{X: 3, Y: 27}, ecc.BN254.ScalarField())
+			if err != nil {
+				t.Fatal(err)
+			}
+			proof, err := Keys{Backend: b, CS: cs, PK: pk}.Prove(witness)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// verifier side
+			vk, err := store.LoadVerifier("cube")
+			if err != nil {
+				t.Fatal(err)
+			}
+			public, err := frontend.NewWitness(&cubeCircuit{Y: 27}, ecc.BN254.ScalarField(), frontend.PublicOnly())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(proof, vk, public); err != nil {
+				t.Fatal(err)
+			}
+			wrong, err := frontend.NewWitness(&cubeCircuit{Y: 28}, ecc.BN254.ScalarField(), frontend.PublicOnly())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := Verify(proof, vk, wrong); err == nil {
+				t.Fatal("proof verified against another public input")
+			}
+		})
+	}
+}
+
+func TestPLONKSharesSRS(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir).WithBackend(PLONK)
+	cube, err := store.Setup("cube", &cubeCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(filepath.Join(dir, srsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Setup("square", &squareCircuit{}); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(filepath.Join(dir, srsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Fatal("second circuit did not reuse the SRS")
+	}
+
+	// the groth16 keys of the same circuit live apart
+	g, err := NewStore(dir).Setup("cube", &cubeCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	witness, err := frontend.NewWitness(&cubeCircuit{X: 3, Y: 27}, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := witness.Public()
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := g.Prove(witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(proof, cube.VK, public); !errors.Is(err, ErrBackend) {
+		t.Fatalf("groth16 proof against a plonk key: %v", err)
+	}
+	if err := NewStore(dir).Write("cube2", cube); err == nil {
+		t.Fatal("wrote plonk keys to a groth16 store")
+	}
+}
+
+func TestSetupRejectsChangedCircuit(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewStore(dir).Setup("c", &cubeCircuit{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewStore(dir).Setup("c", &squareCircuit{}); err == nil {
+		t.Fatal("expected an error for keys generated from another circuit")
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	if _, err := NewStore(t.TempDir()).LoadVerifier("nothing"); err == nil {
+		t.Fatal("expected ErrNotFound")
+	}
+}
@@ -0,0 +1,118 @@
+package keys
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	kzgbn254 "github.com/consensys/gnark-crypto/ecc/bn254/kzg"
+	"github.com/consensys/gnark-crypto/kzg"
+	"github.com/consensys/gnark/constraint"
+)
+
+const srsFile = "kzg.srs"
+
+// The KZG SRS of PLONK is generated here with a random τ that is dropped
+// right after: like groth16.Setup, whoever runs it could forge proofs, so it
+// only fits a single trusted operator.
+
+// srsSize is the size of the Lagrange SRS of cs; its canonical SRS has 3 more
+// powers of τ for the blinding of the quotient.
+func srsSize(cs constraint.ConstraintSystem) uint64 {
+	return ecc.NextPowerOfTwo(uint64(cs.GetNbConstraints() + cs.GetNbPublicVariables()))
+}
+
+// cut returns the canonical and Lagrange SRS of cs from u, whose powers of τ
+// are a superset of theirs.
+func cut(u *kzgbn254.SRS, cs constraint.ConstraintSystem) (kzg.SRS, kzg.SRS, error) {
+	n := srsSize(cs)
+	canonical := &kzgbn254.SRS{Vk: u.Vk}
+	canonical.Pk.G1 = u.Pk.G1[:n+3]
+	lagrange := &kzgbn254.SRS{Vk: u.Vk}
+	var err error
+	lagrange.Pk.G1, err = kzgbn254.ToLagrangeG1(u.Pk.G1[:n])
+	if err != nil {
+		return nil, nil, fmt.Errorf("keys: lagrange srs: %w", err)
+	}
+	return canonical, lagrange, nil
+}
+
+func generateSRS(size uint64) (*kzgbn254.SRS, error) {
+	tau, err := rand.Int(rand.Reader, fr.Modulus())
+	if err != nil {
+		return nil, err
+	}
+	u, err := kzgbn254.NewSRS(size, tau)
+	if err != nil {
+		return nil, fmt.Errorf("keys: generate srs: %w", err)
+	}
+	return u, nil
+}
+
+// newSRS draws an SRS for cs alone.
+func newSRS(cs constraint.ConstraintSystem) (kzg.SRS, kzg.SRS, error) {
+	u, err := generateSRS(srsSize(cs) + 3)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cut(u, cs)
+}
+
+// srs cuts the SRS of cs from the universal SRS of the store.
+func (s Store) srs(cs constraint.ConstraintSystem) (kzg.SRS, kzg.SRS, error) {
+	u, err := s.universalSRS(srsSize(cs) + 3)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cut(u, cs)
+}
+
+// universalSRS reads the universal SRS of the store, replacing it by a new
+// one when it has less than size powers of τ. Keys set up from the old one
+// stay valid: each verifying key carries its own part of the SRS.
+func (s Store) universalSRS(size uint64) (*kzgbn254.SRS, error) {
+	path := filepath.Join(s.Dir, srsFile)
+	u := new(kzgbn254.SRS)
+	f, err := os.Open(path)
+	switch {
+	case err == nil:
+		_, err = u.ReadFrom(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("keys: read %s: %w", path, err)
+		}
+		if uint64(len(u.Pk.G1)) >= size {
+			return u, nil
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	u, err = generateSRS(size)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(s.Dir, "."+srsFile+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = u.WriteTo(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("keys: write %s: %w", path, err)
+	}
+	return u, nil
+}
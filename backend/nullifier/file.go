@@ -0,0 +1,98 @@
+package nullifier
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sync"
+)
+
+// FileStore is a Store backed by an append-only file of JSON records, one per
+// line. The file is read once on open and every new record is synced before
+// Record returns.
+type FileStore struct {
+	mu  sync.Mutex
+	mem *MemoryStore
+	f   *os.File
+}
+
+// OpenFile opens the store at path, creating it if needed. A record is only
+// acknowledged once its whole line is synced, so a last line cut short by a
+// crash is one whose Record never returned: it is truncated away.
+func OpenFile(path string) (*FileStore, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{mem: NewMemoryStore(), f: f}
+
+	rd := bufio.NewReader(f)
+	var end int64
+	for line := 1; ; line++ {
+		data, err := rd.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 {
+				if err := f.Truncate(end); err != nil {
+					f.Close()
+					return nil, err
+				}
+			}
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		end += int64(len(data))
+		data = bytes.TrimSpace(data)
+		if len(data) == 0 {
+			continue
+		}
+		var r Record
+		if err := json.Unmarshal(data, &r); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("nullifier: %s:%d: %w", path, line, err)
+		}
+		if err := s.mem.Record(r); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("nullifier: %s:%d: %w", path, line, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *FileStore) Record(r Record) error {
+	if r.Delta == nil {
+		return errors.New("nullifier: record without delta")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok, _ := s.mem.Lookup(r.Delta); ok {
+		return check(old, r)
+	}
+
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := s.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := s.f.Sync(); err != nil {
+		return err
+	}
+	return s.mem.Record(r)
+}
+
+func (s *FileStore) Lookup(delta *big.Int) (Record, bool, error) {
+	return s.mem.Lookup(delta)
+}
+
+func (s *FileStore) Close() error {
+	return s.f.Close()
+}
@@ -0,0 +1,117 @@
+// Package nullifier records the delta published by every verified offline
+// transaction. delta=mimc(tk,seq) is revealed once per account, so a delta
+// seen twice is an account spent twice while its wallet was offline.
+package nullifier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// ErrDoubleSpend matches every *DoubleSpendError with errors.Is.
+var ErrDoubleSpend = errors.New("nullifier: double spend")
+
+// Record is the nullifier of one verified transaction.
+type Record struct {
+	Delta *big.Int `json:"delta"`
+	TxID  string   `json:"tx"`
+}
+
+// DoubleSpendError reports the transaction holding Delta and the one that
+// tried to spend it again.
+type DoubleSpendError struct {
+	Delta  *big.Int
+	First  Record
+	Second Record
+}
+
+func (e *DoubleSpendError) Error() string {
+	return fmt.Sprintf("nullifier: delta %s spent by %s and %s", e.Delta, e.First.TxID, e.Second.TxID)
+}
+
+func (e *DoubleSpendError) Is(target error) bool {
+	return target == ErrDoubleSpend
+}
+
+// Store keeps the nullifiers seen by the ledger.
+type Store interface {
+	// Record stores r. Recording the same transaction again is a no-op, a
+	// different transaction with the same delta is a *DoubleSpendError.
+	Record(r Record) error
+	// Lookup returns the transaction that spent delta, if any.
+	Lookup(delta *big.Int) (Record, bool, error)
+}
+
+// TxID names a transaction by the hash of the data that identifies it. It
+// must not depend on the proof, which can be re-randomized.
+func TxID(data ...[]byte) string {
+	h := sha256.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func key(delta *big.Int) string {
+	return delta.Text(16)
+}
+
+func check(old, r Record) error {
+	if old.TxID == r.TxID {
+		return nil
+	}
+	return &DoubleSpendError{Delta: r.Delta, First: old, Second: r}
+}
+
+// MemoryStore is a Store held in memory, for tests and short-lived nodes.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(map[string]Record)}
+}
+
+func (s *MemoryStore) Record(r Record) error {
+	if r.Delta == nil {
+		return errors.New("nullifier: record without delta")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.records[key(r.Delta)]; ok {
+		return check(old, r)
+	}
+	s.records[key(r.Delta)] = r
+	return nil
+}
+
+func (s *MemoryStore) Lookup(delta *big.Int) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[key(delta)]
+	return r, ok, nil
+}
+
+// Reconcile records the transactions a wallet settles when it comes back
+// online. Transactions that reuse a delta, already known or earlier in the
+// batch, are returned as conflicts, the others are recorded. err is set only
+// when the store itself fails.
+func Reconcile(s Store, batch []Record) (conflicts []*DoubleSpendError, err error) {
+	for _, r := range batch {
+		err := s.Record(r)
+		var ds *DoubleSpendError
+		switch {
+		case err == nil:
+		case errors.As(err, &ds):
+			conflicts = append(conflicts, ds)
+		default:
+			return conflicts, err
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,126 @@
+package nullifier
+
+import (
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testStore(t *testing.T, s Store) {
+	a := Record{Delta: big.NewInt(7), TxID: TxID([]byte("a"))}
+	b := Record{Delta: big.NewInt(7), TxID: TxID([]byte("b"))}
+
+	if err := s.Record(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Record(a); err != nil {
+		t.Fatalf("recording the same tx again: %v", err)
+	}
+	err := s.Record(b)
+	var ds *DoubleSpendError
+	if !errors.As(err, &ds) || !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("got %v, want a double spend", err)
+	}
+	if ds.First.TxID != a.TxID || ds.Second.TxID != b.TxID {
+		t.Fatalf("conflict names %s and %s", ds.First.TxID, ds.Second.TxID)
+	}
+	if r, ok, err := s.Lookup(big.NewInt(7)); err != nil || !ok || r.TxID != a.TxID {
+		t.Fatalf("lookup: %v %v %v", r, ok, err)
+	}
+	if _, ok, err := s.Lookup(big.NewInt(8)); err != nil || ok {
+		t.Fatalf("lookup of an unspent delta: %v %v", ok, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nullifiers")
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+	s.Close()
+
+	// the spend survives a restart
+	s, err = OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.Record(Record{Delta: big.NewInt(7), TxID: "other"}); !errors.Is(err, ErrDoubleSpend) {
+		t.Fatalf("after reopen: got %v, want a double spend", err)
+	}
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nullifiers")
+	if err := os.WriteFile(path, []byte("{\"delta\":1,\"tx\":\"a\"}\nnot json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(path); err == nil {
+		t.Fatal("corrupt file accepted")
+	}
+}
+
+func TestFileStoreTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nullifiers")
+	if err := os.WriteFile(path, []byte("{\"delta\":1,\"tx\":\"a\"}\n{\"delta\":2,\"t"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("torn last record: %v", err)
+	}
+	if _, ok, _ := s.Lookup(big.NewInt(1)); !ok {
+		t.Error("complete record lost")
+	}
+	if _, ok, _ := s.Lookup(big.NewInt(2)); ok {
+		t.Error("torn record recorded")
+	}
+	if err := s.Record(Record{Delta: big.NewInt(2), TxID: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = OpenFile(path)
+	if err != nil {
+		t.Fatalf("after the torn record was replaced: %v", err)
+	}
+	defer s.Close()
+	if r, ok, _ := s.Lookup(big.NewInt(2)); !ok || r.TxID != "b" {
+		t.Errorf("record after the tear: %+v %v", r, ok)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	s := NewMemoryStore()
+	if err := s.Record(Record{Delta: big.NewInt(1), TxID: "online"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the wallet spent account 1 online and offline, and account 2 twice offline
+	batch := []Record{
+		{Delta: big.NewInt(1), TxID: "offline-1"},
+		{Delta: big.NewInt(2), TxID: "offline-2"},
+		{Delta: big.NewInt(3), TxID: "offline-3"},
+		{Delta: big.NewInt(2), TxID: "offline-2b"},
+	}
+	conflicts, err := Reconcile(s, batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 2 ||
+		conflicts[0].First.TxID != "online" || conflicts[0].Second.TxID != "offline-1" ||
+		conflicts[1].First.TxID != "offline-2" || conflicts[1].Second.TxID != "offline-2b" {
+		t.Fatalf("conflicts: %+v", conflicts)
+	}
+	if _, ok, _ := s.Lookup(big.NewInt(3)); !ok {
+		t.Fatal("clean spend not recorded")
+	}
+}
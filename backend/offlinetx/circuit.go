@@ -1,6 +1,7 @@
 package offlinetx
 
 import (
+	"Asyn_CBDC/backend/generator"
 	"Asyn_CBDC/backend/util"
 
 	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
@@ -11,24 +12,14 @@ import (
 	cir_eddsa "github.com/consensys/gnark/std/signature/eddsa"
 )
 
-type nonRegulationCircuit struct {
-	SigPublicKey       cir_eddsa.PublicKey `gnark:",public"`
-	Signature          cir_eddsa.Signature
-	Acc                util.Account
-	Bal                frontend.Variable
-	TacSk              frontend.Variable
-	Seq                frontend.Variable
-	Seq1               frontend.Variable
-	ExpectedDelta      frontend.Variable    `gnark:",public"`
-	ExpectedDAcc       util.Account         `gnark:",public"`
-	ExpectedDPublicKey twistededwards.Point `gnark:",public"`
-	PrivateKey         frontend.Variable
-	PublicKey          twistededwards.Point
-	Alpha              frontend.Variable
-	Randomness         frontend.Variable
-}
+// offlineCircuit proves an offline spend: the old account, signed by the bank,
+// is split into a payment of Amount to RecipientPk and a derived account
+// holding the change. Mode adds the regulation modules on top of this core;
+// the module slices have one element when the mode uses the module and none
+// otherwise, so unused modules have neither inputs nor constraints.
+type offlineCircuit struct {
+	Mode RegulationMode `gnark:"-"`
 
-type nolimitRegulationCircuit struct {
 	SigPublicKey       cir_eddsa.PublicKey `gnark:",public"`
 	Signature          cir_eddsa.Signature
 	Acc                util.Account
@@ -43,129 +34,67 @@ type nolimitRegulationCircuit struct {
 	PublicKey          twistededwards.Point
 	Alpha              frontend.Variable
 	Randomness         frontend.Variable
-	ExpectedCPk        [2]twistededwards.Point `gnark:",public"`
-	PublicKeyA         twistededwards.Point    `gnark:",public"`
-	RandomnessA        frontend.Variable
+	Amount             frontend.Variable
+	RecipientPk        twistededwards.Point `gnark:",public"`
+	RandomnessP        frontend.Variable
+	ExpectedPayment    util.Account `gnark:",public"`
+	State              frontend.Variable
+	Expiry             frontend.Variable `gnark:",public"`
+	Limit              frontend.Variable
+	Aux                twistededwards.Point
+
+	Reg     []regulationModule // NoLimitRegulation and up
+	Holding []holdingModule    // HoldingLimit and up
+	Date    []dateModule       // FreqLimit
 }
 
-type holdinglimitRegulationCircuit struct {
-	SigPublicKey       cir_eddsa.PublicKey `gnark:",public"`
-	Signature          cir_eddsa.Signature
-	Acc                util.Account
-	Bal                frontend.Variable
-	TacSk              frontend.Variable
-	Seq                frontend.Variable
-	Seq1               frontend.Variable
-	ExpectedDelta      frontend.Variable    `gnark:",public"`
-	ExpectedDAcc       util.Account         `gnark:",public"`
-	ExpectedDPublicKey twistededwards.Point `gnark:",public"`
-	PrivateKey         frontend.Variable
-	PublicKey          twistededwards.Point
-	Alpha              frontend.Variable
-	Randomness         frontend.Variable
-	ExpectedCPk        [2]twistededwards.Point `gnark:",public"`
-	PublicKeyA         twistededwards.Point    `gnark:",public"`
-	RandomnessA        frontend.Variable
-	A                  frontend.Variable
-	ExpectedAux        twistededwards.Point `gnark:",public"`
+// regulationModule encrypts the sender's public key to the regulator.
+type regulationModule struct {
+	ExpectedCPk [2]twistededwards.Point `gnark:",public"`
+	PublicKeyA  twistededwards.Point    `gnark:",public"`
+	RandomnessA frontend.Variable
 }
 
-type freqlimitRegulationCircuit struct {
-	SigPublicKey       cir_eddsa.PublicKey `gnark:",public"`
-	Signature          cir_eddsa.Signature
-	Acc                util.Account
-	Bal                frontend.Variable
-	TacSk              frontend.Variable
-	Seq                frontend.Variable
-	Seq1               frontend.Variable
-	ExpectedDelta      frontend.Variable    `gnark:",public"`
-	ExpectedDAcc       util.Account         `gnark:",public"`
-	ExpectedDPublicKey twistededwards.Point `gnark:",public"`
-	PrivateKey         frontend.Variable
-	PublicKey          twistededwards.Point
-	Alpha              frontend.Variable
-	Randomness         frontend.Variable
-	ExpectedCPk        [2]twistededwards.Point `gnark:",public"`
-	PublicKeyA         twistededwards.Point    `gnark:",public"`
-	RandomnessA        frontend.Variable
-	A                  frontend.Variable
-	ExpectedAux        twistededwards.Point `gnark:",public"`
-	Comment            twistededwards.Point `gnark:",public"`
-	Date               frontend.Variable
-	DateSignature      cir_eddsa.Signature
-	Commentr           frontend.Variable
+// holdingModule re-randomizes the regulation ciphertext by A. It publishes
+// Aux=h*A, which must be the Aux the bank signed with the account. It bounds
+// the change left in the derived account by HoldingLimit, the signed Limit.
+type holdingModule struct {
+	A            frontend.Variable
+	ExpectedAux  twistededwards.Point `gnark:",public"`
+	HoldingLimit frontend.Variable    `gnark:",public"`
 }
 
-func (circuit *nonRegulationCircuit) Define(api frontend.API) error {
-	//choose curve
-	curvepara := ecctedwards.BN254
+// dateModule commits to the date signed by the bank and counts the spends of
+// the period [PeriodStart,PeriodEnd] holding it. State=Period<<32+Counter is
+// the period of the last spend and the number of spends made in it.
+type dateModule struct {
+	Comment        twistededwards.Point `gnark:",public"`
+	Date           frontend.Variable
+	DateSignature  cir_eddsa.Signature
+	Commentr       frontend.Variable
+	PeriodStart    frontend.Variable `gnark:",public"`
+	PeriodEnd      frontend.Variable `gnark:",public"`
+	MaxTxPerPeriod frontend.Variable `gnark:",public"`
+	Period         frontend.Variable
+	Counter        frontend.Variable
+}
 
-	curve, err := twistededwards.NewEdCurve(api, curvepara)
-	if err != nil {
-		return err
+// newOfflineCircuit returns the circuit of mode, with its modules allocated.
+func newOfflineCircuit(mode RegulationMode) *offlineCircuit {
+	circuit := &offlineCircuit{Mode: mode}
+	if mode >= NoLimitRegulation {
+		circuit.Reg = make([]regulationModule, 1)
 	}
-
-	hashf1, err := mimc.NewMiMC(api)
-
-	if err != nil {
-		return err
+	if mode >= HoldingLimit {
+		circuit.Holding = make([]holdingModule, 1)
 	}
-
-	msg := circuit.Acc.A.X
-
-	// verify the signature in the cs
-	result_sig := cir_eddsa.Verify(curve, circuit.Signature, msg, circuit.SigPublicKey, &hashf1)
-	if result_sig != nil {
-		return err
+	if mode >= FreqLimit {
+		circuit.Date = make([]dateModule, 1)
 	}
-
-	//delta0=mimc(tk,seq)
-	mimc, _ := mimc.NewMiMC(api)
-	mimc.Write(circuit.TacSk, circuit.Seq)
-	delta_0 := mimc.Sum()
-	api.AssertIsEqual(delta_0, circuit.ExpectedDelta)
-
-	api.AssertIsEqual(cmp.IsLess(api, 0, circuit.Bal), 1)
-
-	//g0bal,Dpk,delta1
-	_g1, _ := twistededwards.GetCurveParams(curvepara)
-	g1 := twistededwards.Point{X: _g1.Base[0], Y: _g1.Base[1]}
-	g1delta0 := curve.ScalarMul(g1, delta_0)
-
-	_g0, _ := twistededwards.GetCurveParams(curvepara)
-	g0 := twistededwards.Point{X: _g0.Base[0], Y: _g0.Base[1]}
-	g0bal := curve.ScalarMul(g0, circuit.Bal)
-
-	expectedg0balg1delta0 := util.DecryptAcc(curve, circuit.Acc, circuit.PrivateKey)
-	g0balg1delta0 := curve.Add(g1delta0, g0bal)
-	api.AssertIsEqual(expectedg0balg1delta0.X, g0balg1delta0.X)
-
-	_h, _ := twistededwards.GetCurveParams(curvepara)
-	h := twistededwards.Point{X: _h.Base[0], Y: _h.Base[1]}
-	_pk := curve.ScalarMul(h, circuit.PrivateKey)
-	api.AssertIsEqual(_pk.X, circuit.PublicKey.X)
-
-	var dpublickey twistededwards.Point
-	dpublickey = curve.ScalarMul(circuit.PublicKey, circuit.Alpha)
-	api.AssertIsEqual(dpublickey.X, circuit.ExpectedDPublicKey.X)
-
-	mimc.Reset()
-	mimc.Write(circuit.TacSk, circuit.Seq1)
-	delta_1 := mimc.Sum()
-	g1delta1 := curve.ScalarMul(g1, delta_1)
-
-	plaintext := curve.Add(g0bal, g1delta1)
-
-	acc := util.EncryptAcc(curve, plaintext, dpublickey, circuit.Randomness, _h)
-	c1 := acc.A
-	c2 := acc.B
-	api.AssertIsEqual(c1.X, circuit.ExpectedDAcc.A.X)
-	api.AssertIsEqual(c2.X, circuit.ExpectedDAcc.B.X)
-
-	return nil
+	return circuit
 }
 
-func (circuit *nolimitRegulationCircuit) Define(api frontend.API) error {
+func (circuit *offlineCircuit) Define(api frontend.API) error {
 	//choose curve
 	curvepara := ecctedwards.BN254
 
@@ -174,244 +103,148 @@ func (circuit *nolimitRegulationCircuit) Define(api frontend.API) error {
 		return err
 	}
 
-	hashf1, err := mimc.NewMiMC(api)
-	if err != nil {
-		return err
-	}
-
-	msg := circuit.Acc.A.X
-
-	// verify the signature in the cs
-	result_sig := cir_eddsa.Verify(curve, circuit.Signature, msg, circuit.SigPublicKey, &hashf1)
-	if result_sig != nil {
-		return err
+	//frequency state of the derived account, unchanged unless counted
+	var newState frontend.Variable = circuit.State
+	for _, date := range circuit.Date {
+		newState = date.nextState(api, circuit.State)
 	}
 
-	//delta0=mimc(tk,seq)
-	mimc, _ := mimc.NewMiMC(api)
-	mimc.Write(circuit.TacSk, circuit.Seq)
-	delta_0 := mimc.Sum()
-	api.AssertIsEqual(delta_0, circuit.ExpectedDelta)
-
-	api.AssertIsEqual(cmp.IsLess(api, 0, circuit.Bal), 1)
-
-	//g0bal,Dpk,delta1
-	_g1, _ := twistededwards.GetCurveParams(curvepara)
-	g1 := twistededwards.Point{X: _g1.Base[0], Y: _g1.Base[1]}
-	g1delta0 := curve.ScalarMul(g1, delta_0)
-
-	_g0, _ := twistededwards.GetCurveParams(curvepara)
-	g0 := twistededwards.Point{X: _g0.Base[0], Y: _g0.Base[1]}
-	g0bal := curve.ScalarMul(g0, circuit.Bal)
-
-	expectedg0balg1delta0 := util.DecryptAcc(curve, circuit.Acc, circuit.PrivateKey)
-	g0balg1delta0 := curve.Add(g1delta0, g0bal)
-	api.AssertIsEqual(expectedg0balg1delta0.X, g0balg1delta0.X)
-
-	_h, _ := twistededwards.GetCurveParams(curvepara)
-	h := twistededwards.Point{X: _h.Base[0], Y: _h.Base[1]}
-	_pk := curve.ScalarMul(h, circuit.PrivateKey)
-	api.AssertIsEqual(_pk.X, circuit.PublicKey.X)
-
-	var dpublickey twistededwards.Point
-	dpublickey = curve.ScalarMul(circuit.PublicKey, circuit.Alpha)
-	api.AssertIsEqual(dpublickey.X, circuit.ExpectedDPublicKey.X)
-
-	mimc.Reset()
-	mimc.Write(circuit.TacSk, circuit.Seq1)
-	delta_1 := mimc.Sum()
-	g1delta1 := curve.ScalarMul(g1, delta_1)
-
-	plaintext := curve.Add(g0bal, g1delta1)
-
-	acc := util.EncryptAcc(curve, plaintext, dpublickey, circuit.Randomness, _h)
-	c1 := acc.A
-	c2 := acc.B
-	api.AssertIsEqual(c1.X, circuit.ExpectedDAcc.A.X)
-	api.AssertIsEqual(c2.X, circuit.ExpectedDAcc.B.X)
-	//32134
-
-	//CPk
-	_ah, _ := twistededwards.GetCurveParams(curvepara)
-
-	cipher := util.EncryptPk(curve, circuit.PublicKey, circuit.PublicKeyA, circuit.RandomnessA, _ah)
-	api.AssertIsEqual(cipher[0].X, circuit.ExpectedCPk[0].X)
-	api.AssertIsEqual(cipher[1].X, circuit.ExpectedCPk[1].X)
-	//38217+1278=39495
-
-	return nil
-}
-
-func (circuit *holdinglimitRegulationCircuit) Define(api frontend.API) error {
-	//choose curve
-	curvepara := ecctedwards.BN254
-
-	curve, err := twistededwards.NewEdCurve(api, curvepara)
+	change, err := circuit.spend(api, curve, newState)
 	if err != nil {
 		return err
 	}
 
-	hashf1, err := mimc.NewMiMC(api)
-	if err != nil {
-		return err
+	//change<=limit: limit-change does not wrap around
+	for _, holding := range circuit.Holding {
+		api.AssertIsEqual(holding.HoldingLimit, circuit.Limit)
+		util.AssertIsUint64(api, api.Sub(holding.HoldingLimit, change))
 	}
 
-	msg := circuit.Acc.A.X
-
-	// verify the signature in the cs
-	result_sig := cir_eddsa.Verify(curve, circuit.Signature, msg, circuit.SigPublicKey, &hashf1)
-	if result_sig != nil {
-		return err
+	h := generator.InCircuit(generator.H)
+	for _, reg := range circuit.Reg {
+		//CPk
+		cipher := util.EncryptPk(curve, circuit.PublicKey, reg.PublicKeyA, reg.RandomnessA, h)
+		for _, holding := range circuit.Holding {
+			var aux twistededwards.Point
+			cipher, aux = util.RegulationTK(curve, h, cipher, holding.A)
+			util.AssertPointEqual(api, aux, holding.ExpectedAux)
+			util.AssertPointEqual(api, aux, circuit.Aux)
+		}
+		util.AssertPointEqual(api, cipher[0], reg.ExpectedCPk[0])
+		util.AssertPointEqual(api, cipher[1], reg.ExpectedCPk[1])
 	}
 
-	//delta0=mimc(tk,seq)
-	mimc, _ := mimc.NewMiMC(api)
-	mimc.Write(circuit.TacSk, circuit.Seq)
-	delta_0 := mimc.Sum()
-	api.AssertIsEqual(delta_0, circuit.ExpectedDelta)
-
-	api.AssertIsEqual(cmp.IsLess(api, 0, circuit.Bal), 1)
-
-	//g0bal,Dpk,delta1
-	_g1, _ := twistededwards.GetCurveParams(curvepara)
-	g1 := twistededwards.Point{X: _g1.Base[0], Y: _g1.Base[1]}
-	g1delta0 := curve.ScalarMul(g1, delta_0)
-
-	_g0, _ := twistededwards.GetCurveParams(curvepara)
-	g0 := twistededwards.Point{X: _g0.Base[0], Y: _g0.Base[1]}
-	g0bal := curve.ScalarMul(g0, circuit.Bal)
-
-	expectedg0balg1delta0 := util.DecryptAcc(curve, circuit.Acc, circuit.PrivateKey)
-	g0balg1delta0 := curve.Add(g1delta0, g0bal)
-	api.AssertIsEqual(expectedg0balg1delta0.X, g0balg1delta0.X)
-
-	_h, _ := twistededwards.GetCurveParams(curvepara)
-	h := twistededwards.Point{X: _h.Base[0], Y: _h.Base[1]}
-	_pk := curve.ScalarMul(h, circuit.PrivateKey)
-	api.AssertIsEqual(_pk.X, circuit.PublicKey.X)
-
-	var dpublickey twistededwards.Point
-	dpublickey = curve.ScalarMul(circuit.PublicKey, circuit.Alpha)
-	api.AssertIsEqual(dpublickey.X, circuit.ExpectedDPublicKey.X)
-
-	mimc.Reset()
-	mimc.Write(circuit.TacSk, circuit.Seq1)
-	delta_1 := mimc.Sum()
-	g1delta1 := curve.ScalarMul(g1, delta_1)
-
-	plaintext := curve.Add(g0bal, g1delta1)
-
-	acc := util.EncryptAcc(curve, plaintext, dpublickey, circuit.Randomness, _h)
-	c1 := acc.A
-	c2 := acc.B
-	api.AssertIsEqual(c1.X, circuit.ExpectedDAcc.A.X)
-	api.AssertIsEqual(c2.X, circuit.ExpectedDAcc.B.X)
-	//32134
-
-	//CPk
-	_ah, _ := twistededwards.GetCurveParams(curvepara)
-
-	cipher := util.EncryptPk(curve, circuit.PublicKey, circuit.PublicKeyA, circuit.RandomnessA, _ah)
-
-	reginfo, aux := util.RegulationTK(curve, _ah, cipher, circuit.A)
-
-	api.AssertIsEqual(reginfo[0].X, circuit.ExpectedCPk[0].X)
-	api.AssertIsEqual(reginfo[1].X, circuit.ExpectedCPk[1].X)
-	api.AssertIsEqual(aux.X, circuit.ExpectedAux.X)
-	//48868
+	for _, date := range circuit.Date {
+		hashf, err := mimc.NewMiMC(api)
+		if err != nil {
+			return err
+		}
+		if err := cir_eddsa.Verify(curve, date.DateSignature, date.Date, circuit.SigPublicKey, &hashf); err != nil {
+			return err
+		}
+
+		dateg := generator.InCircuit(generator.DateG)
+		dateh := generator.InCircuit(generator.DateH)
+		comm := util.Pedersen(curve, dateg, dateh, date.Date, date.Commentr)
+		util.AssertPointEqual(api, comm, date.Comment)
+	}
 
 	return nil
 }
 
-func (circuit *freqlimitRegulationCircuit) Define(api frontend.API) error {
-	//choose curve
-	curvepara := ecctedwards.BN254
+// nextState checks the date window and returns the state after this spend:
+// counter+1 in the same period, 1 once the period rolled over, at most
+// MaxTxPerPeriod either way.
+func (date dateModule) nextState(api frontend.API, state frontend.Variable) frontend.Variable {
+	//state=period<<32+counter
+	api.ToBinary(date.Counter, CounterBits)
+	util.AssertIsUint64(api, date.Period)
+	api.AssertIsEqual(state, api.Add(api.Mul(date.Period, 1<<CounterBits), date.Counter))
+
+	//periodstart<=date<=periodend, period<=periodstart
+	util.AssertIsUint64(api, api.Sub(date.Date, date.PeriodStart))
+	util.AssertIsUint64(api, api.Sub(date.PeriodEnd, date.Date))
+	util.AssertIsUint64(api, api.Sub(date.PeriodStart, date.Period))
+
+	same := api.IsZero(api.Sub(date.Period, date.PeriodStart))
+	counter := api.Select(same, api.Add(date.Counter, 1), 1)
+	util.AssertIsUint64(api, api.Sub(date.MaxTxPerPeriod, counter))
+
+	return api.Add(api.Mul(date.PeriodStart, 1<<CounterBits), counter)
+}
 
-	curve, err := twistededwards.NewEdCurve(api, curvepara)
+// spend is the core shared by every mode: the signed old account is split
+// into the payment and the change account, bal=amount+change. The change
+// account carries newState. It returns the change.
+func (circuit *offlineCircuit) spend(api frontend.API, curve twistededwards.Curve, newState frontend.Variable) (frontend.Variable, error) {
+	hashf1, err := mimc.NewMiMC(api)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	hashf1, err := mimc.NewMiMC(api)
-	hashf2, err := mimc.NewMiMC(api)
+	//the bank signs the whole account, its seq, the expiry of the signature,
+	//the holding limit of the wallet's tier and the Aux of its holding key
+	msg, err := util.AccountDigest(api, circuit.Acc, circuit.Seq, circuit.Expiry, circuit.Limit, circuit.Aux)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	msg := circuit.Acc.A.X
-
 	// verify the signature in the cs
 	result_sig := cir_eddsa.Verify(curve, circuit.Signature, msg, circuit.SigPublicKey, &hashf1)
 	if result_sig != nil {
-		return err
-	}
-	msg = circuit.Date
-	date_sig := cir_eddsa.Verify(curve, circuit.DateSignature, msg, circuit.SigPublicKey, &hashf2)
-	if date_sig != nil {
-		return err
+		return nil, result_sig
 	}
 
 	//delta0=mimc(tk,seq)
-	mimc, _ := mimc.NewMiMC(api)
-	mimc.Write(circuit.TacSk, circuit.Seq)
-	delta_0 := mimc.Sum()
+	delta_0, err := util.CalculateDelta(api, circuit.TacSk, circuit.Seq)
+	if err != nil {
+		return nil, err
+	}
 	api.AssertIsEqual(delta_0, circuit.ExpectedDelta)
 
 	api.AssertIsEqual(cmp.IsLess(api, 0, circuit.Bal), 1)
 
+	//bal=amount+change, both in [0,2^64)
+	change := api.Sub(circuit.Bal, circuit.Amount)
+	util.AssertIsUint64(api, circuit.Amount)
+	util.AssertIsUint64(api, change)
+
 	//g0bal,Dpk,delta1
-	_g1, _ := twistededwards.GetCurveParams(curvepara)
-	g1 := twistededwards.Point{X: _g1.Base[0], Y: _g1.Base[1]}
+	g1 := generator.InCircuit(generator.G1)
 	g1delta0 := curve.ScalarMul(g1, delta_0)
 
-	_g0, _ := twistededwards.GetCurveParams(curvepara)
-	g0 := twistededwards.Point{X: _g0.Base[0], Y: _g0.Base[1]}
+	g0 := generator.InCircuit(generator.G0)
 	g0bal := curve.ScalarMul(g0, circuit.Bal)
 
+	gc := generator.InCircuit(generator.Gc)
+
 	expectedg0balg1delta0 := util.DecryptAcc(curve, circuit.Acc, circuit.PrivateKey)
-	g0balg1delta0 := curve.Add(g1delta0, g0bal)
-	api.AssertIsEqual(expectedg0balg1delta0.X, g0balg1delta0.X)
+	g0balg1delta0 := curve.Add(curve.Add(g1delta0, g0bal), curve.ScalarMul(gc, circuit.State))
+	util.AssertPointEqual(api, expectedg0balg1delta0, g0balg1delta0)
 
-	_h, _ := twistededwards.GetCurveParams(curvepara)
-	h := twistededwards.Point{X: _h.Base[0], Y: _h.Base[1]}
+	h := generator.InCircuit(generator.H)
 	_pk := curve.ScalarMul(h, circuit.PrivateKey)
-	api.AssertIsEqual(_pk.X, circuit.PublicKey.X)
+	util.AssertPointEqual(api, _pk, circuit.PublicKey)
 
-	var dpublickey twistededwards.Point
-	dpublickey = curve.ScalarMul(circuit.PublicKey, circuit.Alpha)
-	api.AssertIsEqual(dpublickey.X, circuit.ExpectedDPublicKey.X)
+	dpublickey := curve.ScalarMul(circuit.PublicKey, circuit.Alpha)
+	util.AssertPointEqual(api, dpublickey, circuit.ExpectedDPublicKey)
 
-	mimc.Reset()
-	mimc.Write(circuit.TacSk, circuit.Seq1)
-	delta_1 := mimc.Sum()
+	//seq1=seq+1, the derived account reveals the next delta and no other
+	api.AssertIsEqual(circuit.Seq1, api.Add(circuit.Seq, 1))
+	delta_1, err := util.CalculateDelta(api, circuit.TacSk, circuit.Seq1)
+	if err != nil {
+		return nil, err
+	}
 	g1delta1 := curve.ScalarMul(g1, delta_1)
 
-	plaintext := curve.Add(g0bal, g1delta1)
-
-	acc := util.EncryptAcc(curve, plaintext, dpublickey, circuit.Randomness, _h)
-	c1 := acc.A
-	c2 := acc.B
-	api.AssertIsEqual(c1.X, circuit.ExpectedDAcc.A.X)
-	api.AssertIsEqual(c2.X, circuit.ExpectedDAcc.B.X)
-	//32134
+	//change account: (g0*change+g1*delta1+gc*state+r*dpk,r*h)
+	plaintext := curve.Add(curve.Add(curve.ScalarMul(g0, change), g1delta1), curve.ScalarMul(gc, newState))
+	acc := util.EncryptAcc(curve, plaintext, dpublickey, circuit.Randomness, h)
+	util.AssertAccountEqual(api, acc, circuit.ExpectedDAcc)
 
-	//CPk
-	_ah, _ := twistededwards.GetCurveParams(curvepara)
+	//payment: (g0*amount+rp*pk_recipient,rp*h)
+	payment := util.EncryptAcc(curve, curve.ScalarMul(g0, circuit.Amount), circuit.RecipientPk, circuit.RandomnessP, h)
+	util.AssertAccountEqual(api, payment, circuit.ExpectedPayment)
 
-	cipher := util.EncryptPk(curve, circuit.PublicKey, circuit.PublicKeyA, circuit.RandomnessA, _ah)
-
-	reginfo, aux := util.RegulationTK(curve, _ah, cipher, circuit.A)
-
-	api.AssertIsEqual(reginfo[0].X, circuit.ExpectedCPk[0].X)
-	api.AssertIsEqual(reginfo[1].X, circuit.ExpectedCPk[1].X)
-	api.AssertIsEqual(aux.X, circuit.ExpectedAux.X)
-	//48868
-
-	_G, _ := twistededwards.GetCurveParams(curvepara)
-	_H, _ := twistededwards.GetCurveParams(curvepara)
-	comm := util.Pedersen(curve, _G, _H, circuit.Date, circuit.Commentr)
-	api.AssertIsEqual(comm.X, circuit.Comment.X)
-	//61434
-
-	return nil
+	return change, nil
 }
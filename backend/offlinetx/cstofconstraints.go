@@ -1,17 +1,66 @@
 package offlinetx
 
 import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/keys"
+	"Asyn_CBDC/backend/nullifier"
 	"Asyn_CBDC/backend/util"
+	"crypto/rand"
+	"fmt"
+	"math/big"
 
 	"github.com/consensys/gnark-crypto/ecc"
 	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
 	"github.com/consensys/gnark-crypto/hash"
-	"github.com/consensys/gnark/backend/groth16"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/frontend/cs/r1cs"
 	"github.com/consensys/gnark/std/algebra/native/twistededwards"
 )
 
+// names of the offline circuits in a keys.Store
+const (
+	CircuitNoRegulation      = "offline_noregulation"
+	CircuitNoLimitRegulation = "offline_nolimit"
+	CircuitHoldingLimit      = "offline_holdinglimit"
+	CircuitFreqLimit         = "offline_freqlimit"
+)
+
+// NewCircuit returns the offline circuit of mode, to compile or set up.
+func NewCircuit(mode RegulationMode) frontend.Circuit {
+	return newOfflineCircuit(mode)
+}
+
+// Assignment is the witness of o proved in mode.
+func (o Offline) Assignment(mode RegulationMode) frontend.Circuit {
+	return offlineAssignment(o, mode, ecctedwards.BN254)
+}
+
+func newCircuit(name string) (frontend.Circuit, error) {
+	for mode := NoRegulation; mode <= FreqLimit; mode++ {
+		if n, _ := mode.Circuit(); n == name {
+			return newOfflineCircuit(mode), nil
+		}
+	}
+	return nil, fmt.Errorf("offlinetx: unknown circuit %q", name)
+}
+
+// SetupKeys loads the keys of the offline circuit name from store, running the
+// setup on first use.
+func SetupKeys(store keys.Store, name string) (keys.Keys, error) {
+	circuit, err := newCircuit(name)
+	if err != nil {
+		return keys.Keys{}, err
+	}
+	return store.Setup(name, circuit)
+}
+
+func prove(k keys.Keys, assignment frontend.Circuit) (keys.Proof, error) {
+	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, err
+	}
+	return k.Prove(witness)
+}
+
 func T_OfflineTx() {
 
 	/* test */
@@ -53,218 +102,151 @@ func T_OfflineTx() {
 	fmt.Println("test encrypt and decrypt:(DAcc)", dg0bal.Equal(&g0bal))*/
 }
 
-func T_offlineTxWithNoRegulation() {
-	curveid := ecctedwards.BN254
+func T_offlineTxWithNoRegulation(store keys.Store) error {
+	return offlineTx(store, NoRegulation)
+}
 
-	hashFunc := hash.MIMC_BN254
-	params, _ := twistededwards.GetCurveParams(curveid)
+func T_offlineTxWithNoLimitRegulation(store keys.Store) error {
+	return offlineTx(store, NoLimitRegulation)
+}
 
-	var offline Offline
-	offline = offline.Execution(params, hashFunc, curveid)
+func T_offlineTxWithHoldinglimitRegulation(store keys.Store) error {
+	return offlineTx(store, HoldingLimit)
+}
 
-	var circuit nonRegulationCircuit
-	r1cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+func T_offlineTxWithFreqlimitRegulation(store keys.Store) error {
+	return offlineTx(store, FreqLimit)
+}
 
-	pk, vk, err := groth16.Setup(r1cs)
+func offlineTx(store keys.Store, mode RegulationMode) error {
+	curveid := ecctedwards.BN254
 
-	var assignment nonRegulationCircuit
+	hashFunc := hash.MIMC_BN254
+	params, _ := twistededwards.GetCurveParams(curveid)
 
-	acc_c1 := offline.OldAcc[0]
-	acc_c2 := offline.OldAcc[1]
-	acc := util.Account{
-		A: twistededwards.Point{X: acc_c1.X, Y: acc_c1.Y},
-		B: twistededwards.Point{X: acc_c2.X, Y: acc_c2.Y},
-	}
-	assignment.Acc = acc
-	assignment.Bal = offline.Bal
-	assignment.TacSk = offline.Tracesk.Sk
-	assignment.Seq = offline.Oldseq
-	assignment.Seq1 = offline.Newseq
-	assignment.ExpectedDelta = offline.Delta
-
-	dacccipher := offline.Deriveacc.Acc
-	dacc_c1 := dacccipher[0]
-	dacc_c2 := dacccipher[1]
-	dacc := util.Account{
-		A: twistededwards.Point{X: dacc_c1.X, Y: dacc_c1.Y},
-		B: twistededwards.Point{X: dacc_c2.X, Y: dacc_c2.Y},
+	is, err := newSimulationIssuer(rand.Reader)
+	if err != nil {
+		return err
 	}
-	assignment.ExpectedDAcc = dacc
-	assignment.ExpectedDPublicKey = twistededwards.Point{
-		X: offline.Deriveacc.Keypair.DPk.Pk.X,
-		Y: offline.Deriveacc.Keypair.DPk.Pk.Y,
+	offline := Offline{Mode: mode, Issuer: is}
+	offline, err = offline.Execution(params, hashFunc, curveid, rand.Reader)
+	if err != nil {
+		return err
 	}
-	assignment.PrivateKey = offline.Sk.Sk
-	assignment.PublicKey = twistededwards.Point{X: offline.Pk.Pk.X, Y: offline.Pk.Pk.Y}
-	assignment.Alpha = offline.Deriveacc.Keypair.Deriver
-	assignment.Randomness = offline.Deriveacc.R
 
-	_sigpublicKey := offline.Sigpk.Bytes()
-	assignment.SigPublicKey.Assign(curveid, _sigpublicKey[:32])
-	assignment.Signature.Assign(curveid, offline.Signature)
+	return proveAndVerify(store, mode, offline)
+}
+
+func proveAndVerify(store keys.Store, mode RegulationMode, offline Offline) error {
+	name, err := mode.Circuit()
+	if err != nil {
+		return err
+	}
+	k, err := SetupKeys(store, name)
+	if err != nil {
+		return err
+	}
 
-	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	publicWitness, err := witness.Public()
-	proof, err := groth16.Prove(r1cs, pk, witness)
+	assignment := offlineAssignment(offline, mode, ecctedwards.BN254)
 
-	err = groth16.Verify(proof, vk, publicWitness)
+	proof, err := prove(k, assignment)
+	if err != nil {
+		return err
+	}
+	reg, err := registry(offline.Issuer)
 	if err != nil {
-		// invalid proof
+		return err
 	}
+	return VerifyIssued(reg, offline.Policy, testDate, mode, proof, offline.PublicInputs(mode), k.VK)
 }
 
-func T_offlineTxWithNoLimitRegulation() {
+// T_offlinePayment pays 50 to a fresh wallet, disclosing the date as in
+// FreqLimit.
+func T_offlinePayment(store keys.Store) error {
 	curveid := ecctedwards.BN254
 
 	hashFunc := hash.MIMC_BN254
 	params, _ := twistededwards.GetCurveParams(curveid)
 
-	var offline Offline
-	offline = offline.Execution(params, hashFunc, curveid)
-
-	var circuit nolimitRegulationCircuit
-	r1cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-
-	pk, vk, err := groth16.Setup(r1cs)
-
-	var assignment nolimitRegulationCircuit
+	var recipient enroll.Enroll
+	recipient = recipient.Init(params, hashFunc, rand.Reader)
 
-	acc_c1 := offline.OldAcc[0]
-	acc_c2 := offline.OldAcc[1]
-	acc := util.Account{
-		A: twistededwards.Point{X: acc_c1.X, Y: acc_c1.Y},
-		B: twistededwards.Point{X: acc_c2.X, Y: acc_c2.Y},
-	}
-	assignment.Acc = acc
-	assignment.Bal = offline.Bal
-	assignment.TacSk = offline.Tracesk.Sk
-	assignment.Seq = offline.Oldseq
-	assignment.Seq1 = offline.Newseq
-	assignment.ExpectedDelta = offline.Delta
-
-	dacccipher := offline.Deriveacc.Acc
-	dacc_c1 := dacccipher[0]
-	dacc_c2 := dacccipher[1]
-	dacc := util.Account{
-		A: twistededwards.Point{X: dacc_c1.X, Y: dacc_c1.Y},
-		B: twistededwards.Point{X: dacc_c2.X, Y: dacc_c2.Y},
-	}
-	assignment.ExpectedDAcc = dacc
-	assignment.ExpectedDPublicKey = twistededwards.Point{
-		X: offline.Deriveacc.Keypair.DPk.Pk.X,
-		Y: offline.Deriveacc.Keypair.DPk.Pk.Y,
+	is, err := newSimulationIssuer(rand.Reader)
+	if err != nil {
+		return err
 	}
-	assignment.PrivateKey = offline.Sk.Sk
-	assignment.PublicKey = twistededwards.Point{X: offline.Pk.Pk.X, Y: offline.Pk.Pk.Y}
-	assignment.Alpha = offline.Deriveacc.Keypair.Deriver
-	assignment.Randomness = offline.Deriveacc.R
-	var cpk [2]twistededwards.Point
-	cpk[0] = twistededwards.Point{X: offline.CipherPk[0].X, Y: offline.CipherPk[0].Y}
-	cpk[1] = twistededwards.Point{X: offline.CipherPk[1].X, Y: offline.CipherPk[1].Y}
-	assignment.ExpectedCPk = cpk
-	assignment.PublicKeyA = twistededwards.Point{X: offline.Apk.Pk.X, Y: offline.Apk.Pk.Y}
-	assignment.RandomnessA = offline.Ar
-
-	_sigpublicKey := offline.Sigpk.Bytes()
-	assignment.SigPublicKey.Assign(curveid, _sigpublicKey[:32])
-	assignment.Signature.Assign(curveid, offline.Signature)
-
-	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	publicWitness, err := witness.Public()
-	proof, err := groth16.Prove(r1cs, pk, witness)
-
-	err = groth16.Verify(proof, vk, publicWitness)
+	offline := Offline{Mode: FreqLimit, Issuer: is}
+	offline, err = offline.Pay(params, hashFunc, curveid, big.NewInt(50), recipient.Pk, rand.Reader)
 	if err != nil {
-		// invalid proof
+		return err
 	}
+
+	return proveAndVerify(store, FreqLimit, offline)
 }
 
-func T_offlineTxWithHoldinglimitRegulation() {
+// T_offlineDoubleSpend spends one account twice, to two different derived
+// accounts, and settles both proofs in nulls. It returns the record of the
+// first spend and the error of the second.
+func T_offlineDoubleSpend(store keys.Store, nulls nullifier.Store) (nullifier.Record, error) {
 	curveid := ecctedwards.BN254
 
 	hashFunc := hash.MIMC_BN254
 	params, _ := twistededwards.GetCurveParams(curveid)
 
-	var offline Offline
-	offline = offline.Execution(params, hashFunc, curveid)
-
-	var circuit holdinglimitRegulationCircuit
-	r1cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
-
-	pk, vk, err := groth16.Setup(r1cs)
-
-	var assignment holdinglimitRegulationCircuit
-
-	acc_c1 := offline.OldAcc[0]
-	acc_c2 := offline.OldAcc[1]
-	acc := util.Account{
-		A: twistededwards.Point{X: acc_c1.X, Y: acc_c1.Y},
-		B: twistededwards.Point{X: acc_c2.X, Y: acc_c2.Y},
-	}
-	assignment.Acc = acc
-	assignment.Bal = offline.Bal
-	assignment.TacSk = offline.Tracesk.Sk
-	assignment.Seq = offline.Oldseq
-	assignment.Seq1 = offline.Newseq
-	assignment.ExpectedDelta = offline.Delta
-
-	dacccipher := offline.Deriveacc.Acc
-	dacc_c1 := dacccipher[0]
-	dacc_c2 := dacccipher[1]
-	dacc := util.Account{
-		A: twistededwards.Point{X: dacc_c1.X, Y: dacc_c1.Y},
-		B: twistededwards.Point{X: dacc_c2.X, Y: dacc_c2.Y},
-	}
-	assignment.ExpectedDAcc = dacc
-	assignment.ExpectedDPublicKey = twistededwards.Point{
-		X: offline.Deriveacc.Keypair.DPk.Pk.X,
-		Y: offline.Deriveacc.Keypair.DPk.Pk.Y,
+	is, err := newSimulationIssuer(rand.Reader)
+	if err != nil {
+		return nullifier.Record{}, err
 	}
-	assignment.PrivateKey = offline.Sk.Sk
-	assignment.PublicKey = twistededwards.Point{X: offline.Pk.Pk.X, Y: offline.Pk.Pk.Y}
-	assignment.Alpha = offline.Deriveacc.Keypair.Deriver
-	assignment.Randomness = offline.Deriveacc.R
-	var cpk [2]twistededwards.Point
-	cpk[0] = twistededwards.Point{X: offline.RegTk[0].X, Y: offline.RegTk[0].Y}
-	cpk[1] = twistededwards.Point{X: offline.RegTk[1].X, Y: offline.RegTk[1].Y}
-	assignment.ExpectedCPk = cpk
-	assignment.PublicKeyA = twistededwards.Point{X: offline.Apk.Pk.X, Y: offline.Apk.Pk.Y}
-	assignment.RandomnessA = offline.Ar
-	assignment.A = offline.A
-	assignment.ExpectedAux = twistededwards.Point{
-		X: offline.Aux.X,
-		Y: offline.Aux.Y,
+	offline := Offline{Issuer: is}
+	offline, err = offline.Execution(params, hashFunc, curveid, rand.Reader)
+	if err != nil {
+		return nullifier.Record{}, err
 	}
 
-	_sigpublicKey := offline.Sigpk.Bytes()
-	assignment.SigPublicKey.Assign(curveid, _sigpublicKey[:32])
-	assignment.Signature.Assign(curveid, offline.Signature)
-
-	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	publicWitness, err := witness.Public()
-	proof, err := groth16.Prove(r1cs, pk, witness)
-
-	err = groth16.Verify(proof, vk, publicWitness)
+	k, err := SetupKeys(store, CircuitNoRegulation)
 	if err != nil {
-		// invalid proof
+		return nullifier.Record{}, err
 	}
-}
-
-func T_offlineTxWithFreqlimitRegulation() {
-	curveid := ecctedwards.BN254
 
-	hashFunc := hash.MIMC_BN254
-	params, _ := twistededwards.GetCurveParams(curveid)
+	reg, err := registry(offline.Issuer)
+	if err != nil {
+		return nullifier.Record{}, err
+	}
 
-	var offline Offline
-	offline = offline.Execution(params, hashFunc, curveid)
+	settle := func(offline Offline) (nullifier.Record, error) {
+		proof, err := prove(k, offlineAssignment(offline, NoRegulation, curveid))
+		if err != nil {
+			return nullifier.Record{}, err
+		}
+		return Settle(nulls, reg, offline.Policy, testDate, NoRegulation, proof, offline.PublicInputs(NoRegulation), k.VK)
+	}
 
-	var circuit freqlimitRegulationCircuit
-	r1cs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &circuit)
+	first, err := settle(offline)
+	if err != nil {
+		return first, err
+	}
+	// the same spend again, under a fresh proof, is no double spend
+	if again, err := settle(offline); err != nil || again.TxID != first.TxID {
+		return first, fmt.Errorf("offlinetx: resubmitted spend recorded as %+v: %v", again, err)
+	}
 
-	pk, vk, err := groth16.Setup(r1cs)
+	priacc := PrimitiveAccount{
+		G0: offline.G0, G1: offline.G1, H: offline.H,
+		Tracesk: offline.Tracesk, Sk: offline.Sk, Pk: offline.Pk, Bal: offline.Bal,
+	}
+	offline.Deriveacc = offline.Deriveacc.DaccountGen(params, hashFunc, offline.Newseq, priacc, rand.Reader)
+	_, err = settle(offline)
+	return first, err
+}
 
-	var assignment freqlimitRegulationCircuit
+// offlineAssignment is the witness of offline proved in mode: the public
+// inputs of offline.PublicInputs(mode) and the secrets of the modules of mode.
+func offlineAssignment(offline Offline, mode RegulationMode, curveid ecctedwards.ID) *offlineCircuit {
+	assignment, err := offline.PublicInputs(mode).assignment(mode)
+	if err != nil {
+		// Execution and Pay always fill the public inputs of every mode
+		panic(err)
+	}
 
 	acc_c1 := offline.OldAcc[0]
 	acc_c2 := offline.OldAcc[1]
@@ -277,53 +259,30 @@ func T_offlineTxWithFreqlimitRegulation() {
 	assignment.TacSk = offline.Tracesk.Sk
 	assignment.Seq = offline.Oldseq
 	assignment.Seq1 = offline.Newseq
-	assignment.ExpectedDelta = offline.Delta
-
-	dacccipher := offline.Deriveacc.Acc
-	dacc_c1 := dacccipher[0]
-	dacc_c2 := dacccipher[1]
-	dacc := util.Account{
-		A: twistededwards.Point{X: dacc_c1.X, Y: dacc_c1.Y},
-		B: twistededwards.Point{X: dacc_c2.X, Y: dacc_c2.Y},
-	}
-	assignment.ExpectedDAcc = dacc
-	assignment.ExpectedDPublicKey = twistededwards.Point{
-		X: offline.Deriveacc.Keypair.DPk.Pk.X,
-		Y: offline.Deriveacc.Keypair.DPk.Pk.Y,
-	}
 	assignment.PrivateKey = offline.Sk.Sk
 	assignment.PublicKey = twistededwards.Point{X: offline.Pk.Pk.X, Y: offline.Pk.Pk.Y}
 	assignment.Alpha = offline.Deriveacc.Keypair.Deriver
 	assignment.Randomness = offline.Deriveacc.R
-	var cpk [2]twistededwards.Point
-	cpk[0] = twistededwards.Point{X: offline.RegTk[0].X, Y: offline.RegTk[0].Y}
-	cpk[1] = twistededwards.Point{X: offline.RegTk[1].X, Y: offline.RegTk[1].Y}
-	assignment.ExpectedCPk = cpk
-	assignment.PublicKeyA = twistededwards.Point{X: offline.Apk.Pk.X, Y: offline.Apk.Pk.Y}
-	assignment.RandomnessA = offline.Ar
-	assignment.A = offline.A
-	assignment.ExpectedAux = twistededwards.Point{
-		X: offline.Aux.X,
-		Y: offline.Aux.Y,
-	}
-	assignment.Date = offline.Date
-	assignment.Comment = twistededwards.Point{
-		X: offline.Comment.X,
-		Y: offline.Comment.Y,
-	}
-	assignment.Commentr = offline.Commentr
-
-	_sigpublicKey := offline.Sigpk.Bytes()
-	assignment.SigPublicKey.Assign(curveid, _sigpublicKey[:32])
+	assignment.Amount = offline.Amount
+	assignment.RandomnessP = offline.Paymentr
+	assignment.State = offline.Freq.Scalar()
+	assignment.Limit = offline.Tier.Limit()
+	assignment.Aux = twistededwards.Point{X: offline.Aux.X, Y: offline.Aux.Y}
 	assignment.Signature.Assign(curveid, offline.Signature)
-	assignment.DateSignature.Assign(curveid, offline.DateSignature)
 
-	witness, err := frontend.NewWitness(&assignment, ecc.BN254.ScalarField())
-	publicWitness, err := witness.Public()
-	proof, err := groth16.Prove(r1cs, pk, witness)
-
-	err = groth16.Verify(proof, vk, publicWitness)
-	if err != nil {
-		// invalid proof
+	for i := range assignment.Reg {
+		assignment.Reg[i].RandomnessA = offline.Ar
 	}
+	for i := range assignment.Holding {
+		assignment.Holding[i].A = offline.A
+	}
+	for i := range assignment.Date {
+		assignment.Date[i].Date = offline.Date
+		assignment.Date[i].DateSignature.Assign(curveid, offline.DateSignature)
+		assignment.Date[i].Commentr = offline.Commentr
+		assignment.Date[i].Period = offline.Freq.Period
+		assignment.Date[i].Counter = offline.Freq.Counter
+	}
+
+	return assignment
 }
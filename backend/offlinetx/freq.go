@@ -0,0 +1,46 @@
+package offlinetx
+
+import "math/big"
+
+// CounterBits is the width of the per-period counter.
+const CounterBits = 32
+
+// FreqState is the frequency state of an account, kept in its plaintext as
+// gc*(Period<<CounterBits + Counter): Counter offline spends were made in the
+// period starting at Period. A fresh account has the zero state.
+type FreqState struct {
+	Period  uint64
+	Counter uint32
+}
+
+// Scalar returns Period<<CounterBits + Counter.
+func (s FreqState) Scalar() *big.Int {
+	v := new(big.Int).SetUint64(s.Period)
+	v.Lsh(v, CounterBits)
+	return v.Add(v, new(big.Int).SetUint64(uint64(s.Counter)))
+}
+
+// Next is the state after one more spend in the period starting at start:
+// the counter goes on within a period and restarts when the period rolls over.
+func (s FreqState) Next(start uint64) FreqState {
+	if s.Period == start {
+		return FreqState{Period: start, Counter: s.Counter + 1}
+	}
+	return FreqState{Period: start, Counter: 1}
+}
+
+// FreqPolicy is the frequency limit of the regulator: at most MaxTxPerPeriod
+// offline spends in each period of Length seconds.
+type FreqPolicy struct {
+	Length         uint64
+	MaxTxPerPeriod uint64
+}
+
+// DefaultFreqPolicy allows ten offline spends a day.
+var DefaultFreqPolicy = FreqPolicy{Length: 24 * 60 * 60, MaxTxPerPeriod: 10}
+
+// Window returns the period [start, end] holding date, a Unix time.
+func (p FreqPolicy) Window(date uint64) (start, end uint64) {
+	start = date - date%p.Length
+	return start, start + p.Length - 1
+}
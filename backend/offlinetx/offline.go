@@ -2,17 +2,19 @@ package offlinetx
 
 import (
 	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/generator"
+	"Asyn_CBDC/backend/issuer"
 	"Asyn_CBDC/backend/util"
-	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
 	"math/big"
-	mathrand "math/rand"
-	"time"
 
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
 	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
 	"github.com/consensys/gnark-crypto/hash"
 	"github.com/consensys/gnark-crypto/signature"
-	"github.com/consensys/gnark-crypto/signature/eddsa"
 	"github.com/consensys/gnark/std/algebra/native/twistededwards"
 )
 
@@ -28,6 +30,7 @@ type PrimitiveAccount struct {
 	Pk      util.Publickey
 	R       *big.Int
 	Acc     []curve.PointAffine
+	Freq    FreqState
 }
 
 type DeriveKeypair struct {
@@ -45,6 +48,7 @@ type DeriveAccount struct {
 	Keypair DeriveKeypair
 	R       *big.Int
 	Acc     []curve.PointAffine
+	Freq    FreqState
 }
 
 type Offline struct {
@@ -63,10 +67,10 @@ type Offline struct {
 	OldAcc        []curve.PointAffine
 	Deriveacc     DeriveAccount
 	Ar            *big.Int
-	Apk           util.Publickey
+	Apk           util.Publickey // regulator key, a fresh one when unset
 	CipherPk      []curve.PointAffine
 	RegTk         []curve.PointAffine
-	A             *big.Int
+	A             *big.Int // holding key, a fresh one when unset; the bank signs h*A
 	Aux           *curve.PointAffine
 	Date          *big.Int
 	DateSignature []byte
@@ -74,20 +78,122 @@ type Offline struct {
 	Commentr      *big.Int
 	CommentG      curve.PointAffine
 	CommentH      curve.PointAffine
+	Amount        *big.Int
+	Recipient     util.Publickey
+	Paymentr      *big.Int
+	Payment       []curve.PointAffine
+	Wallet        *Wallet        // sequence numbers, advanced; a new enrolled wallet when nil
+	Tier          Tier           // tier of the wallet, TierAnonymous when unset
+	Mode          RegulationMode // mode the transaction is proved in
+	Policy        FreqPolicy     // counts FreqLimit spends, DefaultFreqPolicy when unset
+	PeriodStart   uint64
+	PeriodEnd     uint64
+	Freq          FreqState // frequency state of the old account
+	NewFreq       FreqState
+	Expiry        *big.Int
+	Issuer        *issuer.Issuer // signs the account with a subkey of SimulationValidity; ErrNoIssuer when nil
+	Certificate   issuer.Certificate
 }
 
-func (o Offline) Execution(params *twistededwards.CurveParams, hash hash.Hash, curveid ecctedwards.ID) Offline {
-	//=========================primitive acc ==============================
-	modulus := params.Order
+// testDate is the date the bank signs in the test transactions, as Unix time,
+// and testValidity how long the bank's signature on the test account lasts.
+const (
+	testDate     = 1_760_000_000
+	testValidity = 30 * 24 * 60 * 60
+)
 
-	oldseq := new(big.Int).Sub(modulus, big.NewInt(3))
-	o.Oldseq = oldseq
+// SimulationValidity is the validity [notBefore, notAfter], Unix time, of an
+// issuer subkey able to sign the test accounts of Execution and Pay.
+func SimulationValidity() (notBefore, notAfter uint64) {
+	return testDate - testValidity, testDate + testValidity
+}
+
+// newSimulationIssuer returns an issuer with fresh keys drawn from rnd,
+// crypto/rand when rnd is nil, and one subkey of SimulationValidity.
+func newSimulationIssuer(rnd io.Reader) (*issuer.Issuer, error) {
+	is, err := issuer.New(rnd)
+	if err != nil {
+		return nil, err
+	}
+	notBefore, notAfter := SimulationValidity()
+	if _, err := is.Rotate(notBefore, notAfter, rnd); err != nil {
+		return nil, err
+	}
+	return is, nil
+}
+
+// registry returns a registry of the certificates of is, the one a verifier
+// pinning its root keeps.
+func registry(is *issuer.Issuer) (*issuer.Registry, error) {
+	reg := issuer.NewRegistry(is.Root())
+	for _, c := range is.Certificates() {
+		if err := reg.Add(c); err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+// ErrInsufficientBalance is returned by Pay when the amount exceeds the balance.
+var ErrInsufficientBalance = errors.New("offlinetx: amount exceeds balance")
+
+// ErrNoIssuer is returned by Execution and Pay when there is no issuer to sign
+// the account.
+var ErrNoIssuer = errors.New("offlinetx: no issuer to sign the account")
+
+// Execution runs an offline transaction for a fresh test account, made as the
+// fields of o ask. All keys and randomness are drawn from rnd, crypto/rand
+// when rnd is nil. Nothing is paid: the whole balance goes to the derived
+// account and the payment of 0 to the sender's own key.
+func (o Offline) Execution(params *twistededwards.CurveParams, hash hash.Hash, curveid ecctedwards.ID, rnd io.Reader) (Offline, error) {
+	return o.execute(params, hash, curveid, testBalance(), nil, util.Publickey{}, rnd)
+}
 
+// Pay runs an offline payment of amount to recipient for a fresh test account:
+// the derived account keeps the change and Payment encrypts amount to
+// recipient.
+func (o Offline) Pay(params *twistededwards.CurveParams, hash hash.Hash, curveid ecctedwards.ID, amount *big.Int, recipient util.Publickey, rnd io.Reader) (Offline, error) {
+	balance := testBalance()
+	if amount.Sign() < 0 || !amount.IsUint64() {
+		return o, fmt.Errorf("offlinetx: amount %s is not a 64-bit value", amount)
+	}
+	if amount.Cmp(&balance) > 0 {
+		return o, ErrInsufficientBalance
+	}
+	return o.execute(params, hash, curveid, balance, amount, recipient, rnd)
+}
+
+func testBalance() big.Int {
 	var balance big.Int
 	balance.SetString("200", 10)
+	return balance
+}
+
+// execute builds the transaction; amount nil pays 0 to the sender. amount
+// is not checked against balance, the change is taken mod r as the
+// circuit does.
+func (o Offline) execute(params *twistededwards.CurveParams, hash hash.Hash, curveid ecctedwards.ID, balance big.Int, amount *big.Int, recipient util.Publickey, rnd io.Reader) (Offline, error) {
+	//=========================primitive acc ==============================
+	modulus := params.Order
+
+	if o.Wallet == nil {
+		o.Wallet = NewEnrolledWallet()
+	}
+	if o.Issuer == nil {
+		return o, ErrNoIssuer
+	}
+	if o.Tier.Name == "" {
+		o.Tier = TierAnonymous
+	}
+	if o.Policy == (FreqPolicy{}) {
+		o.Policy = DefaultFreqPolicy
+	}
+	oldseq, newseq := o.Wallet.Next()
+	o.Oldseq = oldseq
 
 	var testacc PrimitiveAccount
-	testacc = testacc.GetAccount(params, hash, balance, oldseq)
+	testacc.Freq = o.Freq
+	testacc = testacc.GetAccount(params, hash, balance, oldseq, rnd)
 	o.Delta = testacc.Delta
 
 	//o.Tracepk = testacc.Tracepk
@@ -99,91 +205,97 @@ func (o Offline) Execution(params *twistededwards.CurveParams, hash hash.Hash, c
 	//=====================================================================
 
 	o.OldAcc = oldacc
-	//sign
-	_c1x := oldacc[0].X
-	//_c2x := acccipher.B.X
-	c1x := _c1x.Bytes()
-	//c2x := _c2x.Bytes()
-	var _msg []byte
-	_msg = append(_msg, c1x[:]...)
-	//var msg []byte
-	//msg = append(msg, c2x[:]...)
-	sigprivateKey, _ := eddsa.New(curveid, rand.Reader)
-	sigpublicKey := sigprivateKey.Public()
-	o.Sigpk = sigpublicKey
+	//the holding key enrolled with the wallet
+	if o.A == nil {
+		o.A = util.RandomScalar(rnd, modulus)
+	}
+	o.Aux = new(curve.PointAffine).ScalarMultiplication(&generator.H, o.A)
+
+	//sign mimc(A.X,A.Y,B.X,B.Y,seq,expiry,limit,aux.X,aux.Y)
+	o.Expiry = new(big.Int).SetUint64(testDate + testValidity)
+	_msg := util.Account_digest(oldacc, oldseq, o.Expiry, o.Tier.Limit(), o.Aux, hash)
+	sigprivateKey, cert, err := o.Issuer.Subkey(o.Expiry.Uint64())
+	if err != nil {
+		return o, err
+	}
+	o.Certificate = cert
+	o.Sigpk = sigprivateKey.Public()
 	signature := util.Sign(sigprivateKey, _msg, hash)
 	o.Signature = signature
 
-	date := new(big.Int).Sub(modulus, big.NewInt(int64(10)))
-	o.Date = date
-	var datemsg []byte
-	datemsg = append(datemsg, o.Date.Bytes()...)
-	datesignature := util.Sign(sigprivateKey, datemsg, hash)
+	o.Date = new(big.Int).SetUint64(testDate)
+	o.PeriodStart, o.PeriodEnd = o.Policy.Window(testDate)
+	o.NewFreq = o.Freq
+	if o.Mode == FreqLimit {
+		o.NewFreq = o.Freq.Next(o.PeriodStart)
+	}
+	datemsg := new(fr.Element).SetBigInt(o.Date).Bytes()
+	datesignature := util.Sign(sigprivateKey, datemsg[:], hash)
 	o.DateSignature = datesignature
 
 	//DAcc
-	newseq := new(big.Int).Sub(modulus, big.NewInt(4))
 	o.Newseq = newseq
+	paid := amount
+	if paid == nil {
+		paid = big.NewInt(0)
+	}
+	change := new(big.Int).Sub(&balance, paid)
+	change.Mod(change, fr.Modulus())
 	var Dacc DeriveAccount
-	Dacc = Dacc.DaccountGen(params, hash, newseq, testacc)
+	Dacc.Freq = o.NewFreq
+	Dacc = Dacc.ChangeGen(params, hash, newseq, testacc, *change, rnd)
 	o.Deriveacc = Dacc
-	o.Bal = Dacc.Bal
+	o.Bal = testacc.Bal
 
 	o.G0 = Dacc.G0
 	o.G1 = Dacc.G1
 	o.H = Dacc.H
 
 	//C_PKU
-	mathrand.Seed(time.Now().UnixNano())
-	randint := mathrand.Intn(1100) + 10
-	_aprivatekey := new(big.Int).Sub(modulus, big.NewInt(int64(randint)))
-	var _ah curve.PointAffine
-	_ah.X.SetBigInt(params.Base[0])
-	_ah.Y.SetBigInt(params.Base[1])
-
-	_apublickey := new(curve.PointAffine).ScalarMultiplication(&_ah, _aprivatekey)
-	o.Apk = util.Publickey{Pk: *_apublickey}
-
-	mathrand.Seed(time.Now().UnixNano())
-	randint = mathrand.Intn(1100) + 10
-	ar := new(big.Int).Sub(modulus, big.NewInt(int64(randint)))
+	_ah := generator.H
+	if o.Apk.Pk == (curve.PointAffine{}) {
+		_aprivatekey := util.RandomScalar(rnd, modulus)
+		_apublickey := new(curve.PointAffine).ScalarMultiplication(&_ah, _aprivatekey)
+		o.Apk = util.Publickey{Pk: *_apublickey}
+	}
+
+	ar := util.RandomScalar(rnd, modulus)
 	o.Ar = ar
 	//_cipherTK := o.Apk.Encrypt(&testacc.Tracepk.Pk, ar, _ah)
 	_cipherPK := o.Apk.Encrypt(&testacc.Pk.Pk, ar, _ah)
 	o.CipherPk = _cipherPK
-	mathrand.Seed(time.Now().UnixNano())
-	randint = mathrand.Intn(1100) + 10
-	a := new(big.Int).Sub(modulus, big.NewInt(int64(randint)))
-	o.A = a
-	regTK := util.Regulation_PK(_cipherPK, a)
+	regTK := util.Regulation_PK(_cipherPK, o.A)
 	o.RegTk = regTK
-	o.Aux = new(curve.PointAffine).ScalarMultiplication(&_ah, a)
 
-	mathrand.Seed(time.Now().UnixNano())
-	randint = mathrand.Intn(1100) + 10
-	commr := new(big.Int).Sub(modulus, big.NewInt(int64(randint)))
+	commr := util.RandomScalar(rnd, modulus)
 	o.Commentr = commr
-	o.CommentG.X.SetBigInt(params.Base[0])
-	o.CommentG.Y.SetBigInt(params.Base[1])
-	o.CommentH.X.SetBigInt(params.Base[0])
-	o.CommentH.Y.SetBigInt(params.Base[1])
+	o.CommentG = generator.DateG
+	o.CommentH = generator.DateH
 	o.Comment = util.Pedersen_date(&o.CommentG, &o.CommentH, o.Date, o.Commentr)
-	return o
+
+	//payment
+	if amount == nil {
+		amount = big.NewInt(0)
+		recipient = testacc.Pk
+	}
+	o.Amount = amount
+	o.Recipient = recipient
+	o.Paymentr = util.RandomScalar(rnd, modulus)
+	g0paid := new(curve.PointAffine).ScalarMultiplication(&o.G0, amount)
+	o.Payment = recipient.Encrypt(g0paid, o.Paymentr, o.H)
+	return o, nil
 }
 
-func (t PrimitiveAccount) GetAccount(params *twistededwards.CurveParams, hashFunc hash.Hash, balance big.Int, seq *big.Int) PrimitiveAccount {
+func (t PrimitiveAccount) GetAccount(params *twistededwards.CurveParams, hashFunc hash.Hash, balance big.Int, seq *big.Int, rnd io.Reader) PrimitiveAccount {
 	t.Bal = balance
 
 	var enroll enroll.Enroll
-	enroll = enroll.Init(params, hashFunc)
+	enroll = enroll.Init(params, hashFunc, rnd)
 
 	t.Tracesk = enroll.Tracesk
 	t.Tracepk = enroll.Tracepk
 
-	_tacSk := t.Tracesk.Sk
-	_data3 := _tacSk.Bytes()
-	data3 := append(_data3, seq.Bytes()...)
-	delta_3 := util.Calculate_delta(data3, hashFunc)
+	delta_3 := util.Calculate_delta(t.Tracesk.Sk, seq, hashFunc)
 
 	t.Delta = delta_3
 	t.Bal = balance
@@ -194,6 +306,7 @@ func (t PrimitiveAccount) GetAccount(params *twistededwards.CurveParams, hashFun
 
 	plaintext := new(curve.PointAffine).Add(
 		new(curve.PointAffine).ScalarMultiplication(&_g1, t.Delta), new(curve.PointAffine).ScalarMultiplication(&_g0, &balance))
+	plaintext.Add(plaintext, freqPoint(t.Freq))
 
 	t.H = enroll.H
 	t.Sk = enroll.Sk
@@ -211,10 +324,11 @@ func (t PrimitiveAccount) GetAccount(params *twistededwards.CurveParams, hashFun
 	return t
 }
 
-func (d DeriveKeypair) DkeypairGen(order *big.Int, pk util.Publickey, sk util.Privatekey) DeriveKeypair {
-	mathrand.Seed(time.Now().UnixNano())
-	randint := mathrand.Intn(1100) + 10
-	d.Deriver = new(big.Int).Sub(order, big.NewInt(int64(randint)))
+// DkeypairGen derives the key pair of the account at seq from the wallet's
+// key: dsk=alpha*sk, dpk=alpha*pk with alpha=util.Derive_alpha(sk,seq), so
+// that sk alone recovers it.
+func (d DeriveKeypair) DkeypairGen(order *big.Int, pk util.Publickey, sk util.Privatekey, seq *big.Int, hashFunc hash.Hash) DeriveKeypair {
+	d.Deriver = util.Derive_alpha(sk.Sk, seq, order, hashFunc)
 
 	dsk := new(big.Int).Mul(d.Deriver, sk.Sk)
 	d.DSk = util.Privatekey{Sk: dsk}
@@ -225,27 +339,31 @@ func (d DeriveKeypair) DkeypairGen(order *big.Int, pk util.Publickey, sk util.Pr
 	return d
 }
 
-func (d DeriveAccount) DaccountGen(params *twistededwards.CurveParams, hashFunc hash.Hash, seq *big.Int, priacc PrimitiveAccount) DeriveAccount {
-	_data4 := priacc.Tracesk.Sk.Bytes()
-	data4 := append(_data4, seq.Bytes()...)
-	delta_4 := util.Calculate_delta(data4, hashFunc)
+func (d DeriveAccount) DaccountGen(params *twistededwards.CurveParams, hashFunc hash.Hash, seq *big.Int, priacc PrimitiveAccount, rnd io.Reader) DeriveAccount {
+	d.Freq = priacc.Freq
+	return d.ChangeGen(params, hashFunc, seq, priacc, priacc.Bal, rnd)
+}
+
+// ChangeGen derives the account holding change, what is left of priacc after a
+// payment, with the frequency state d.Freq.
+func (d DeriveAccount) ChangeGen(params *twistededwards.CurveParams, hashFunc hash.Hash, seq *big.Int, priacc PrimitiveAccount, change big.Int, rnd io.Reader) DeriveAccount {
+	delta_4 := util.Calculate_delta(priacc.Tracesk.Sk, seq, hashFunc)
 
 	d.Delta = delta_4
 
 	var derivekey DeriveKeypair
-	derivekey = derivekey.DkeypairGen(params.Order, priacc.Pk, priacc.Sk)
+	derivekey = derivekey.DkeypairGen(params.Order, priacc.Pk, priacc.Sk, seq, hashFunc)
 
 	d.Keypair = derivekey
 
-	d.Bal = priacc.Bal
+	d.Bal = change
 
-	mathrand.Seed(time.Now().UnixNano())
-	randint := mathrand.Intn(1100) + 10
-	dr := new(big.Int).Sub(params.Order, big.NewInt(int64(randint)))
+	dr := util.RandomScalar(rnd, params.Order)
 	d.R = dr
 
-	g0bal := priacc.Sk.Decryptacc(priacc.Acc, new(curve.PointAffine).ScalarMultiplication(&priacc.G1, priacc.Delta))
-	dplaintext := new(curve.PointAffine).Add(g0bal, new(curve.PointAffine).ScalarMultiplication(&priacc.G1, delta_4))
+	g0change := new(curve.PointAffine).ScalarMultiplication(&priacc.G0, &change)
+	dplaintext := new(curve.PointAffine).Add(g0change, new(curve.PointAffine).ScalarMultiplication(&priacc.G1, delta_4))
+	dplaintext.Add(dplaintext, freqPoint(d.Freq))
 	dacccipher := derivekey.DPk.Encrypt(dplaintext, dr, priacc.H)
 	d.Acc = dacccipher
 
@@ -254,3 +372,8 @@ func (d DeriveAccount) DaccountGen(params *twistededwards.CurveParams, hashFunc
 	d.H = priacc.H
 	return d
 }
+
+// freqPoint is gc*state, the frequency state in an account plaintext.
+func freqPoint(s FreqState) *curve.PointAffine {
+	return new(curve.PointAffine).ScalarMultiplication(&generator.Gc, s.Scalar())
+}
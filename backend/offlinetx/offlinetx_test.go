@@ -1,18 +1,688 @@
 package offlinetx
 
-import "testing"
+import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/generator"
+	"Asyn_CBDC/backend/issuer"
+	"Asyn_CBDC/backend/keys"
+	"Asyn_CBDC/backend/nullifier"
+	"Asyn_CBDC/backend/util"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark-crypto/signature/eddsa"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	"github.com/consensys/gnark/test"
+)
+
+// testStore is shared by the tests so that each circuit is set up once.
+var testStore keys.Store
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "offlinetx-keys")
+	if err != nil {
+		panic(err)
+	}
+	testStore = keys.NewStore(dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// testIssuer signs the test accounts. Its keys come from a fixed seed so that
+// every test transaction has the same SigPublicKey.
+var testIssuer = sync.OnceValue(func() *issuer.Issuer {
+	is, err := newSimulationIssuer(util.NewSeededReader([]byte("offlinetx test issuer")))
+	if err != nil {
+		panic(err)
+	}
+	return is
+})
+
+func testRegistry(t *testing.T) *issuer.Registry {
+	t.Helper()
+	reg, err := registry(testIssuer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
 
 func TestNoRegulation(t *testing.T) {
-	T_offlineTxWithNoRegulation() //33412
+	if err := T_offlineTxWithNoRegulation(testStore); err != nil {
+		t.Fatal(err)
+	}
 }
 func TestNoLimitRegulation(t *testing.T) {
-	T_offlineTxWithNoLimitRegulation() //39495
+	if err := T_offlineTxWithNoLimitRegulation(testStore); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestWithHoldingLimitRegulation(t *testing.T) {
-	T_offlineTxWithHoldinglimitRegulation() //48868
+	if err := T_offlineTxWithHoldinglimitRegulation(testStore); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestWithFreqLimitRegulation(t *testing.T) {
-	T_offlineTxWithFreqlimitRegulation() //61434
+	if err := T_offlineTxWithFreqlimitRegulation(testStore); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPayment(t *testing.T) {
+	if err := T_offlinePayment(testStore); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDoubleSpend(t *testing.T) {
+	nulls := nullifier.NewMemoryStore()
+	first, err := T_offlineDoubleSpend(testStore, nulls)
+	var ds *nullifier.DoubleSpendError
+	if !errors.As(err, &ds) {
+		t.Fatalf("second spend: got %v, want a double spend", err)
+	}
+	if ds.First != first || ds.Second.TxID == first.TxID || ds.Delta.Cmp(first.Delta) != 0 {
+		t.Fatalf("conflict %+v does not name the first spend %+v", ds, first)
+	}
+}
+
+func TestSettleRejectsUnknownIssuer(t *testing.T) {
+	curveid := ecctedwards.BN254
+	stranger, err := newSimulationIssuer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := Offline{Issuer: stranger}
+	o = execution(t, o, util.NewSeededReader([]byte(t.Name())))
+
+	k, err := SetupKeys(testStore, CircuitNoRegulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := prove(k, offlineAssignment(o, NoRegulation, curveid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nulls := nullifier.NewMemoryStore()
+	pub := o.PublicInputs(NoRegulation)
+	if _, err := Settle(nulls, testRegistry(t), DefaultFreqPolicy, testDate, NoRegulation, proof, pub, k.VK); !errors.Is(err, issuer.ErrUnknownKey) {
+		t.Errorf("proof of another issuer: %v", err)
+	}
+	if r, spent, err := nulls.Lookup(o.Delta); err != nil || spent {
+		t.Errorf("rejected proof recorded as %+v: %v", r, err)
+	}
+}
+
+// twin is (x,-y), the point an X-only comparison cannot tell from (x,y)
+func twin(p twistededwards.Point) twistededwards.Point {
+	y := p.Y.(fr.Element)
+	y.Neg(&y)
+	return twistededwards.Point{X: p.X, Y: y}
+}
+
+// neg is -(x,y) = (-x,y)
+func neg(p twistededwards.Point) twistededwards.Point {
+	x := p.X.(fr.Element)
+	x.Neg(&x)
+	return twistededwards.Point{X: x, Y: p.Y}
+}
+
+// execution runs o.Execution on BN254, failing t on an error.
+func execution(t *testing.T, o Offline, rnd io.Reader) Offline {
+	t.Helper()
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	o, err := o.Execution(params, hash.MIMC_BN254, ecctedwards.BN254, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func testOffline(t *testing.T, mode RegulationMode) Offline {
+	o := Offline{Mode: mode, Issuer: testIssuer()}
+	return execution(t, o, util.NewSeededReader([]byte(t.Name())))
+}
+
+func testPayment(t *testing.T, amount int64) Offline {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	rnd := util.NewSeededReader([]byte(t.Name()))
+	var recipient enroll.Enroll
+	recipient = recipient.Init(params, hash.MIMC_BN254, rnd)
+	o := Offline{Issuer: testIssuer()}
+	balance := testBalance()
+	o, err := o.execute(params, hash.MIMC_BN254, ecctedwards.BN254, balance, big.NewInt(amount), recipient.Pk, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
+
+func assertRejects(t *testing.T, what string, circuit, assignment frontend.Circuit) {
+	t.Helper()
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err == nil {
+		t.Errorf("%s: tampered witness accepted", what)
+	}
+}
+
+func TestModesSolve(t *testing.T) {
+	for mode := NoRegulation; mode <= FreqLimit; mode++ {
+		a := offlineAssignment(testOffline(t, mode), mode, ecctedwards.BN254)
+		if err := test.IsSolved(newOfflineCircuit(mode), a, ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("%s: %v", mode, err)
+		}
+	}
+}
+
+func TestRejectsNegatedPoints(t *testing.T) {
+	offline := make(map[RegulationMode]Offline)
+	for mode := NoRegulation; mode <= FreqLimit; mode++ {
+		offline[mode] = testOffline(t, mode)
+	}
+	curveid := ecctedwards.BN254
+
+	for _, tamper := range []struct {
+		name string
+		f    func(twistededwards.Point) twistededwards.Point
+	}{{"twin", twin}, {"neg", neg}} {
+		for _, c := range []struct {
+			name string
+			mode RegulationMode
+			f    func(a *offlineCircuit)
+		}{
+			{"DAcc.A", NoRegulation, func(a *offlineCircuit) { a.ExpectedDAcc.A = tamper.f(a.ExpectedDAcc.A) }},
+			{"DAcc.B", NoRegulation, func(a *offlineCircuit) { a.ExpectedDAcc.B = tamper.f(a.ExpectedDAcc.B) }},
+			{"DPublicKey", NoRegulation, func(a *offlineCircuit) { a.ExpectedDPublicKey = tamper.f(a.ExpectedDPublicKey) }},
+			{"Payment.A", NoRegulation, func(a *offlineCircuit) { a.ExpectedPayment.A = tamper.f(a.ExpectedPayment.A) }},
+			{"Payment.B", NoRegulation, func(a *offlineCircuit) { a.ExpectedPayment.B = tamper.f(a.ExpectedPayment.B) }},
+			{"CPk[0]", NoLimitRegulation, func(a *offlineCircuit) { a.Reg[0].ExpectedCPk[0] = tamper.f(a.Reg[0].ExpectedCPk[0]) }},
+			{"CPk[1]", NoLimitRegulation, func(a *offlineCircuit) { a.Reg[0].ExpectedCPk[1] = tamper.f(a.Reg[0].ExpectedCPk[1]) }},
+			{"Aux", HoldingLimit, func(a *offlineCircuit) { a.Holding[0].ExpectedAux = tamper.f(a.Holding[0].ExpectedAux) }},
+			{"Comment", FreqLimit, func(a *offlineCircuit) { a.Date[0].Comment = tamper.f(a.Date[0].Comment) }},
+		} {
+			a := offlineAssignment(offline[c.mode], c.mode, curveid)
+			c.f(a)
+			assertRejects(t, tamper.name+" "+c.name, newOfflineCircuit(c.mode), a)
+		}
+	}
+}
+
+func TestPaymentConservesBalance(t *testing.T) {
+	curveid := ecctedwards.BN254
+	field := ecc.BN254.ScalarField()
+
+	for _, amount := range []int64{0, 50, 200} {
+		a := offlineAssignment(testPayment(t, amount), NoRegulation, curveid)
+		if err := test.IsSolved(newOfflineCircuit(NoRegulation), a, field); err != nil {
+			t.Fatalf("amount %d: %v", amount, err)
+		}
+	}
+
+	// the change wraps around mod r, only the range check stops it
+	a := offlineAssignment(testPayment(t, 201), NoRegulation, curveid)
+	assertRejects(t, "overspend", newOfflineCircuit(NoRegulation), a)
+
+	// paying 50 while claiming 60 breaks old = paid + change
+	a = offlineAssignment(testPayment(t, 50), NoRegulation, curveid)
+	a.Amount = 60
+	assertRejects(t, "wrong amount", newOfflineCircuit(NoRegulation), a)
+
+	a = offlineAssignment(testPayment(t, 50), NoRegulation, curveid)
+	a.Bal = 250
+	assertRejects(t, "inflated balance", newOfflineCircuit(NoRegulation), a)
+
+	params, _ := twistededwards.GetCurveParams(curveid)
+	var o Offline
+	if _, err := o.Pay(params, hash.MIMC_BN254, curveid, big.NewInt(201), util.Publickey{}, nil); err != ErrInsufficientBalance {
+		t.Errorf("Pay over balance: got %v, want ErrInsufficientBalance", err)
+	}
+	if _, err := o.Pay(params, hash.MIMC_BN254, curveid, big.NewInt(-1), util.Publickey{}, nil); err == nil {
+		t.Error("Pay of a negative amount accepted")
+	}
+}
+
+func TestWalletSeq(t *testing.T) {
+	rnd := util.NewSeededReader([]byte(t.Name()))
+	o := Offline{Wallet: NewEnrolledWallet(), Issuer: testIssuer()}
+
+	first := execution(t, o, rnd)
+	second := execution(t, o, rnd)
+	if first.Oldseq.Int64() != enroll.FirstSeq || first.Newseq.Int64() != enroll.FirstSeq+1 {
+		t.Fatalf("first spend: seq %v -> %v", first.Oldseq, first.Newseq)
+	}
+	if second.Oldseq.Cmp(first.Newseq) != 0 || o.Wallet.Seq().Int64() != enroll.FirstSeq+2 {
+		t.Fatalf("second spend: seq %v -> %v, wallet at %v", second.Oldseq, second.Newseq, o.Wallet.Seq())
+	}
+}
+
+func TestRejectsSeqSkip(t *testing.T) {
+	o := testOffline(t, NoRegulation)
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	rnd := util.NewSeededReader([]byte(t.Name()))
+	priacc := PrimitiveAccount{
+		G0: o.G0, G1: o.G1, H: o.H,
+		Tracesk: o.Tracesk, Sk: o.Sk, Pk: o.Pk, Bal: o.Bal,
+	}
+
+	// a derived account at any seq but Seq+1 is rejected, even when it is
+	// otherwise well formed
+	for _, seq1 := range []*big.Int{
+		o.Oldseq,
+		new(big.Int).Add(o.Oldseq, big.NewInt(2)),
+		new(big.Int).Sub(o.Oldseq, big.NewInt(1)),
+	} {
+		p := o
+		p.Newseq = seq1
+		p.Deriveacc = DeriveAccount{}.DaccountGen(params, hash.MIMC_BN254, seq1, priacc, rnd)
+		a := offlineAssignment(p, NoRegulation, ecctedwards.BN254)
+		assertRejects(t, "seq1="+seq1.String(), newOfflineCircuit(NoRegulation), a)
+	}
+}
+
+func TestSpendRecordDelta(t *testing.T) {
+	o := testPayment(t, 50)
+	var txid string
+	for mode := NoRegulation; mode <= FreqLimit; mode++ {
+		w, err := frontend.NewWitness(offlineAssignment(o, mode, ecctedwards.BN254), ecc.BN254.ScalarField(), frontend.PublicOnly())
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := spendRecord(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Delta.Cmp(o.Delta) != 0 {
+			t.Errorf("%s: recorded %v, want delta %v", mode, r.Delta, o.Delta)
+		}
+		// the spend is named by delta, DAcc and Payment, not by the mode
+		if mode == NoRegulation {
+			txid = r.TxID
+		} else if r.TxID != txid {
+			t.Errorf("%s: tx %s, want %s", mode, r.TxID, txid)
+		}
+	}
+}
+
+func TestVerifyOfflineRejects(t *testing.T) {
+	curveid := ecctedwards.BN254
+	o := Offline{Issuer: testIssuer()}
+	o = execution(t, o, nil)
+
+	k, err := SetupKeys(testStore, CircuitNoRegulation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := prove(k, offlineAssignment(o, NoRegulation, curveid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyOffline(NoRegulation, DefaultFreqPolicy, testDate, proof, o.PublicInputs(NoRegulation), k.VK); err != nil {
+		t.Fatal(err)
+	}
+
+	offCurve := o.Deriveacc.Keypair.DPk.Pk
+	offCurve.X.SetOne()
+	other := testOffline(t, NoRegulation)
+
+	for _, c := range []struct {
+		name string
+		mode RegulationMode
+		pub  func(PublicInputs) PublicInputs
+	}{
+		{"unknown mode", RegulationMode(7), nil},
+		{"inputs of another mode", NoRegulation, func(p PublicInputs) PublicInputs { return o.PublicInputs(NoLimitRegulation) }},
+		{"key of another mode", FreqLimit, func(p PublicInputs) PublicInputs { return o.PublicInputs(FreqLimit) }},
+		{"holding limit without the module", NoRegulation, func(p PublicInputs) PublicInputs { p.HoldingLimit = big.NewInt(1); return p }},
+		{"missing delta", NoRegulation, func(p PublicInputs) PublicInputs { p.Delta = nil; return p }},
+		{"delta out of field", NoRegulation, func(p PublicInputs) PublicInputs { p.Delta = fr.Modulus(); return p }},
+		{"short account", NoRegulation, func(p PublicInputs) PublicInputs { p.DAcc = p.DAcc[:1]; return p }},
+		{"point off curve", NoRegulation, func(p PublicInputs) PublicInputs { p.DPublicKey = &offCurve; return p }},
+		{"missing signature key", NoRegulation, func(p PublicInputs) PublicInputs { p.SigPublicKey = nil; return p }},
+		{"missing expiry", NoRegulation, func(p PublicInputs) PublicInputs { p.Expiry = nil; return p }},
+		{"other expiry", NoRegulation, func(p PublicInputs) PublicInputs { p.Expiry = new(big.Int).Add(p.Expiry, big.NewInt(1)); return p }},
+		{"other delta", NoRegulation, func(p PublicInputs) PublicInputs { p.Delta = other.Delta; return p }},
+		{"other account", NoRegulation, func(p PublicInputs) PublicInputs { p.DAcc = other.Deriveacc.Acc; return p }},
+	} {
+		pub := o.PublicInputs(c.mode)
+		if c.pub != nil {
+			pub = c.pub(o.PublicInputs(NoRegulation))
+		}
+		if err := VerifyOffline(c.mode, DefaultFreqPolicy, testDate, proof, pub, k.VK); err == nil {
+			t.Errorf("%s: accepted", c.name)
+		}
+	}
+	err = VerifyOffline(NoRegulation, DefaultFreqPolicy, testDate+testValidity+1, proof, o.PublicInputs(NoRegulation), k.VK)
+	if !errors.Is(err, issuer.ErrExpired) {
+		t.Errorf("expired account: %v", err)
+	}
+
+	pub := o.PublicInputs(NoRegulation)
+	if err := VerifyIssued(testRegistry(t), DefaultFreqPolicy, testDate, NoRegulation, proof, pub, k.VK); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyIssued(testRegistry(t), DefaultFreqPolicy, testDate+testValidity+1, NoRegulation, proof, pub, k.VK); !errors.Is(err, issuer.ErrExpired) {
+		t.Errorf("expired account: %v", err)
+	}
+	stranger, err := issuer.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyIssued(issuer.NewRegistry(stranger.Root()), DefaultFreqPolicy, testDate, NoRegulation, proof, pub, k.VK); !errors.Is(err, issuer.ErrUnknownKey) {
+		t.Errorf("key of another issuer: %v", err)
+	}
+}
+
+func TestVerifyFreqPolicy(t *testing.T) {
+	curveid := ecctedwards.BN254
+	k, err := SetupKeys(testStore, CircuitFreqLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name   string
+		policy FreqPolicy
+	}{
+		{"misaligned window", FreqPolicy{Length: 1, MaxTxPerPeriod: DefaultFreqPolicy.MaxTxPerPeriod}},
+		{"longer window", FreqPolicy{Length: 2 * DefaultFreqPolicy.Length, MaxTxPerPeriod: DefaultFreqPolicy.MaxTxPerPeriod}},
+		{"forged maximum", FreqPolicy{Length: DefaultFreqPolicy.Length, MaxTxPerPeriod: 1<<CounterBits - 1}},
+	} {
+		o := Offline{Mode: FreqLimit, Policy: c.policy, Issuer: testIssuer()}
+		o = execution(t, o, util.NewSeededReader([]byte(t.Name()+c.name)))
+		proof, err := prove(k, offlineAssignment(o, FreqLimit, curveid))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := o.PublicInputs(FreqLimit)
+		if err := VerifyOffline(FreqLimit, c.policy, testDate, proof, pub, k.VK); err != nil {
+			t.Fatalf("%s: under its own policy: %v", c.name, err)
+		}
+		if err := VerifyOffline(FreqLimit, DefaultFreqPolicy, testDate, proof, pub, k.VK); !errors.Is(err, ErrFreqPolicy) {
+			t.Errorf("%s: %v", c.name, err)
+		}
+		if err := VerifyIssued(testRegistry(t), DefaultFreqPolicy, testDate, FreqLimit, proof, pub, k.VK); !errors.Is(err, ErrFreqPolicy) {
+			t.Errorf("%s: issued: %v", c.name, err)
+		}
+	}
+
+	// a proof of today's period settles tomorrow, but not yesterday
+	o := Offline{Mode: FreqLimit, Issuer: testIssuer()}
+	o = execution(t, o, util.NewSeededReader([]byte(t.Name())))
+	proof, err := prove(k, offlineAssignment(o, FreqLimit, curveid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyOffline(FreqLimit, DefaultFreqPolicy, testDate+DefaultFreqPolicy.Length, proof, o.PublicInputs(FreqLimit), k.VK); err != nil {
+		t.Errorf("late settlement: %v", err)
+	}
+	err = VerifyOffline(FreqLimit, DefaultFreqPolicy, testDate-DefaultFreqPolicy.Length, proof, o.PublicInputs(FreqLimit), k.VK)
+	if !errors.Is(err, ErrFreqPolicy) {
+		t.Errorf("future period: %v", err)
+	}
+	if err := VerifyOffline(FreqLimit, FreqPolicy{}, testDate, proof, o.PublicInputs(FreqLimit), k.VK); !errors.Is(err, ErrFreqPolicy) {
+		t.Errorf("no policy: %v", err)
+	}
+}
+
+func TestIssuerPinned(t *testing.T) {
+	a, b := testOffline(t, NoRegulation), testOffline(t, FreqLimit)
+	if !a.Sigpk.Equal(b.Sigpk) {
+		t.Error("test accounts signed by different keys")
+	}
+	if _, err := testRegistry(t).Resolve(a.Sigpk, a.Expiry.Uint64(), testDate); err != nil {
+		t.Error(err)
+	}
+
+	// an issuer whose current subkey lapses before the account expires
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	is, err := issuer.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := is.Rotate(testDate, testDate+testValidity-1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (Offline{}).Pay(params, hash.MIMC_BN254, ecctedwards.BN254, big.NewInt(1), a.Pk, nil); !errors.Is(err, ErrNoIssuer) {
+		t.Errorf("no issuer: %v", err)
+	}
+	if _, err := (Offline{}).Execution(params, hash.MIMC_BN254, ecctedwards.BN254, nil); !errors.Is(err, ErrNoIssuer) {
+		t.Errorf("execution without issuer: %v", err)
+	}
+	o := Offline{Issuer: is}
+	if _, err := o.Pay(params, hash.MIMC_BN254, ecctedwards.BN254, big.NewInt(1), a.Pk, nil); !errors.Is(err, issuer.ErrBeyondValidity) {
+		t.Errorf("lapsed subkey: %v", err)
+	}
+}
+
+func TestHoldingLimit(t *testing.T) {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	curveid := ecctedwards.BN254
+	field := ecc.BN254.ScalarField()
+	rnd := util.NewSeededReader([]byte(t.Name()))
+	var recipient enroll.Enroll
+	recipient = recipient.Init(params, hash.MIMC_BN254, rnd)
+
+	pay := func(mode RegulationMode, limit uint64, amount int64) Offline {
+		o := Offline{Mode: mode, Tier: Tier{Name: "test", HoldingLimit: limit}, Issuer: testIssuer()}
+		o, err := o.execute(params, hash.MIMC_BN254, curveid, testBalance(), big.NewInt(amount), recipient.Pk, rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+
+	for _, c := range []struct {
+		limit  uint64
+		amount int64
+		ok     bool
+	}{
+		{150, 50, true},  // change 150 = limit
+		{150, 49, false}, // change 151
+		{0, 200, true},   // nothing left
+		{0, 199, false},
+		{TierAnonymous.HoldingLimit, 0, true},
+	} {
+		for _, mode := range []RegulationMode{HoldingLimit, FreqLimit} {
+			o := pay(mode, c.limit, c.amount)
+			err := test.IsSolved(newOfflineCircuit(mode), offlineAssignment(o, mode, curveid), field)
+			if (err == nil) != c.ok {
+				t.Errorf("%s: limit %d, change %d: solved %v, want %v", mode, c.limit, 200-c.amount, err == nil, c.ok)
+			}
+		}
+		// the modes without a limit do not check it
+		o := pay(NoLimitRegulation, c.limit, c.amount)
+		if err := test.IsSolved(newOfflineCircuit(NoLimitRegulation), offlineAssignment(o, NoLimitRegulation, curveid), field); err != nil {
+			t.Errorf("nolimit-regulation, change %d: %v", 200-c.amount, err)
+		}
+	}
+}
+
+func TestRejectsHigherTier(t *testing.T) {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	curveid := ecctedwards.BN254
+	rnd := util.NewSeededReader([]byte(t.Name()))
+	var recipient enroll.Enroll
+	recipient = recipient.Init(params, hash.MIMC_BN254, rnd)
+
+	// a wallet allowed 100 keeps 150 by claiming the verified tier
+	for _, mode := range []RegulationMode{HoldingLimit, FreqLimit} {
+		o := Offline{Mode: mode, Tier: Tier{Name: "test", HoldingLimit: 100}, Issuer: testIssuer()}
+		o, err := o.execute(params, hash.MIMC_BN254, curveid, testBalance(), big.NewInt(50), recipient.Pk, rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := offlineAssignment(o, mode, curveid)
+		a.Holding[0].HoldingLimit = TierVerified.Limit()
+		assertRejects(t, mode.String()+" public limit", newOfflineCircuit(mode), a)
+		a.Limit = TierVerified.Limit()
+		assertRejects(t, mode.String()+" signed limit", newOfflineCircuit(mode), a)
+	}
+}
+
+func TestRejectsFreshHoldingKey(t *testing.T) {
+	curveid := ecctedwards.BN254
+	params, _ := twistededwards.GetCurveParams(curveid)
+	rnd := util.NewSeededReader([]byte(t.Name()))
+
+	// the sender swaps the enrolled holding key for one no registry knows
+	for _, mode := range []RegulationMode{HoldingLimit, FreqLimit} {
+		o := testOffline(t, mode)
+		signed := *o.Aux
+		o.A = util.RandomScalar(rnd, params.Order)
+		o.RegTk = util.Regulation_PK(o.CipherPk, o.A)
+		o.Aux = new(curve.PointAffine).ScalarMultiplication(&generator.H, o.A)
+		a := offlineAssignment(o, mode, curveid)
+		assertRejects(t, mode.String()+" fresh aux", newOfflineCircuit(mode), a)
+		a.Aux = point(signed)
+		assertRejects(t, mode.String()+" signed aux", newOfflineCircuit(mode), a)
+	}
+}
+
+func TestTiers(t *testing.T) {
+	tier, err := LookupTier("basic")
+	if err != nil || tier != TierBasic {
+		t.Fatalf("LookupTier(basic) = %v, %v", tier, err)
+	}
+	if _, err := LookupTier("gold"); err == nil {
+		t.Fatal("unknown tier accepted")
+	}
+	if !tier.Allows(big.NewInt(10_000)) || tier.Allows(big.NewInt(10_001)) || tier.Allows(big.NewInt(-1)) {
+		t.Fatal("Allows does not match the limit")
+	}
+}
+
+// rederive replaces the derived account of o by one carrying newFreq
+func rederive(o Offline, newFreq FreqState) Offline {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	priacc := PrimitiveAccount{
+		G0: o.G0, G1: o.G1, H: o.H,
+		Tracesk: o.Tracesk, Sk: o.Sk, Pk: o.Pk, Bal: o.Bal, Freq: o.Freq,
+	}
+	o.NewFreq = newFreq
+	d := DeriveAccount{Freq: newFreq}
+	o.Deriveacc = d.ChangeGen(params, hash.MIMC_BN254, o.Newseq, priacc, o.Deriveacc.Bal, nil)
+	return o
+}
+
+func TestFrequencyLimit(t *testing.T) {
+	curveid := ecctedwards.BN254
+	field := ecc.BN254.ScalarField()
+	policy := DefaultFreqPolicy
+	start, _ := policy.Window(testDate)
+	yesterday := start - policy.Length
+
+	spend := func(old FreqState) Offline {
+		o := Offline{Mode: FreqLimit, Policy: policy, Freq: old, Issuer: testIssuer()}
+		return execution(t, o, nil)
+	}
+	solves := func(o Offline) error {
+		return test.IsSolved(newOfflineCircuit(FreqLimit), offlineAssignment(o, FreqLimit, curveid), field)
+	}
+
+	for _, c := range []struct {
+		name string
+		old  FreqState
+		next FreqState
+		ok   bool
+	}{
+		{"first spend", FreqState{}, FreqState{start, 1}, true},
+		{"same period", FreqState{start, 3}, FreqState{start, 4}, true},
+		{"last allowed", FreqState{start, 9}, FreqState{start, 10}, true},
+		{"over the limit", FreqState{start, 10}, FreqState{start, 11}, false},
+		{"rollover resets", FreqState{yesterday, 10}, FreqState{start, 1}, true},
+		{"period in the future", FreqState{start + policy.Length, 0}, FreqState{start, 1}, false},
+	} {
+		o := spend(c.old)
+		if o.NewFreq != c.next {
+			t.Errorf("%s: next state %+v, want %+v", c.name, o.NewFreq, c.next)
+		}
+		if err := solves(o); (err == nil) != c.ok {
+			t.Errorf("%s: solved %v, want %v", c.name, err == nil, c.ok)
+		}
+	}
+
+	o := spend(FreqState{start, 3})
+	for _, c := range []struct {
+		name string
+		next FreqState
+	}{
+		{"counter kept", FreqState{start, 3}},
+		{"counter reset in the same period", FreqState{start, 1}},
+		{"counter skipped", FreqState{start, 5}},
+		{"other period", FreqState{start + policy.Length, 4}},
+	} {
+		if err := solves(rederive(o, c.next)); err == nil {
+			t.Errorf("%s: accepted", c.name)
+		}
+	}
+
+	// the signed date must be in the public window
+	late := o
+	late.PeriodStart, late.PeriodEnd = policy.Window(testDate + policy.Length)
+	late = rederive(late, FreqState{late.PeriodStart, 1})
+	if err := solves(late); err == nil {
+		t.Error("date outside the period accepted")
+	}
+
+	// without the date module the state is carried unchanged
+	o = Offline{Mode: HoldingLimit, Freq: FreqState{start, 3}, Issuer: testIssuer()}
+	o = execution(t, o, nil)
+	if o.NewFreq != o.Freq {
+		t.Fatalf("holding-limit spend changed the state to %+v", o.NewFreq)
+	}
+	if err := test.IsSolved(newOfflineCircuit(HoldingLimit), offlineAssignment(o, HoldingLimit, curveid), field); err != nil {
+		t.Fatal(err)
+	}
+	if err := test.IsSolved(newOfflineCircuit(HoldingLimit), offlineAssignment(rederive(o, FreqState{}), HoldingLimit, curveid), field); err == nil {
+		t.Error("holding-limit spend reset the counter")
+	}
+}
+
+func TestRejectsForgedB(t *testing.T) {
+	o := testOffline(t, NoRegulation)
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	curveid := ecctedwards.BN254
+	field := ecc.BN254.ScalarField()
+
+	// B' = B - (d/sk)*g0 decrypts, with the certified A, to the old plaintext
+	// plus d*g0: d more coins, if nothing certified B
+	d := big.NewInt(1_000)
+	k := new(big.Int).ModInverse(o.Sk.Sk, params.Order)
+	k.Mul(k, d).Mod(k, params.Order)
+	var forged curve.PointAffine
+	forged.ScalarMultiplication(&o.G0, k)
+	forged.Neg(&forged)
+	forged.Add(&o.OldAcc[1], &forged)
+
+	o.OldAcc = []curve.PointAffine{o.OldAcc[0], forged}
+	o.Bal.Add(&o.Bal, d)
+	o.Deriveacc.Bal = o.Bal
+	o = rederive(o, o.NewFreq)
+
+	// the forgery is consistent except for the bank's signature
+	a := offlineAssignment(o, NoRegulation, curveid)
+	assertRejects(t, "forged B", newOfflineCircuit(NoRegulation), a)
+
+	// and the same witness with the forged account signed does solve
+	sk, err := eddsa.New(curveid, util.NewSeededReader([]byte(t.Name())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Sigpk = sk.Public()
+	o.Signature = util.Sign(sk, util.Account_digest(o.OldAcc, o.Oldseq, o.Expiry, o.Tier.Limit(), o.Aux, hash.MIMC_BN254), hash.MIMC_BN254)
+	if err := test.IsSolved(newOfflineCircuit(NoRegulation), offlineAssignment(o, NoRegulation, curveid), field); err != nil {
+		t.Fatal(err)
+	}
 }
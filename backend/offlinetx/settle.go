@@ -0,0 +1,84 @@
+package offlinetx
+
+import (
+	"Asyn_CBDC/backend/issuer"
+	"Asyn_CBDC/backend/keys"
+	"Asyn_CBDC/backend/nullifier"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/schema"
+)
+
+// spendFields are the public inputs that name a spend: its delta, the derived
+// account and the payment. The proof is left out, a re-randomized proof of
+// the same spend is the same transaction.
+var spendFields = []string{
+	"ExpectedDelta",
+	"ExpectedDAcc_A_X", "ExpectedDAcc_A_Y", "ExpectedDAcc_B_X", "ExpectedDAcc_B_Y",
+	"ExpectedPayment_A_X", "ExpectedPayment_A_Y", "ExpectedPayment_B_X", "ExpectedPayment_B_Y",
+}
+
+// publicIndex maps the public inputs of the offline circuits to their position
+// in the public witness, read from the circuit schema. The inputs of the core
+// come before those of the modules, so their positions are the same in every
+// mode.
+var publicIndex = sync.OnceValue(func() map[string]int {
+	index := make(map[string]int)
+	tVariable := reflect.TypeOf((*frontend.Variable)(nil)).Elem()
+	_, err := schema.Walk(newOfflineCircuit(NoRegulation), tVariable, func(f schema.LeafInfo, _ reflect.Value) error {
+		if f.Visibility == schema.Public {
+			index[f.FullName()] = len(index)
+		}
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range spendFields {
+		if _, ok := index[name]; !ok {
+			panic("offlinetx: no public input " + name)
+		}
+	}
+	return index
+})
+
+// Settle verifies an offline proof as VerifyIssued does and records its delta
+// in nulls. A delta already spent by another transaction is a
+// *nullifier.DoubleSpendError. Nothing is recorded for a rejected proof.
+func Settle(nulls nullifier.Store, reg *issuer.Registry, policy FreqPolicy, now uint64, mode RegulationMode, proof keys.Proof, pub PublicInputs, vk keys.VerifyingKey) (nullifier.Record, error) {
+	if err := VerifyIssued(reg, policy, now, mode, proof, pub, vk); err != nil {
+		return nullifier.Record{}, err
+	}
+	publicWitness, err := pub.witness(mode)
+	if err != nil {
+		return nullifier.Record{}, err
+	}
+	r, err := spendRecord(publicWitness)
+	if err != nil {
+		return nullifier.Record{}, err
+	}
+	return r, nulls.Record(r)
+}
+
+func spendRecord(publicWitness witness.Witness) (nullifier.Record, error) {
+	public, ok := publicWitness.Vector().(fr.Vector)
+	index := publicIndex()
+	if !ok || len(public) < len(index) {
+		return nullifier.Record{}, fmt.Errorf("offlinetx: malformed public witness")
+	}
+	var data [][]byte
+	for _, name := range spendFields {
+		b := public[index[name]].Bytes()
+		data = append(data, b[:])
+	}
+	return nullifier.Record{
+		Delta: public[index["ExpectedDelta"]].BigInt(new(big.Int)),
+		TxID:  nullifier.TxID(data...),
+	}, nil
+}
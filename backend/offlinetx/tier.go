@@ -0,0 +1,47 @@
+package offlinetx
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// Tier is a class of wallet with its own holding limit: the most a wallet
+// may keep after an offline spend in HoldingLimit and FreqLimit modes.
+type Tier struct {
+	Name         string
+	HoldingLimit uint64
+}
+
+// the default tiers, by how much the holder was identified at enrollment
+var (
+	TierAnonymous = Tier{Name: "anonymous", HoldingLimit: 1_000}
+	TierBasic     = Tier{Name: "basic", HoldingLimit: 10_000}
+	TierVerified  = Tier{Name: "verified", HoldingLimit: 100_000}
+)
+
+// Tiers lists the tiers known to the bank, by name. Deployments replace or
+// extend it with their own limits.
+var Tiers = map[string]Tier{
+	TierAnonymous.Name: TierAnonymous,
+	TierBasic.Name:     TierBasic,
+	TierVerified.Name:  TierVerified,
+}
+
+// LookupTier returns the tier called name.
+func LookupTier(name string) (Tier, error) {
+	t, ok := Tiers[name]
+	if !ok {
+		return Tier{}, fmt.Errorf("offlinetx: unknown wallet tier %q", name)
+	}
+	return t, nil
+}
+
+// Limit returns the holding limit as the circuit input.
+func (t Tier) Limit() *big.Int {
+	return new(big.Int).SetUint64(t.HoldingLimit)
+}
+
+// Allows reports whether a wallet of tier t may hold balance.
+func (t Tier) Allows(balance *big.Int) bool {
+	return balance.Sign() >= 0 && balance.Cmp(t.Limit()) <= 0
+}
@@ -0,0 +1,371 @@
+package offlinetx
+
+import (
+	"Asyn_CBDC/backend/issuer"
+	"Asyn_CBDC/backend/keys"
+	"Asyn_CBDC/backend/util"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark-crypto/signature"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	cir_eddsa "github.com/consensys/gnark/std/signature/eddsa"
+)
+
+// RegulationMode selects what an offline transaction discloses to the
+// regulator, and so which circuit proves it.
+type RegulationMode int
+
+const (
+	NoRegulation      RegulationMode = iota // nothing
+	NoLimitRegulation                       // the sender's key, encrypted to the regulator
+	HoldingLimit                            // the sender's key, re-randomized by A, and Aux
+	FreqLimit                               // as HoldingLimit, plus a commitment to the date
+)
+
+var modeNames = [...]string{"no-regulation", "nolimit-regulation", "holdinglimit-regulation", "freqlimit-regulation"}
+
+func (m RegulationMode) String() string {
+	if m < 0 || int(m) >= len(modeNames) {
+		return fmt.Sprintf("RegulationMode(%d)", int(m))
+	}
+	return modeNames[m]
+}
+
+// Circuit returns the name of the circuit of m in a keys.Store.
+func (m RegulationMode) Circuit() (string, error) {
+	switch m {
+	case NoRegulation:
+		return CircuitNoRegulation, nil
+	case NoLimitRegulation:
+		return CircuitNoLimitRegulation, nil
+	case HoldingLimit:
+		return CircuitHoldingLimit, nil
+	case FreqLimit:
+		return CircuitFreqLimit, nil
+	}
+	return "", fmt.Errorf("offlinetx: unknown regulation mode %d", int(m))
+}
+
+// PublicInputs are the public inputs of an offline proof, as received by the
+// bank. The regulation fields are nil in the modes that do not use them.
+type PublicInputs struct {
+	SigPublicKey   signature.PublicKey // bank key that signed the old account
+	Expiry         *big.Int            // end of validity of that signature, Unix time
+	Delta          *big.Int            // nullifier of the old account
+	DAcc           []curve.PointAffine // derived account
+	DPublicKey     *curve.PointAffine  // derived public key
+	RecipientPk    *curve.PointAffine  // payee
+	Payment        []curve.PointAffine // amount paid, encrypted to RecipientPk
+	CPk            []curve.PointAffine // regulation ciphertext, NoLimitRegulation and up
+	PublicKeyA     *curve.PointAffine  // regulator key, NoLimitRegulation and up
+	Aux            *curve.PointAffine  // HoldingLimit and FreqLimit
+	HoldingLimit   *big.Int            // limit of the wallet's tier, HoldingLimit and FreqLimit
+	Comment        *curve.PointAffine  // date commitment, FreqLimit
+	PeriodStart    *big.Int            // current period, FreqLimit
+	PeriodEnd      *big.Int
+	MaxTxPerPeriod *big.Int
+}
+
+// PublicInputs returns the public inputs of o proved in mode.
+func (o Offline) PublicInputs(mode RegulationMode) PublicInputs {
+	pub := PublicInputs{
+		SigPublicKey: o.Sigpk,
+		Expiry:       o.Expiry,
+		Delta:        o.Delta,
+		DAcc:         o.Deriveacc.Acc,
+		DPublicKey:   &o.Deriveacc.Keypair.DPk.Pk,
+		RecipientPk:  &o.Recipient.Pk,
+		Payment:      o.Payment,
+	}
+	if mode >= NoLimitRegulation {
+		pub.CPk = o.CipherPk
+		pub.PublicKeyA = &o.Apk.Pk
+	}
+	if mode >= HoldingLimit {
+		pub.CPk = o.RegTk
+		pub.Aux = o.Aux
+		pub.HoldingLimit = o.Tier.Limit()
+	}
+	if mode >= FreqLimit {
+		pub.Comment = o.Comment
+		pub.PeriodStart = new(big.Int).SetUint64(o.PeriodStart)
+		pub.PeriodEnd = new(big.Int).SetUint64(o.PeriodEnd)
+		pub.MaxTxPerPeriod = new(big.Int).SetUint64(o.Policy.MaxTxPerPeriod)
+	}
+	return pub
+}
+
+// ErrFreqPolicy is returned when the frequency limit a FreqLimit proof was made
+// under is not the regulator's policy, or its period has not started yet.
+var ErrFreqPolicy = errors.New("offlinetx: frequency limit is not the regulator's")
+
+// VerifyOffline checks an offline proof made in mode against its public
+// inputs and the verifying key of the circuit of mode. The account signature
+// must not have expired at now, Unix time. In FreqLimit mode the proof must
+// count the spend under policy, in one of its periods that has started by now.
+// VerifyOffline does not authenticate the issuer of the signature key; a bank
+// settling proofs uses VerifyIssued.
+func VerifyOffline(mode RegulationMode, policy FreqPolicy, now uint64, proof keys.Proof, pub PublicInputs, vk keys.VerifyingKey) error {
+	if _, err := mode.Circuit(); err != nil {
+		return err
+	}
+	if proof == nil || vk == nil {
+		return errors.New("offlinetx: missing proof or verifying key")
+	}
+	if err := pub.check(mode); err != nil {
+		return err
+	}
+	if now > pub.Expiry.Uint64() {
+		return fmt.Errorf("%w at %s", issuer.ErrExpired, pub.Expiry)
+	}
+	if err := pub.checkPolicy(mode, policy, now); err != nil {
+		return err
+	}
+	publicWitness, err := pub.witness(mode)
+	if err != nil {
+		return err
+	}
+	if n := len(publicWitness.Vector().(fr.Vector)); n != vk.NbPublicWitness() {
+		return fmt.Errorf("offlinetx: verifying key takes %d public inputs, %s has %d: key of another mode?", vk.NbPublicWitness(), mode, n)
+	}
+	if err := keys.Verify(proof, vk, publicWitness); err != nil {
+		return fmt.Errorf("offlinetx: %s proof rejected: %w", mode, err)
+	}
+	return nil
+}
+
+// VerifyIssued is VerifyOffline for a verifier that pins the issuer: the
+// signature key of the proof must resolve in reg to a subkey still covering
+// pub.Expiry at now, Unix time.
+func VerifyIssued(reg *issuer.Registry, policy FreqPolicy, now uint64, mode RegulationMode, proof keys.Proof, pub PublicInputs, vk keys.VerifyingKey) error {
+	if err := pub.check(mode); err != nil {
+		return err
+	}
+	if _, err := reg.Resolve(pub.SigPublicKey, pub.Expiry.Uint64(), now); err != nil {
+		return err
+	}
+	return VerifyOffline(mode, policy, now, proof, pub, vk)
+}
+
+// witness is the public witness of pub in mode.
+func (pub PublicInputs) witness(mode RegulationMode) (witness.Witness, error) {
+	assignment, err := pub.assignment(mode)
+	if err != nil {
+		return nil, err
+	}
+	publicWitness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return nil, fmt.Errorf("offlinetx: %s public inputs: %w", mode, err)
+	}
+	return publicWitness, nil
+}
+
+// checkPolicy rejects, in FreqLimit mode, a window that is not a period of
+// policy or starts after now, and a maximum other than the one of policy: the
+// circuit only checks the spend against the window and maximum it is given.
+// A spend settled after its period is accepted.
+func (pub PublicInputs) checkPolicy(mode RegulationMode, policy FreqPolicy, now uint64) error {
+	if mode < FreqLimit {
+		return nil
+	}
+	if policy.Length == 0 {
+		return fmt.Errorf("%w: the policy has no period", ErrFreqPolicy)
+	}
+	if !pub.PeriodStart.IsUint64() || !pub.PeriodEnd.IsUint64() {
+		return fmt.Errorf("%w: period [%s,%s] is not a 64-bit window", ErrFreqPolicy, pub.PeriodStart, pub.PeriodEnd)
+	}
+	start, end := pub.PeriodStart.Uint64(), pub.PeriodEnd.Uint64()
+	if s, e := policy.Window(start); s != start || e != end {
+		return fmt.Errorf("%w: period [%d,%d] is not a period of %d seconds", ErrFreqPolicy, start, end, policy.Length)
+	}
+	if start > now {
+		return fmt.Errorf("%w: period [%d,%d] starts after %d", ErrFreqPolicy, start, end, now)
+	}
+	if !pub.MaxTxPerPeriod.IsUint64() || pub.MaxTxPerPeriod.Uint64() != policy.MaxTxPerPeriod {
+		return fmt.Errorf("%w: at most %s transactions per period, the policy allows %d", ErrFreqPolicy, pub.MaxTxPerPeriod, policy.MaxTxPerPeriod)
+	}
+	return nil
+}
+
+// check rejects missing and malformed inputs, and the inputs of another mode.
+func (pub PublicInputs) check(mode RegulationMode) error {
+	if pub.SigPublicKey == nil {
+		return errors.New("offlinetx: missing signature public key")
+	}
+	if _, err := pub.sigPoint(); err != nil {
+		return err
+	}
+	if pub.Expiry == nil {
+		return errors.New("offlinetx: missing expiry")
+	}
+	if pub.Expiry.Sign() < 0 || !pub.Expiry.IsUint64() {
+		return errors.New("offlinetx: expiry is not a 64-bit value")
+	}
+	if pub.Delta == nil {
+		return errors.New("offlinetx: missing delta")
+	}
+	if pub.Delta.Sign() < 0 || pub.Delta.Cmp(fr.Modulus()) >= 0 {
+		return errors.New("offlinetx: delta is not a field element")
+	}
+	if err := checkPoints("derived account", pub.DAcc, 2); err != nil {
+		return err
+	}
+	if err := checkPoint("derived public key", pub.DPublicKey); err != nil {
+		return err
+	}
+	if err := checkPoint("recipient key", pub.RecipientPk); err != nil {
+		return err
+	}
+	if err := checkPoints("payment", pub.Payment, 2); err != nil {
+		return err
+	}
+
+	regulated := mode >= NoLimitRegulation
+	if err := expect(mode, "regulation ciphertext", pub.CPk != nil, regulated); err != nil {
+		return err
+	}
+	if err := expect(mode, "regulator key", pub.PublicKeyA != nil, regulated); err != nil {
+		return err
+	}
+	if err := expect(mode, "aux", pub.Aux != nil, mode >= HoldingLimit); err != nil {
+		return err
+	}
+	if err := expect(mode, "holding limit", pub.HoldingLimit != nil, mode >= HoldingLimit); err != nil {
+		return err
+	}
+	if pub.HoldingLimit != nil && (pub.HoldingLimit.Sign() < 0 || !pub.HoldingLimit.IsUint64()) {
+		return errors.New("offlinetx: holding limit is not a 64-bit value")
+	}
+	if err := expect(mode, "date commitment", pub.Comment != nil, mode >= FreqLimit); err != nil {
+		return err
+	}
+	for _, c := range []struct {
+		what string
+		v    *big.Int
+		bits uint
+	}{
+		{"period start", pub.PeriodStart, 64},
+		{"period end", pub.PeriodEnd, 64},
+		{"maximum number of transactions per period", pub.MaxTxPerPeriod, CounterBits},
+	} {
+		if err := expect(mode, c.what, c.v != nil, mode >= FreqLimit); err != nil {
+			return err
+		}
+		if c.v != nil && (c.v.Sign() < 0 || c.v.BitLen() > int(c.bits)) {
+			return fmt.Errorf("offlinetx: %s is not a %d-bit value", c.what, c.bits)
+		}
+	}
+	if pub.PeriodStart != nil && pub.PeriodStart.Cmp(pub.PeriodEnd) > 0 {
+		return errors.New("offlinetx: period ends before it starts")
+	}
+	if regulated {
+		if err := checkPoints("regulation ciphertext", pub.CPk, 2); err != nil {
+			return err
+		}
+		if err := checkPoint("regulator key", pub.PublicKeyA); err != nil {
+			return err
+		}
+	}
+	if pub.Aux != nil {
+		if err := checkPoint("aux", pub.Aux); err != nil {
+			return err
+		}
+	}
+	if pub.Comment != nil {
+		if err := checkPoint("date commitment", pub.Comment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expect(mode RegulationMode, what string, given, used bool) error {
+	switch {
+	case given && !used:
+		return fmt.Errorf("offlinetx: %s given, %s does not use it: wrong mode?", what, mode)
+	case !given && used:
+		return fmt.Errorf("offlinetx: %s requires the %s", mode, what)
+	}
+	return nil
+}
+
+func checkPoints(what string, p []curve.PointAffine, n int) error {
+	if len(p) != n {
+		return fmt.Errorf("offlinetx: %s has %d points, want %d", what, len(p), n)
+	}
+	for i := range p {
+		if err := checkPoint(fmt.Sprintf("%s[%d]", what, i), &p[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkPoint(what string, p *curve.PointAffine) error {
+	if p == nil {
+		return fmt.Errorf("offlinetx: missing %s", what)
+	}
+	if !p.IsOnCurve() {
+		return fmt.Errorf("offlinetx: %s is not on the curve", what)
+	}
+	return nil
+}
+
+func point(p curve.PointAffine) twistededwards.Point {
+	return twistededwards.Point{X: p.X, Y: p.Y}
+}
+
+func account(c []curve.PointAffine) util.Account {
+	return util.Account{A: point(c[0]), B: point(c[1])}
+}
+
+// sigPoint decompresses the signature public key. cir_eddsa.PublicKey.Assign
+// would panic on a bad encoding.
+func (pub PublicInputs) sigPoint() (curve.PointAffine, error) {
+	var a curve.PointAffine
+	if _, err := a.SetBytes(pub.SigPublicKey.Bytes()); err != nil {
+		return a, fmt.Errorf("offlinetx: malformed signature public key: %w", err)
+	}
+	return a, nil
+}
+
+// assignment fills the public inputs of the circuit of mode.
+func (pub PublicInputs) assignment(mode RegulationMode) (*offlineCircuit, error) {
+	if _, err := mode.Circuit(); err != nil {
+		return nil, err
+	}
+	a, err := pub.sigPoint()
+	if err != nil {
+		return nil, err
+	}
+	assignment := newOfflineCircuit(mode)
+	assignment.SigPublicKey = cir_eddsa.PublicKey{A: point(a)}
+	assignment.ExpectedDelta = pub.Delta
+	assignment.ExpectedDAcc = account(pub.DAcc)
+	assignment.ExpectedDPublicKey = point(*pub.DPublicKey)
+	assignment.RecipientPk = point(*pub.RecipientPk)
+	assignment.ExpectedPayment = account(pub.Payment)
+	assignment.Expiry = pub.Expiry
+	for i := range assignment.Reg {
+		assignment.Reg[i].ExpectedCPk = [2]twistededwards.Point{point(pub.CPk[0]), point(pub.CPk[1])}
+		assignment.Reg[i].PublicKeyA = point(*pub.PublicKeyA)
+	}
+	for i := range assignment.Holding {
+		assignment.Holding[i].ExpectedAux = point(*pub.Aux)
+		assignment.Holding[i].HoldingLimit = pub.HoldingLimit
+	}
+	for i := range assignment.Date {
+		assignment.Date[i].Comment = point(*pub.Comment)
+		assignment.Date[i].PeriodStart = pub.PeriodStart
+		assignment.Date[i].PeriodEnd = pub.PeriodEnd
+		assignment.Date[i].MaxTxPerPeriod = pub.MaxTxPerPeriod
+	}
+	return assignment, nil
+}
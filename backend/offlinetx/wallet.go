@@ -0,0 +1,44 @@
+package offlinetx
+
+import (
+	"Asyn_CBDC/backend/enroll"
+	"math/big"
+	"sync"
+)
+
+// Wallet counts the offline spends of one account. Spending the account at
+// seq publishes delta=mimc(tk,seq) and derives the account at seq+1, so with
+// the counter every delta is published once and ExpectedDelta is a one-time
+// nullifier.
+type Wallet struct {
+	mu  sync.Mutex
+	seq *big.Int
+}
+
+// NewWallet returns a wallet whose account is at seq.
+func NewWallet(seq *big.Int) *Wallet {
+	return &Wallet{seq: new(big.Int).Set(seq)}
+}
+
+// NewEnrolledWallet returns the wallet of a freshly enrolled account.
+func NewEnrolledWallet() *Wallet {
+	return NewWallet(big.NewInt(enroll.FirstSeq))
+}
+
+// Seq returns the sequence number of the current account.
+func (w *Wallet) Seq() *big.Int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return new(big.Int).Set(w.seq)
+}
+
+// Next returns the sequence numbers of the account being spent and of the
+// derived account, and advances the wallet to the latter.
+func (w *Wallet) Next() (seq, next *big.Int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	seq = new(big.Int).Set(w.seq)
+	next = new(big.Int).Add(seq, big.NewInt(1))
+	w.seq.Set(next)
+	return seq, new(big.Int).Set(next)
+}
@@ -0,0 +1,99 @@
+package onlinetx
+
+import (
+	"Asyn_CBDC/backend/onlinetx/bulletproof"
+	"Asyn_CBDC/backend/onlinetx/crossgroup"
+	"fmt"
+	"math/big"
+	"time"
+
+	bn254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+)
+
+// binding is the ciphertexts on BabyJubjub that must hold the value of one
+// commitment of a range proof on BN254, and the randomness they were
+// encrypted with, which only the prover has.
+type binding struct {
+	keys        []crossgroup.Key
+	ciphertexts []crossgroup.Ciphertext
+	r           []*big.Int
+}
+
+func (b *binding) add(key crossgroup.Key, cipher []curve.PointAffine, r *big.Int) {
+	b.keys = append(b.keys, key)
+	b.ciphertexts = append(b.ciphertexts, crossgroup.Ciphertext{A: cipher[0], B: cipher[1]})
+	b.r = append(b.r, r)
+}
+
+func (b binding) statement(params bulletproof.BulletParams, V bn254.G1Affine) crossgroup.Statement {
+	return crossgroup.Statement{
+		N:           int(params.N),
+		G:           params.Bg,
+		H:           params.Bh,
+		V:           V,
+		Keys:        b.keys,
+		Ciphertexts: b.ciphertexts,
+	}
+}
+
+// amount binds v to txs, txr and cipher_v, its encryptions to the sender,
+// the receiver and the regulator.
+func (s sender) amount() binding {
+	var b binding
+	b.add(crossgroup.Key{M: s.dacc.G0, Pk: s.dacc.Keypair.DPk.Pk, H: s.dacc.H}, []curve.PointAffine{s.txs.A, s.txs.B}, s.r_txs)
+	b.add(crossgroup.Key{M: s.dacc.G0, Pk: s.r_derivepk.Pk, H: s.dacc.H}, []curve.PointAffine{s.txr.A, s.txr.B}, s.r_txr)
+	b.add(crossgroup.Key{M: s._trans, Pk: s.apk.Pk, H: s.h}, s.cipher_v, s.r_v)
+	return b
+}
+
+// change binds bal-v to cipher_bal-cipher_v, the balance left encrypted to
+// the regulator.
+func (s sender) change() binding {
+	var A, B curve.PointAffine
+	A.Add(&s.cipher_bal[0], new(curve.PointAffine).Neg(&s.cipher_v[0]))
+	B.Add(&s.cipher_bal[1], new(curve.PointAffine).Neg(&s.cipher_v[1]))
+	r := new(big.Int).Sub(s.r_bal, s.r_v)
+	r.Mod(r, order)
+	var b binding
+	b.add(crossgroup.Key{M: s._trans, Pk: s.apk.Pk, H: s.h}, []curve.PointAffine{A, B}, r)
+	return b
+}
+
+// balance binds bal to cipher_bal.
+func (r receiver) balance() binding {
+	var b binding
+	b.add(crossgroup.Key{M: r._trans, Pk: r.apk.Pk, H: r.h}, r.cipher_bal, r.r_bal)
+	return b
+}
+
+// proveBindings proves that the first len(bindings) values of proof, opened by
+// values and gammas, are the ones in the ciphertexts of bindings.
+func proveBindings(proof bulletproof.RangeProof, values []*big.Int, gammas []*big.Int, bindings []binding) ([]crossgroup.Proof, error) {
+	bpPara := bulletproof.NewParams(bulletproof.Bits, int64(len(proof.V)))
+	var res []crossgroup.Proof
+	for j, b := range bindings {
+		p, err := crossgroup.Prove(b.statement(bpPara, proof.V[j]), values[j], gammas[j], b.r)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// verifyBindings checks that the commitments of proof hold the values of the
+// ciphertexts of bindings, one crossgroup proof each.
+func verifyBindings(proof bulletproof.RangeProof, bindings []binding, bound []crossgroup.Proof) (time.Duration, error) {
+	starttime := time.Now()
+	bpPara := bulletproof.NewParams(bulletproof.Bits, int64(len(proof.V)))
+	if len(bound) != len(bindings) || len(bindings) > len(proof.V) {
+		return time.Since(starttime), ErrBindingRejected
+	}
+	for j, b := range bindings {
+		if err := crossgroup.Verify(b.statement(bpPara, proof.V[j]), bound[j]); err != nil {
+			return time.Since(starttime), fmt.Errorf("%w: value %d: %w", ErrBindingRejected, j, err)
+		}
+	}
+	return time.Since(starttime), nil
+}
@@ -1,12 +1,21 @@
 package bulletproof
 
 import (
+	"encoding/binary"
 	"math/big"
+	"sync"
 
 	curve "github.com/consensys/gnark-crypto/ecc/bn254"
 	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
 )
 
+// Domain separates the generators of this package from any other hash to
+// BN254 G1. Changing it changes every generator and so every commitment.
+const Domain = "Asyn_CBDC/bulletproof/v1"
+
+// Bits is the width of the range proofs of the online transactions.
+const Bits = 32
+
 type BulletParams struct {
 	P  *big.Int
 	N  int64
@@ -17,16 +26,49 @@ type BulletParams struct {
 }
 
 func (p BulletParams) ParamsGen() BulletParams {
-	n := int64(32)
-	p.P = fr.Modulus()
-	p.N = n
-	G := GenerateMultiPoint(n)
-	H := GenerateMultiPoint(n)
-	g := GeneratePoint()
-	h := GeneratePoint()
-	p.G = G
-	p.H = H
-	p.Bg = g
-	p.Bh = h
+	return p.AggregateParamsGen(1)
+}
+
+// AggregateParamsGen returns the params of range proofs on up to m values of
+// Bits bits, with m*Bits generators in G and H.
+func (p BulletParams) AggregateParamsGen(m int64) BulletParams {
+	return NewParams(Bits, m)
+}
+
+var cache = struct {
+	sync.Mutex
+	byBits map[int64]BulletParams
+}{byBits: make(map[int64]BulletParams)}
+
+// NewParams returns the params of range proofs on up to m values of n bits.
+// Every generator is hashed to the curve from its name and index, so nobody
+// knows a discrete log between them and a verifier rebuilds the same params
+// as the prover. They are cached per bit width, grown as m requires.
+func NewParams(n int64, m int64) BulletParams {
+	cache.Lock()
+	defer cache.Unlock()
+	p, ok := cache.byBits[n]
+	if !ok {
+		p = BulletParams{P: fr.Modulus(), N: n, Bg: HashPoint("Bg", 0), Bh: HashPoint("Bh", 0)}
+	}
+	for i := int64(len(p.G)); i < m*n; i++ {
+		p.G = append(p.G, HashPoint("G", i))
+		p.H = append(p.H, HashPoint("H", i))
+	}
+	cache.byBits[n] = p
+	// callers get their own view, which appending to cannot spoil the cache
+	p.G = p.G[: m*n : m*n]
+	p.H = p.H[: m*n : m*n]
 	return p
 }
+
+// HashPoint hashes the generator name[i] to BN254 G1 under Domain.
+func HashPoint(name string, i int64) curve.G1Affine {
+	msg := binary.BigEndian.AppendUint64([]byte(name), uint64(i))
+	point, err := curve.HashToG1(msg, []byte(Domain))
+	if err != nil {
+		// only fails on a domain longer than 255 bytes
+		panic(err)
+	}
+	return point
+}
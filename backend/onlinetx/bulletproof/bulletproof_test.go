@@ -0,0 +1,142 @@
+package bulletproof
+
+import (
+	"Asyn_CBDC/backend/transcript"
+	"errors"
+	"math/big"
+	"testing"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254"
+)
+
+func TestInnerProduct(t *testing.T) {
+	for _, n := range []int64{1, 2, 8, 32} {
+		G := GenerateMultiPoint(n)
+		H := GenerateMultiPoint(n)
+		U := GeneratePoint()
+		a := Generate_s(n)
+		b := Generate_s(n)
+		commitP := CommitVectors(G, H, a, b)
+		commitU := CommitSingle(U, Inner_produ(a, b))
+		commitP.Add(&commitP, &commitU)
+
+		ts := func() *transcript.Transcript {
+			t := transcript.New("test")
+			t.AppendPoint("P", &commitP)
+			return t
+		}
+		proof, err := ProveInnerProduct(ts(), G, H, U, a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if 1<<len(proof.L) != n {
+			t.Errorf("n=%d: %d rounds", n, len(proof.L))
+		}
+		if err := VerifyInnerProduct(ts(), G, H, U, commitP, proof); err != nil {
+			t.Errorf("n=%d: %v", n, err)
+		}
+
+		bad := proof
+		bad.B = new(big.Int).Add(proof.B, big.NewInt(1))
+		if err := VerifyInnerProduct(ts(), G, H, U, commitP, bad); !errors.Is(err, ErrInnerProduct) {
+			t.Errorf("n=%d: tampered b: %v", n, err)
+		}
+		if n > 1 {
+			bad = proof
+			bad.L = append([]curve.G1Affine{proof.R[0]}, proof.L[1:]...)
+			if err := VerifyInnerProduct(ts(), G, H, U, commitP, bad); !errors.Is(err, ErrInnerProduct) {
+				t.Errorf("n=%d: tampered L: %v", n, err)
+			}
+		}
+	}
+
+	G := GenerateMultiPoint(3)
+	if _, err := ProveInnerProduct(transcript.New("test"), G, G, G[0], Generate_s(3), Generate_s(3)); !errors.Is(err, ErrVectorLength) {
+		t.Errorf("n=3: %v", err)
+	}
+}
+
+func TestRangeProof(t *testing.T) {
+	var params BulletParams
+	params = params.AggregateParamsGen(8)
+	max := new(big.Int).Lsh(big.NewInt(1), 32)
+	max.Sub(max, big.NewInt(1))
+	for _, m := range []int{1, 2, 3, 4, 8} {
+		var values []*big.Int
+		for j := 0; j < m; j++ {
+			values = append(values, big.NewInt(int64(j*1000)))
+		}
+		values[m-1] = max
+		proof, gammas, err := ProveRange(params, values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(proof.V) != len(gammas) || !powerOfTwo(len(proof.V)) || len(proof.V) < m {
+			t.Fatalf("m=%d: %d commitments, %d blindings", m, len(proof.V), len(gammas))
+		}
+		for j, v := range values {
+			if c := Commit(params.Bg, params.Bh, v, gammas[j]); !c.Equal(&proof.V[j]) {
+				t.Errorf("m=%d: V[%d] does not open to %v", m, j, v)
+			}
+		}
+		if err := VerifyRange(params, proof); err != nil {
+			t.Errorf("m=%d: %v", m, err)
+		}
+		if len(proof.V) > 1 {
+			bad := proof
+			bad.V = append([]curve.G1Affine{proof.V[1], proof.V[0]}, proof.V[2:]...)
+			if err := VerifyRange(params, bad); !errors.Is(err, ErrRange) {
+				t.Errorf("m=%d: swapped commitments: %v", m, err)
+			}
+		}
+	}
+
+	over := new(big.Int).Add(max, big.NewInt(1))
+	if _, _, err := ProveRange(params, []*big.Int{big.NewInt(1), over}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("2^32: %v", err)
+	}
+	if _, _, err := ProveRange(params, []*big.Int{big.NewInt(-1)}); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("-1: %v", err)
+	}
+	proof, _, err := ProveRange(params, []*big.Int{big.NewInt(1), big.NewInt(2), big.NewInt(3), big.NewInt(4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof.V = proof.V[:3]
+	if err := VerifyRange(params, proof); !errors.Is(err, ErrVectorLength) {
+		t.Errorf("3 commitments: %v", err)
+	}
+}
+
+func TestParamsDeterministic(t *testing.T) {
+	a := NewParams(Bits, 2)
+	b := NewParams(Bits, 4)
+	if len(a.G) != 2*Bits || len(b.H) != 4*Bits || a.N != Bits {
+		t.Fatalf("%d and %d generators", len(a.G), len(b.H))
+	}
+	if !a.Bg.Equal(&b.Bg) || !a.Bh.Equal(&b.Bh) || a.Bg.Equal(&a.Bh) {
+		t.Error("Bg and Bh")
+	}
+	seen := make(map[curve.G1Affine]bool)
+	for i := range a.G {
+		if !a.G[i].Equal(&b.G[i]) || !a.H[i].Equal(&b.H[i]) {
+			t.Fatalf("generator %d differs between rebuilds", i)
+		}
+		if seen[a.G[i]] || seen[a.H[i]] || a.G[i].Equal(&a.H[i]) {
+			t.Fatalf("generator %d repeats", i)
+		}
+		seen[a.G[i]], seen[a.H[i]] = true, true
+	}
+	if g := HashPoint("G", 0); !g.Equal(&a.G[0]) || !g.IsInSubGroup() {
+		t.Error("G[0]")
+	}
+
+	// a view handed out cannot change the cache
+	a.G = append(a.G, a.Bg)
+	if c := NewParams(Bits, 3); c.G[2*Bits].Equal(&a.Bg) {
+		t.Error("append to a view reached the cache")
+	}
+	if small := NewParams(8, 1); len(small.G) != 8 || !small.G[0].Equal(&a.G[0]) {
+		t.Errorf("8-bit params: %d generators", len(small.G))
+	}
+}
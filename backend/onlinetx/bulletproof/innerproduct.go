@@ -0,0 +1,131 @@
+package bulletproof
+
+import (
+	"Asyn_CBDC/backend/transcript"
+	"errors"
+	"math/big"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+var (
+	ErrInnerProduct = errors.New("bulletproof: inner product proof rejected")
+	ErrVectorLength = errors.New("bulletproof: vector length is not a power of two or does not match the generators")
+)
+
+// InnerProductProof shows that P=<a,G>+<b,H>+<a,b>*U in log2(n) rounds: each
+// round commits to the cross terms in L and R and halves a, b, G and H, until
+// a single A and B are left.
+type InnerProductProof struct {
+	L []curve.G1Affine
+	R []curve.G1Affine
+	A *big.Int
+	B *big.Int
+}
+
+// ProveInnerProduct proves that P=<a,G>+<b,H>+<a,b>*U. The length n of the
+// vectors and the generators must be a power of two. t must have absorbed P,
+// or what P is computed from, and goes on with the challenges of each round.
+func ProveInnerProduct(t *transcript.Transcript, G []curve.G1Affine, H []curve.G1Affine, U curve.G1Affine, a []*big.Int, b []*big.Int) (InnerProductProof, error) {
+	n := len(a)
+	if !powerOfTwo(n) || len(b) != n || len(G) != n || len(H) != n {
+		return InnerProductProof{}, ErrVectorLength
+	}
+	P = fr.Modulus()
+	t.AppendInt("n", int64(n))
+
+	var proof InnerProductProof
+	for ; n > 1; n /= 2 {
+		h := n / 2
+		cL := Inner_produ(a[:h], b[h:])
+		cR := Inner_produ(a[h:], b[:h])
+		L := CommitVectors(G[h:], H[:h], a[:h], b[h:])
+		_L := CommitSingle(U, cL)
+		L.Add(&L, &_L)
+		R := CommitVectors(G[:h], H[h:], a[h:], b[:h])
+		_R := CommitSingle(U, cR)
+		R.Add(&R, &_R)
+		proof.L = append(proof.L, L)
+		proof.R = append(proof.R, R)
+
+		u := challengeU(t, L, R)
+		if u.Sign() == 0 {
+			return InnerProductProof{}, ErrInnerProduct
+		}
+		uinv := inverseBig(u)
+		a = CalVectorAdd(CalVectorTimes(a[:h], u), CalVectorTimes(a[h:], uinv))
+		b = CalVectorAdd(CalVectorTimes(b[:h], uinv), CalVectorTimes(b[h:], u))
+		G, H = foldGenerators(G, H, u, uinv)
+	}
+	proof.A = a[0]
+	proof.B = b[0]
+	return proof, nil
+}
+
+// VerifyInnerProduct checks proof against P, G, H and U, folding the
+// generators with the challenges of each round. t must be in the state the
+// prover's was in.
+func VerifyInnerProduct(t *transcript.Transcript, G []curve.G1Affine, H []curve.G1Affine, U curve.G1Affine, commitP curve.G1Affine, proof InnerProductProof) error {
+	n := len(G)
+	if !powerOfTwo(n) || len(H) != n || len(proof.L) != len(proof.R) || 1<<len(proof.L) != n ||
+		proof.A == nil || proof.B == nil {
+		return ErrVectorLength
+	}
+	P = fr.Modulus()
+	t.AppendInt("n", int64(n))
+
+	for i := range proof.L {
+		u := challengeU(t, proof.L[i], proof.R[i])
+		if u.Sign() == 0 {
+			return ErrInnerProduct
+		}
+		uinv := inverseBig(u)
+		G, H = foldGenerators(G, H, u, uinv)
+		commitP = foldCommit(commitP, proof.L[i], proof.R[i], u, uinv)
+	}
+
+	ab := new(big.Int).Mul(proof.A, proof.B)
+	ab.Mod(ab, P)
+	expected := Commit(G[0], H[0], proof.A, proof.B)
+	_expected := CommitSingle(U, ab)
+	expected.Add(&expected, &_expected)
+	if !expected.Equal(&commitP) {
+		return ErrInnerProduct
+	}
+	return nil
+}
+
+// challengeU absorbs the L and R of a round and squeezes its challenge.
+func challengeU(t *transcript.Transcript, L curve.G1Affine, R curve.G1Affine) *big.Int {
+	t.AppendPoint("L", &L)
+	t.AppendPoint("R", &R)
+	return t.Challenge("u", P)
+}
+
+// foldGenerators halves G into G_lo/u+G_hi*u and H into H_lo*u+H_hi/u.
+func foldGenerators(G []curve.G1Affine, H []curve.G1Affine, u *big.Int, uinv *big.Int) ([]curve.G1Affine, []curve.G1Affine) {
+	h := len(G) / 2
+	G1 := make([]curve.G1Affine, h)
+	H1 := make([]curve.G1Affine, h)
+	for i := 0; i < h; i++ {
+		G1[i] = Commit(G[i], G[h+i], uinv, u)
+		H1[i] = Commit(H[i], H[h+i], u, uinv)
+	}
+	return G1, H1
+}
+
+// foldCommit returns u^2*L+P+u^-2*R, the commitment to the folded vectors.
+func foldCommit(commitP curve.G1Affine, L curve.G1Affine, R curve.G1Affine, u *big.Int, uinv *big.Int) curve.G1Affine {
+	u2 := new(big.Int).Mul(u, u)
+	u2.Mod(u2, P)
+	uinv2 := new(big.Int).Mul(uinv, uinv)
+	uinv2.Mod(uinv2, P)
+	LR := Commit(L, R, u2, uinv2)
+	commitP.Add(&commitP, &LR)
+	return commitP
+}
+
+func powerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
@@ -0,0 +1,265 @@
+package bulletproof
+
+import (
+	"Asyn_CBDC/backend/transcript"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+var (
+	ErrRange      = errors.New("bulletproof: range proof rejected")
+	ErrOutOfRange = errors.New("bulletproof: value out of range")
+)
+
+// RangeProof shows that each commitment V[j]=v_j*Bg+gamma_j*Bh opens to
+// 0<=v_j<2^N. The m values share A, S, T1, T2 and one inner product argument
+// over m*N bits, so the proof grows with 2*log2(m*N).
+type RangeProof struct {
+	V    []curve.G1Affine
+	A    curve.G1Affine
+	S    curve.G1Affine
+	T1   curve.G1Affine
+	T2   curve.G1Affine
+	Taux *big.Int
+	Miu  *big.Int
+	Tx   *big.Int
+	IPP  InnerProductProof
+}
+
+// Size is the encoded size of p: A, S, T1, T2 and the L and R of the inner
+// product argument compressed, taux, miu, tx, a and b. V is the statement, and
+// the challenges are recomputed from the commitments.
+func (p RangeProof) Size() int {
+	return (4+len(p.IPP.L)+len(p.IPP.R))*curve.SizeOfG1AffineCompressed + 5*fr.Bytes
+}
+
+// ProveRange commits to values and proves them all in [0,2^N) in one proof.
+// Their number is padded to a power of two with zeros, whose commitments are
+// appended to V. It returns the blinding factors of V.
+func ProveRange(params BulletParams, values []*big.Int) (RangeProof, []*big.Int, error) {
+	m := 1
+	for m < len(values) {
+		m *= 2
+	}
+	n := int(params.N)
+	if len(values) == 0 || len(params.G) < m*n || len(params.H) < m*n {
+		return RangeProof{}, nil, ErrVectorLength
+	}
+	mn := int64(m * n)
+	G := params.G[:mn]
+	H := params.H[:mn]
+	g := params.Bg
+	h := params.Bh
+	P = fr.Modulus()
+
+	var proof RangeProof
+	var gammas, aL []*big.Int
+	for j := 0; j < m; j++ {
+		v := big.NewInt(0)
+		if j < len(values) {
+			v.Set(values[j])
+		}
+		if v.Sign() < 0 {
+			return RangeProof{}, nil, fmt.Errorf("%w: value %d is negative", ErrOutOfRange, j)
+		}
+		gamma, _ := rand.Int(rand.Reader, P)
+		gammas = append(gammas, gamma)
+		proof.V = append(proof.V, Commit(g, h, v, gamma))
+		bits, err := Generate_a_L(v, params.N)
+		if err != nil {
+			return RangeProof{}, nil, fmt.Errorf("%w: value %d has more than %d bits", ErrOutOfRange, j, n)
+		}
+		aL = append(aL, bits...)
+	}
+	aR := Generate_a_R(aL)
+
+	//generate commitA,commitS
+	alpha, _ := rand.Int(rand.Reader, P)
+	proof.A = CommitVectors(G, H, aL, aR)
+	_commitA := CommitSingle(h, alpha)
+	proof.A.Add(&proof.A, &_commitA)
+	rho, _ := rand.Int(rand.Reader, P)
+	sL := Generate_s(mn)
+	sR := Generate_s(mn)
+	proof.S = CommitVectors(G, H, sL, sR)
+	_commitS := CommitSingle(h, rho)
+	proof.S.Add(&proof.S, &_commitS)
+
+	//generate challenge y,z
+	t := rangeTranscript(params, proof.V, mn)
+	t.AppendPoint("A", &proof.A)
+	t.AppendPoint("S", &proof.S)
+	y := *t.Challenge("y", P)
+	z := *t.Challenge("z", P)
+
+	//l(X)=l0+l1*X, r(X)=r0+r1*X
+	yn := GenerateY(y, mn)
+	l0 := CalVectorSub(aL, GenerateZ(z, mn))
+	r0 := CalVectorAdd(CalHadamardVec(yn, CalVectorAdd(aR, GenerateZ(z, mn))), zs2n(z, m, params.N))
+	r1 := CalHadamardVec(yn, sR)
+
+	//t(X)=<l(X),r(X)>=t0+t1*X+t2*X^2
+	t0 := Inner_produ(l0, r0)
+	t2 := Inner_produ(sL, r1)
+	t1 := Inner_produ(CalVectorAdd(l0, sL), CalVectorAdd(r0, r1))
+	t1.Sub(t1, t0)
+	t1.Sub(t1, t2)
+	t1.Mod(t1, P)
+	tau1, _ := rand.Int(rand.Reader, P)
+	tau2, _ := rand.Int(rand.Reader, P)
+	proof.T1 = Commit(g, h, t1, tau1)
+	proof.T2 = Commit(g, h, t2, tau2)
+
+	//generate challenge x
+	t.AppendPoint("T1", &proof.T1)
+	t.AppendPoint("T2", &proof.T2)
+	x := *t.Challenge("x", P)
+
+	//generate response
+	lx := CalVectorAdd(l0, CalVectorTimes(sL, &x))
+	rx := CalVectorAdd(r0, CalVectorTimes(r1, &x))
+	proof.Tx = Calculate_tx(lx, rx)
+	proof.Taux = Calculate_taux(tau1, tau2, x, z, big.NewInt(0))
+	zj := new(big.Int).Mul(&z, &z)
+	for _, gamma := range gammas {
+		_taux := new(big.Int).Mul(zj, gamma)
+		proof.Taux.Add(proof.Taux, _taux)
+		proof.Taux.Mod(proof.Taux, P)
+		zj.Mul(zj, &z)
+		zj.Mod(zj, P)
+	}
+	proof.Miu = Calculate_miu(alpha, rho, x)
+
+	//prove <lx,rx>=tx in log2(m*n) rounds
+	U := challengeW(t, proof, g)
+	H1 := GenerateH1(H, y, mn, P)
+	ipp, err := ProveInnerProduct(t, G, H1, U, lx, rx)
+	if err != nil {
+		return RangeProof{}, nil, err
+	}
+	proof.IPP = ipp
+	return proof, gammas, nil
+}
+
+// VerifyRange checks proof for any power-of-two number of commitments that
+// params has generators for.
+func VerifyRange(params BulletParams, proof RangeProof) error {
+	m := len(proof.V)
+	n := int(params.N)
+	if !powerOfTwo(m) || len(params.G) < m*n || len(params.H) < m*n ||
+		proof.Taux == nil || proof.Miu == nil || proof.Tx == nil {
+		return ErrVectorLength
+	}
+	mn := int64(m * n)
+	G := params.G[:mn]
+	H := params.H[:mn]
+	g := params.Bg
+	h := params.Bh
+	P = fr.Modulus()
+
+	t := rangeTranscript(params, proof.V, mn)
+	t.AppendPoint("A", &proof.A)
+	t.AppendPoint("S", &proof.S)
+	y := *t.Challenge("y", P)
+	z := *t.Challenge("z", P)
+	t.AppendPoint("T1", &proof.T1)
+	t.AppendPoint("T2", &proof.T2)
+	x := *t.Challenge("x", P)
+
+	//δ(y,z)=(z-z^2)*<1,y^mn>-Σ z^(j+3)*<1,2^n>
+	yn := GenerateY(y, mn)
+	z2 := new(big.Int).Mul(&z, &z)
+	z2.Mod(z2, P)
+	delta := new(big.Int).Sub(&z, z2)
+	delta.Mul(delta, Inner_produ(GenerateZ(*big.NewInt(1), mn), yn))
+	sum2n := Inner_produ(GenerateZ(*big.NewInt(1), params.N), Generate2n(params.N))
+	//tx*g+taux*h==Σ z^(j+2)*V_j+δ*g+x*T1+x^2*T2
+	commit0 := Commit(g, h, proof.Tx, proof.Taux)
+	x2 := new(big.Int).Mul(&x, &x)
+	x2.Mod(x2, P)
+	commitVT := Commit(proof.T1, proof.T2, &x, x2)
+	zj := new(big.Int).Set(z2)
+	for j := range proof.V {
+		commitV := CommitSingle(proof.V[j], zj)
+		commitVT.Add(&commitVT, &commitV)
+		zj.Mul(zj, &z)
+		zj.Mod(zj, P)
+		_delta := new(big.Int).Mul(zj, sum2n)
+		delta.Sub(delta, _delta)
+	}
+	delta.Mod(delta, P)
+	commitDelta := CommitSingle(g, delta)
+	commitVT.Add(&commitVT, &commitDelta)
+	if !commit0.Equal(&commitVT) {
+		return fmt.Errorf("%w: t(x) does not match V, T1 and T2", ErrRange)
+	}
+
+	//P=A+x*S-z*<1,G>+<z*y^mn+Σ z^(j+2)*2^n,H1>
+	H1 := GenerateH1(H, y, mn, P)
+	commitP := Commit(proof.A, proof.S, big.NewInt(1), &x)
+	commitz1 := CommitSingleVector(G, GenerateZ1(z, mn))
+	commitP.Add(&commitP, &commitz1)
+	commitvec := CommitSingleVector(H1, CalVectorAdd(CalVectorTimes(yn, &z), zs2n(z, m, params.N)))
+	commitP.Add(&commitP, &commitvec)
+	//P-miu*h+tx*U=<lx,G>+<rx,H1>+<lx,rx>*U
+	U := challengeW(t, proof, g)
+	commitmiu := CommitSingle(h, new(big.Int).Sub(P, proof.Miu))
+	commitU := CommitSingle(U, proof.Tx)
+	commitP.Add(&commitP, &commitmiu)
+	commitP.Add(&commitP, &commitU)
+	if err := VerifyInnerProduct(t, G, H1, U, commitP, proof.IPP); err != nil {
+		return fmt.Errorf("%w: %w", ErrRange, err)
+	}
+	return nil
+}
+
+// rangeTranscript starts the transcript of a range proof with its statement:
+// the bit width, the generators and the commitments V.
+func rangeTranscript(params BulletParams, V []curve.G1Affine, mn int64) *transcript.Transcript {
+	t := transcript.New("bulletproof/range")
+	t.AppendInt("bits", params.N)
+	t.AppendPoint("Bg", &params.Bg)
+	t.AppendPoint("Bh", &params.Bh)
+	t.AppendPoints("G", points(params.G[:mn])...)
+	t.AppendPoints("H", points(params.H[:mn])...)
+	t.AppendPoints("V", points(V)...)
+	return t
+}
+
+// challengeW absorbs taux, miu and tx and returns U=w*g, the base of <lx,rx>
+// in the inner product argument.
+func challengeW(t *transcript.Transcript, proof RangeProof, g curve.G1Affine) curve.G1Affine {
+	t.AppendScalar("taux", proof.Taux)
+	t.AppendScalar("miu", proof.Miu)
+	t.AppendScalar("tx", proof.Tx)
+	w := t.Challenge("w", P)
+	return CommitSingle(g, w)
+}
+
+func points(ps []curve.G1Affine) []transcript.Point {
+	res := make([]transcript.Point, len(ps))
+	for i := range ps {
+		res[i] = &ps[i]
+	}
+	return res
+}
+
+// zs2n is z^2*2^n||z^3*2^n||...||z^(m+1)*2^n, the weights that turn the bits
+// of the m values back into the values.
+func zs2n(z big.Int, m int, n int64) []*big.Int {
+	p2n := Generate2n(n)
+	zj := new(big.Int).Mul(&z, &z)
+	zj.Mod(zj, P)
+	var vec []*big.Int
+	for j := 0; j < m; j++ {
+		vec = append(vec, CalVectorTimes(p2n, zj)...)
+		zj = new(big.Int).Mul(zj, &z)
+		zj.Mod(zj, P)
+	}
+	return vec
+}
@@ -0,0 +1,83 @@
+package onlinetx
+
+import (
+	"Asyn_CBDC/backend/transcript"
+	"math/big"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+)
+
+// The sender sigma protocols, one per regulation mode.
+const (
+	freqLimit    = "freqlimit"
+	noLimit      = "nolimit"
+	noRegulation = "noregulation"
+)
+
+// order of the BabyJubjub subgroup the sigma protocols work in
+var order = func() *big.Int {
+	c := curve.GetEdwardsCurve()
+	return &c.Order
+}()
+
+// challenge derives the challenge of the sender sigma protocol of mode from
+// its statement and the commitments of p. The prover calls it before the
+// responses exist, the verifier recomputes it.
+func (s sender) challenge(mode string, p sigmaProof) big.Int {
+	t := transcript.New("onlinetx/sender/" + mode)
+	t.AppendPoint("h", &s.dacc.H)
+	t.AppendPoint("g0", &s.dacc.G0)
+	t.AppendPoint("pks", &s.dacc.Keypair.DPk.Pk)
+	t.AppendPoint("pkr", &s.r_derivepk.Pk)
+	t.AppendPoint("txs.A", &s.txs.A)
+	t.AppendPoint("txs.B", &s.txs.B)
+	t.AppendPoint("txr.A", &s.txr.A)
+	t.AppendPoint("txr.B", &s.txr.B)
+	if mode != noRegulation {
+		t.AppendPoint("apk", &s.apk.Pk)
+		t.AppendPoint("apk.h", &s.h)
+		t.AppendPoint("trans", &s._trans)
+		t.AppendPoints("cipher_bal", points(s.cipher_bal)...)
+		t.AppendPoints("cipher_v", points(s.cipher_v)...)
+	}
+	if mode == freqLimit {
+		t.AppendPoint("dateg", &s.dateg)
+		t.AppendPoint("dateh", &s.dateh)
+		t.AppendPoint("commentdate", &s.commentdate)
+	}
+	return appendCommitments(t, p)
+}
+
+// challenge derives the challenge of the receiver sigma protocol.
+func (r receiver) challenge(p sigmaProof) big.Int {
+	t := transcript.New("onlinetx/receiver")
+	t.AppendPoint("h", &r.dacc.H)
+	pkbeta := new(curve.PointAffine).ScalarMultiplication(&r.pk, r.beta)
+	t.AppendPoint("pkbeta", pkbeta)
+	t.AppendPoint("apk", &r.apk.Pk)
+	t.AppendPoint("apk.h", &r.h)
+	t.AppendPoint("trans", &r._trans)
+	t.AppendPoints("cipher_bal", points(r.cipher_bal)...)
+	t.AppendPoint("dateg", &r.dateg)
+	t.AppendPoint("dateh", &r.dateh)
+	t.AppendPoint("commentdate", &r.commentdate)
+	return appendCommitments(t, p)
+}
+
+func appendCommitments(t *transcript.Transcript, p sigmaProof) big.Int {
+	for i := range p.commit {
+		t.AppendPoint("commit", &p.commit[i].Commit)
+	}
+	for _, c := range p.commitenc {
+		t.AppendPoints("commitenc", points(c)...)
+	}
+	return *t.Challenge("challenge", order)
+}
+
+func points(ps []curve.PointAffine) []transcript.Point {
+	res := make([]transcript.Point, len(ps))
+	for i := range ps {
+		res[i] = &ps[i]
+	}
+	return res
+}
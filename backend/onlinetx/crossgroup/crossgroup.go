@@ -0,0 +1,336 @@
+// Package crossgroup proves that a Pedersen commitment on BN254 G1, such as a
+// V of a bulletproof range proof, and ElGamal ciphertexts on BabyJubjub hold
+// the same value. The two groups have different orders, so the value is
+// split into N bits that are committed on both curves, and an OR proof shows
+// that each bit is 0 or 1 on both at once. The commitments to the bits add up
+// to V and to the ciphertexts, so the value is the same integer below 2^N in
+// all of them.
+package crossgroup
+
+import (
+	"Asyn_CBDC/backend/transcript"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	bn254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+)
+
+var (
+	ErrProof      = errors.New("crossgroup: proof rejected")
+	ErrStatement  = errors.New("crossgroup: proof does not match the statement")
+	ErrOutOfRange = errors.New("crossgroup: value out of range")
+)
+
+// ChallengeBits is the size of the challenges, below both group orders so
+// that a challenge means the same on either curve.
+const ChallengeBits = 128
+
+var (
+	challengeBound = new(big.Int).Lsh(big.NewInt(1), ChallengeBits)
+	// order of the BabyJubjub subgroup
+	order = func() *big.Int {
+		c := curve.GetEdwardsCurve()
+		return &c.Order
+	}()
+)
+
+// Key is what a ciphertext is encrypted under: the base M the value is
+// encoded on, and the public key Pk with the base H of the randomness.
+type Key struct {
+	M  curve.PointAffine
+	Pk curve.PointAffine
+	H  curve.PointAffine
+}
+
+// Ciphertext is (A,B)=(v*M+r*Pk, r*H), as util.Publickey.Encrypt computes it.
+type Ciphertext struct {
+	A curve.PointAffine
+	B curve.PointAffine
+}
+
+// Statement is that V=v*G+gamma*H on BN254 and Ciphertexts[k] encrypts v under
+// Keys[k], for one 0<=v<2^N.
+type Statement struct {
+	N           int
+	G           bn254.G1Affine
+	H           bn254.G1Affine
+	V           bn254.G1Affine
+	Keys        []Key
+	Ciphertexts []Ciphertext
+}
+
+// Bit commits to the bit b on both curves, C=b*G+gamma*H and D[k] the
+// encryption of b under Keys[k], and proves b is 0 or 1 in both. E, SG and SR
+// are the challenges and responses of the branches b=0 and b=1, of which the
+// prover could only simulate the other one.
+type Bit struct {
+	C  bn254.G1Affine
+	D  []Ciphertext
+	E  [2]*big.Int
+	SG [2]*big.Int
+	SR [2][]*big.Int
+}
+
+// Proof holds the bits of the value from the least significant one.
+type Proof struct {
+	Bits []Bit
+}
+
+// Size is the encoded size of p: C and D compressed, the challenges and the
+// responses.
+func (p Proof) Size() int {
+	n := 0
+	for _, b := range p.Bits {
+		n += bn254.SizeOfG1AffineCompressed + 2*ChallengeBits/8 + 2*fr.Bytes
+		// D[k] and its responses SR[0][k] and SR[1][k]
+		n += len(b.D) * 4 * fr.Bytes
+	}
+	return n
+}
+
+// commitments are what the prover of a bit sends first in the branch b=j:
+// R=k*H on BN254, and S[k] an encryption of 0 under each key.
+type commitments struct {
+	R bn254.G1Affine
+	S []Ciphertext
+}
+
+// Prove proves st from v, the blinding factor gamma of V and the randomness
+// r[k] of each ciphertext. The blinding factors of the bits are drawn so they
+// add up to gamma and r[k], which makes the bits add up to V and the
+// ciphertexts.
+func Prove(st Statement, v *big.Int, gamma *big.Int, r []*big.Int) (Proof, error) {
+	if st.N <= 0 || len(st.Keys) != len(st.Ciphertexts) || len(r) != len(st.Keys) {
+		return Proof{}, ErrStatement
+	}
+	if v.Sign() < 0 || v.BitLen() > st.N {
+		return Proof{}, fmt.Errorf("%w: %v has more than %d bits", ErrOutOfRange, v, st.N)
+	}
+	p := fr.Modulus()
+	gammas := split(gamma, st.N, p)
+	rhos := make([][]*big.Int, len(r))
+	for k := range r {
+		rhos[k] = split(r[k], st.N, order)
+	}
+
+	proof := Proof{Bits: make([]Bit, st.N)}
+	sent := make([][2]commitments, st.N)
+	kg := make([]*big.Int, st.N)
+	kr := make([][]*big.Int, st.N)
+	for i := range proof.Bits {
+		b := int(v.Bit(i))
+		bit := &proof.Bits[i]
+		bit.C = commit(st.G, st.H, big.NewInt(int64(b)), gammas[i])
+		for k, key := range st.Keys {
+			bit.D = append(bit.D, key.encrypt(big.NewInt(int64(b)), rhos[k][i]))
+		}
+
+		// the branch of the other bit value is simulated from its challenge
+		bit.E[1-b] = random(challengeBound)
+		bit.SG[1-b] = random(p)
+		for range st.Keys {
+			bit.SR[1-b] = append(bit.SR[1-b], random(order))
+		}
+		sent[i][1-b] = st.commitments(bit, 1-b)
+
+		kg[i] = random(p)
+		sent[i][b].R.ScalarMultiplication(&st.H, kg[i])
+		for _, key := range st.Keys {
+			k := random(order)
+			kr[i] = append(kr[i], k)
+			sent[i][b].S = append(sent[i][b].S, key.encrypt(big.NewInt(0), k))
+		}
+	}
+
+	e := st.challenge(proof, sent)
+	for i := range proof.Bits {
+		b := int(v.Bit(i))
+		bit := &proof.Bits[i]
+		bit.E[b] = new(big.Int).Xor(e, bit.E[1-b])
+		bit.SG[b] = response(kg[i], bit.E[b], gammas[i], p)
+		bit.SR[b] = make([]*big.Int, len(st.Keys))
+		for k := range st.Keys {
+			bit.SR[b][k] = response(kr[i][k], bit.E[b], rhos[k][i], order)
+		}
+	}
+	return proof, nil
+}
+
+// Verify checks proof against st.
+func Verify(st Statement, proof Proof) error {
+	if st.N <= 0 || len(st.Keys) != len(st.Ciphertexts) || len(proof.Bits) != st.N {
+		return ErrStatement
+	}
+	for _, bit := range proof.Bits {
+		if len(bit.D) != len(st.Keys) || !bit.C.IsInSubGroup() {
+			return ErrStatement
+		}
+		// a small order part of D would survive the OR proof
+		for _, d := range bit.D {
+			if !inSubgroup(&d.A) || !inSubgroup(&d.B) {
+				return ErrStatement
+			}
+		}
+		for j := 0; j < 2; j++ {
+			if !below(bit.E[j], challengeBound) || !below(bit.SG[j], fr.Modulus()) || len(bit.SR[j]) != len(st.Keys) {
+				return ErrStatement
+			}
+			for _, s := range bit.SR[j] {
+				if !below(s, order) {
+					return ErrStatement
+				}
+			}
+		}
+	}
+
+	sent := make([][2]commitments, st.N)
+	for i := range proof.Bits {
+		sent[i][0] = st.commitments(&proof.Bits[i], 0)
+		sent[i][1] = st.commitments(&proof.Bits[i], 1)
+	}
+	e := st.challenge(proof, sent)
+	for i, bit := range proof.Bits {
+		if new(big.Int).Xor(bit.E[0], bit.E[1]).Cmp(e) != 0 {
+			return fmt.Errorf("%w: bit %d is neither 0 nor 1", ErrProof, i)
+		}
+	}
+
+	// V=Σ 2^i*C_i and Ciphertexts[k]=Σ 2^i*D_i[k]
+	var V bn254.G1Affine
+	for i := st.N - 1; i >= 0; i-- {
+		V.Double(&V)
+		V.Add(&V, &proof.Bits[i].C)
+	}
+	if !V.Equal(&st.V) {
+		return fmt.Errorf("%w: the bits do not add up to V", ErrProof)
+	}
+	for k, c := range st.Ciphertexts {
+		var A, B curve.PointAffine
+		A.Y.SetOne()
+		B.Y.SetOne()
+		for i := st.N - 1; i >= 0; i-- {
+			A.Double(&A)
+			A.Add(&A, &proof.Bits[i].D[k].A)
+			B.Double(&B)
+			B.Add(&B, &proof.Bits[i].D[k].B)
+		}
+		if !A.Equal(&c.A) || !B.Equal(&c.B) {
+			return fmt.Errorf("%w: the bits do not add up to ciphertext %d", ErrProof, k)
+		}
+	}
+	return nil
+}
+
+// commitments recomputes the first message of the branch b=j of bit from its
+// challenge and responses: R=SG*H-E*(C-j*G) and S[k]=SR[k]*(Pk,H)-E*(D[k]-j*M).
+func (st Statement) commitments(bit *Bit, j int) commitments {
+	var res commitments
+	C := bit.C
+	if j == 1 {
+		C.Sub(&C, &st.G)
+	}
+	var eC bn254.G1Affine
+	eC.ScalarMultiplication(&C, bit.E[j])
+	res.R.ScalarMultiplication(&st.H, bit.SG[j])
+	res.R.Sub(&res.R, &eC)
+	for k, key := range st.Keys {
+		D := bit.D[k]
+		if j == 1 {
+			D.A.Add(&D.A, new(curve.PointAffine).Neg(&key.M))
+		}
+		S := key.encrypt(big.NewInt(0), bit.SR[j][k])
+		S.A.Add(&S.A, new(curve.PointAffine).Neg(new(curve.PointAffine).ScalarMultiplication(&D.A, bit.E[j])))
+		S.B.Add(&S.B, new(curve.PointAffine).Neg(new(curve.PointAffine).ScalarMultiplication(&D.B, bit.E[j])))
+		res.S = append(res.S, S)
+	}
+	return res
+}
+
+// challenge absorbs st, the bits and the first messages of both branches of
+// every bit, and squeezes the one challenge they share.
+func (st Statement) challenge(proof Proof, sent [][2]commitments) *big.Int {
+	t := transcript.New("crossgroup")
+	t.AppendInt("n", int64(st.N))
+	t.AppendPoint("G", &st.G)
+	t.AppendPoint("H", &st.H)
+	t.AppendPoint("V", &st.V)
+	t.AppendInt("ciphertexts", int64(len(st.Keys)))
+	for k := range st.Keys {
+		t.AppendPoint("M", &st.Keys[k].M)
+		t.AppendPoint("Pk", &st.Keys[k].Pk)
+		t.AppendPoint("H", &st.Keys[k].H)
+		t.AppendPoint("A", &st.Ciphertexts[k].A)
+		t.AppendPoint("B", &st.Ciphertexts[k].B)
+	}
+	for i := range proof.Bits {
+		bit := &proof.Bits[i]
+		t.AppendPoint("C", &bit.C)
+		for k := range bit.D {
+			t.AppendPoint("D.A", &bit.D[k].A)
+			t.AppendPoint("D.B", &bit.D[k].B)
+		}
+		for j := range sent[i] {
+			t.AppendPoint("R", &sent[i][j].R)
+			for k := range sent[i][j].S {
+				t.AppendPoint("S.A", &sent[i][j].S[k].A)
+				t.AppendPoint("S.B", &sent[i][j].S[k].B)
+			}
+		}
+	}
+	return t.Challenge("e", challengeBound)
+}
+
+func (k Key) encrypt(v *big.Int, r *big.Int) Ciphertext {
+	var c Ciphertext
+	c.A.ScalarMultiplication(&k.M, v)
+	c.A.Add(&c.A, new(curve.PointAffine).ScalarMultiplication(&k.Pk, r))
+	c.B.ScalarMultiplication(&k.H, r)
+	return c
+}
+
+func commit(G bn254.G1Affine, H bn254.G1Affine, v *big.Int, gamma *big.Int) bn254.G1Affine {
+	var c, _H bn254.G1Affine
+	c.ScalarMultiplication(&G, v)
+	_H.ScalarMultiplication(&H, gamma)
+	c.Add(&c, &_H)
+	return c
+}
+
+// split draws n blinding factors x_i with Σ 2^i*x_i=x mod m.
+func split(x *big.Int, n int, m *big.Int) []*big.Int {
+	xs := make([]*big.Int, n)
+	x0 := new(big.Int).Set(x)
+	for i := n - 1; i > 0; i-- {
+		xs[i] = random(m)
+		x0.Sub(x0, new(big.Int).Lsh(xs[i], uint(i)))
+	}
+	xs[0] = x0.Mod(x0, m)
+	return xs
+}
+
+// response is k+e*w mod m.
+func response(k *big.Int, e *big.Int, w *big.Int, m *big.Int) *big.Int {
+	s := new(big.Int).Mul(e, w)
+	s.Add(s, k)
+	return s.Mod(s, m)
+}
+
+func random(m *big.Int) *big.Int {
+	r, _ := rand.Int(rand.Reader, m)
+	return r
+}
+
+func inSubgroup(p *curve.PointAffine) bool {
+	if !p.IsOnCurve() {
+		return false
+	}
+	return new(curve.PointAffine).ScalarMultiplication(p, order).IsZero()
+}
+
+func below(x *big.Int, m *big.Int) bool {
+	return x != nil && x.Sign() >= 0 && x.Cmp(m) < 0
+}
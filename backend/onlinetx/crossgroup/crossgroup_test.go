@@ -0,0 +1,77 @@
+package crossgroup
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	bn254 "github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+)
+
+func statement(t *testing.T, n int, v *big.Int, encrypted ...*big.Int) (Statement, *big.Int, []*big.Int) {
+	t.Helper()
+	_, _, g, _ := bn254.Generators()
+	var h bn254.G1Affine
+	h.ScalarMultiplication(&g, random(fr.Modulus()))
+	gamma := random(fr.Modulus())
+	st := Statement{N: n, G: g, H: h, V: commit(g, h, v, gamma)}
+
+	base := curve.GetEdwardsCurve().Base
+	var rs []*big.Int
+	for _, w := range encrypted {
+		var key Key
+		key.M.ScalarMultiplication(&base, random(order))
+		key.Pk.ScalarMultiplication(&base, random(order))
+		key.H.ScalarMultiplication(&base, random(order))
+		r := random(order)
+		st.Keys = append(st.Keys, key)
+		st.Ciphertexts = append(st.Ciphertexts, key.encrypt(w, r))
+		rs = append(rs, r)
+	}
+	return st, gamma, rs
+}
+
+func TestCrossGroup(t *testing.T) {
+	v := big.NewInt(100)
+	st, gamma, rs := statement(t, 32, v, v, v)
+	proof, err := Prove(st, v, gamma, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(st, proof); err != nil {
+		t.Fatal(err)
+	}
+	if proof.Size() != 32*(32+32+64+2*4*32) {
+		t.Errorf("size %d", proof.Size())
+	}
+
+	// the second ciphertext encrypts another value than V commits to
+	st, gamma, rs = statement(t, 32, v, v, big.NewInt(101))
+	proof, err = Prove(st, v, gamma, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(st, proof); !errors.Is(err, ErrProof) {
+		t.Errorf("different values: %v", err)
+	}
+
+	st, gamma, rs = statement(t, 8, v, v)
+	proof, err = Prove(st, v, gamma, rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof.Bits[3].SR[1][0] = new(big.Int).Add(proof.Bits[3].SR[1][0], big.NewInt(1))
+	if err := Verify(st, proof); !errors.Is(err, ErrProof) {
+		t.Errorf("tampered response: %v", err)
+	}
+	proof.Bits = proof.Bits[1:]
+	if err := Verify(st, proof); !errors.Is(err, ErrStatement) {
+		t.Errorf("missing bit: %v", err)
+	}
+
+	if _, err := Prove(st, big.NewInt(256), gamma, rs); !errors.Is(err, ErrOutOfRange) {
+		t.Errorf("256 in 8 bits: %v", err)
+	}
+}
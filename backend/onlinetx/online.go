@@ -1,13 +1,14 @@
 package onlinetx
 
 import (
+	"Asyn_CBDC/backend/issuer"
 	"Asyn_CBDC/backend/onlinetx/bulletproof"
 	"Asyn_CBDC/backend/onlinetx/sigma"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
 
-	eccfr "github.com/consensys/gnark-crypto/ecc/bn254"
 	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
 	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
@@ -23,70 +24,155 @@ type sigmaProof struct {
 	commit    []sigma.CommitMent
 	commitenc [][]curve.PointAffine
 	response  []sigma.Response
-	challenge big.Int
 }
 
-type bulletProof struct {
-	commitV  eccfr.G1Affine
-	commitA  eccfr.G1Affine
-	commitS  eccfr.G1Affine
-	chall_y  big.Int
-	chall_z  big.Int
-	commitT1 eccfr.G1Affine
-	commitT2 eccfr.G1Affine
-	chall_x  big.Int
-	rp_taux  *big.Int
-	rp_miu   *big.Int
-	rp_lx    []*big.Int
-	rp_rx    []*big.Int
-	rp_tx    *big.Int
-	bpPara   bulletproof.BulletParams
+var (
+	ErrSigmaRejected   = errors.New("onlinetx: sigma proof rejected")
+	ErrRangeRejected   = errors.New("onlinetx: range proof rejected")
+	ErrBindingRejected = errors.New("onlinetx: range proof is not bound to the ciphertexts")
+)
+
+// Measurement is the cost of one online proof: the time to prove and to verify
+// it, and its size in bytes.
+type Measurement struct {
+	Name   string
+	Prove  time.Duration
+	Verify time.Duration
+	Size   int
 }
 
-func Verify() {
+// Measure runs an online payment of 100 out of a balance of 200, proves and
+// verifies every sigma protocol of the sender and the receiver, the one
+// aggregated range proof of each and the proofs that bind its commitments to
+// the ciphertexts, and returns what each one cost. HoldingLimit reuses the
+// sigma protocol of FreqLimit. Each protocol spends a fresh offline account
+// signed by is. Measure fails if a proof is rejected.
+func Measure(is *issuer.Issuer) ([]Measurement, error) {
 	curveid := ecctedwards.BN254
 	params, _ := twistededwards.GetCurveParams(curveid)
-	modulus := fr.Modulus()
 
+	var ms []Measurement
+	sigmaProved := func(name string, p sigmaProof, prove time.Duration, verify func() (time.Duration, error)) error {
+		t, err := verify()
+		if err != nil {
+			return err
+		}
+		ms = append(ms, Measurement{Name: name, Prove: prove, Verify: t, Size: p.size()})
+		return nil
+	}
+	// the first len(bindings) values are bound to their ciphertexts
+	rangeProved := func(side string, nums []*big.Int, bpPara bulletproof.BulletParams, bindings ...binding) error {
+		start := time.Now()
+		proof, gammas, err := bulletproof.ProveRange(bpPara, nums)
+		if err != nil {
+			return fmt.Errorf("%s/range: %w", side, err)
+		}
+		prove := time.Since(start)
+		t, err := verifyRangeProof(proof)
+		if err != nil {
+			return fmt.Errorf("%s/range: %w", side, err)
+		}
+		ms = append(ms, Measurement{Name: side + "/range", Prove: prove, Verify: t, Size: proof.Size()})
+
+		start = time.Now()
+		bound, err := proveBindings(proof, nums, gammas, bindings)
+		if err != nil {
+			return fmt.Errorf("%s/binding: %w", side, err)
+		}
+		prove = time.Since(start)
+		t, err = verifyBindings(proof, bindings, bound)
+		if err != nil {
+			return fmt.Errorf("%s/binding: %w", side, err)
+		}
+		size := 0
+		for _, p := range bound {
+			size += p.Size()
+		}
+		ms = append(ms, Measurement{Name: side + "/binding", Prove: prove, Verify: t, Size: size})
+		return nil
+	}
+
+	o, err := offlineAccount(params, curveid, is)
+	if err != nil {
+		return nil, err
+	}
 	var s sender
-	s, s_sigmaproof, s_bpv, s_bpbal, s_holding, s_date, s_zkptimewithFreqlimitRegulation, s_zkptimewithHoldinglimitRegulation, s_zkptimewithNolimitRegulation, s_zkptimewithNoRegulation := s.zkpProof(params, curveid, modulus)
-	fmt.Printf("time of sender zkpGenwithFreqlimitRegulation:%fms\n\n", float64(s_zkptimewithFreqlimitRegulation)/1000)
-	fmt.Printf("time of sender zkpGenwithHoldinglimitRegulation:%fms\n\n", float64(s_zkptimewithHoldinglimitRegulation)/1000)
-	fmt.Printf("time of sender zkpGenwithNolimitRegulation:%fms\n\n", float64(s_zkptimewithNolimitRegulation)/1000)
-	fmt.Printf("time of sender zkpGenwithNoRegulation:%fms\n\n", float64(s_zkptimewithNoRegulation)/1000)
-	s_verifysigmawithFreqlimitRegulation := verifySenderSigmaProtocolwithFreqlimitRegulation(s, s_sigmaproof)
-	s_verifysigmawithHoldinglimitRegulation := verifySenderSigmaProtocolwithNolimitRegulation(s, s_sigmaproof)
-	s_verifysigmawithNolimitRegulation := verifySenderSigmaProtocolwithNolimitRegulation(s, s_sigmaproof)
-	s_verifysigmawithNoRegulation := verifySenderSigmaProtocolwithNoRegulation(s, s_sigmaproof)
-
-	//fmt.Println("bulletproof_transaction amount:")
-	s_bp1 := verifyBulletProof(s_bpv)
-	//fmt.Println("bulletproof_account balance:")
-	s_bp2 := verifyBulletProof(s_bpbal)
-	//fmt.Println("bulletproof_holding limit:")
-	s_bp3 := verifyBulletProof(s_holding)
-	//fmt.Println("bulletproof_date limit:")
-	s_bp4 := verifyBulletProof(s_date)
-	fmt.Printf("time of verifywithFreqlimitRegulation sender:%fms\n\n", float64(s_verifysigmawithFreqlimitRegulation.Microseconds()+s_bp1.Microseconds()+s_bp2.Microseconds()+s_bp3.Microseconds()+s_bp4.Microseconds())/1000)
-	fmt.Printf("time of verifywithHoldinglimitRegulation sender:%fms\n\n", float64(s_verifysigmawithHoldinglimitRegulation.Microseconds()+s_bp1.Microseconds()+s_bp2.Microseconds()+s_bp3.Microseconds())/1000)
-	fmt.Printf("time of verifywithNolimitRegulation sender:%fms\n\n", float64(s_verifysigmawithNolimitRegulation.Microseconds()+s_bp1.Microseconds()+s_bp2.Microseconds())/1000)
-	fmt.Printf("time of verifywithNoRegulation sender:%fms\n\n", float64(s_verifysigmawithNoRegulation.Microseconds()+s_bp1.Microseconds()+s_bp2.Microseconds())/1000)
-
+	freq, s, t_freq := s.sigmaprotocolwithFreqlimitRegulation(params, o)
+	if err := sigmaProved("sender/sigma/freqlimit", freq, t_freq, func() (time.Duration, error) {
+		return verifySenderSigmaProtocolwithFreqlimitRegulation(s, freq)
+	}); err != nil {
+		return nil, err
+	}
+	o, err = offlineAccount(params, curveid, is)
+	if err != nil {
+		return nil, err
+	}
+	nolimit, s_nolimit, t_nolimit := s.sigmaprotocolwithNolimitRegulation(params, o)
+	if err := sigmaProved("sender/sigma/nolimit", nolimit, t_nolimit, func() (time.Duration, error) {
+		return verifySenderSigmaProtocolwithNolimitRegulation(s_nolimit, nolimit)
+	}); err != nil {
+		return nil, err
+	}
+	o, err = offlineAccount(params, curveid, is)
+	if err != nil {
+		return nil, err
+	}
+	noreg, s_noreg, t_noreg := s.sigmaprotocolwithNoRegulation(params, o)
+	if err := sigmaProved("sender/sigma/noregulation", noreg, t_noreg, func() (time.Duration, error) {
+		return verifySenderSigmaProtocolwithNoRegulation(s_noreg, noreg)
+	}); err != nil {
+		return nil, err
+	}
+
+	// one aggregated range proof per side of the transaction
+	var bpPara bulletproof.BulletParams
+	bpPara = bpPara.AggregateParamsGen(4)
+	bal_v := new(big.Int).Sub(&s.bal, &s.v)
+	// amount, balance left, holding and date
+	if err := rangeProved("sender", []*big.Int{&s.v, bal_v, big.NewInt(200), big.NewInt(200)}, bpPara, s.amount(), s.change()); err != nil {
+		return nil, err
+	}
+
+	o, err = offlineAccount(params, curveid, is)
+	if err != nil {
+		return nil, err
+	}
 	var r receiver
-	r, r_sigmaproof, r_bpbal, r_holding, r_date, r_zkptime := r.zkpProof(params, curveid, modulus, s)
-	fmt.Printf("time of receiver zkpGen:%fms\n\n", float64(r_zkptime)/1000)
-	r_verifysigma := verifyReceiverSigmaProtocol(r, r_sigmaproof)
-	//fmt.Println("bulletproof_account balance:")
-	r_bp1 := verifyBulletProof(r_bpbal)
-	//fmt.Println("bulletproof_holding limit:")
-	r_bp2 := verifyBulletProof(r_holding)
-	//fmt.Println("bulletproof_date limit:")
-	r_bp3 := verifyBulletProof(r_date)
-	fmt.Printf("time of verify receiver:%fms\n\n", float64(r_verifysigma.Microseconds()+r_bp1.Microseconds()+r_bp2.Microseconds()+r_bp3.Microseconds())/1000)
+	recv, r, t_recv := r.sigmaprotocol(params, o, s)
+	if err := sigmaProved("receiver/sigma", recv, t_recv, func() (time.Duration, error) {
+		return verifyReceiverSigmaProtocol(r, recv)
+	}); err != nil {
+		return nil, err
+	}
+	// balance, holding and date, padded to 4 values
+	if err := rangeProved("receiver", []*big.Int{&r.bal, big.NewInt(200), big.NewInt(200)}, bpPara, r.balance()); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
 
+// rejected names the first of checks that fails, numbered from 1 like the
+// constraints of the protocol.
+func rejected(err error, what string, checks ...bool) error {
+	for i, ok := range checks {
+		if !ok {
+			return fmt.Errorf("%w: %s constraint %d", err, what, i+1)
+		}
+	}
+	return nil
 }
 
-func verifySenderSigmaProtocolwithFreqlimitRegulation(s sender, sigmaproof sigmaProof) time.Duration {
+// size is the encoded size of p: its commitments and responses. The verifier
+// recomputes the challenge.
+func (p sigmaProof) size() int {
+	n := len(p.commit) + len(p.response)
+	for _, c := range p.commitenc {
+		n += len(c)
+	}
+	return n * fr.Bytes
+}
+
+func verifySenderSigmaProtocolwithFreqlimitRegulation(s sender, sigmaproof sigmaProof) (time.Duration, error) {
 	commit_s := sigmaproof.commit[0]
 	commit_sh := sigmaproof.commit[1]
 	commit_r := sigmaproof.commit[2]
@@ -106,11 +192,11 @@ func verifySenderSigmaProtocolwithFreqlimitRegulation(s sender, sigmaproof sigma
 	rp_date := sigmaproof.response[8]
 	rp_dater := sigmaproof.response[9]
 
-	challenge := sigmaproof.challenge
-
 	/* */
 	starttime := time.Now()
 
+	challenge := s.challenge(freqLimit, sigmaproof)
+
 	var rp_gh curve.PointAffine
 	rp_gh.Add(new(curve.PointAffine).ScalarMultiplication(&s.dateg, &rp_date.Rp), new(curve.PointAffine).ScalarMultiplication(&s.dateh, &rp_dater.Rp))
 	var commit_gh curve.PointAffine
@@ -152,16 +238,6 @@ func verifySenderSigmaProtocolwithFreqlimitRegulation(s sender, sigmaproof sigma
 
 	endtime := time.Now()
 
-	/* debug */
-	fmt.Println("verifywithFreqlimitRegulation sender sigma:")
-	fmt.Println("constraint1:", rp_sr_h.Equal(&commit_sh_chal_txsb))
-	fmt.Println("constraint2:", rp_sr_pk_rp_sv_g0.Equal(&commit_s_chal_txsa))
-	fmt.Println("constraint3:", rp_rr_h.Equal(&commit_rh_chal_txrb))
-	fmt.Println("constraint4:", rp_rr_pk_rp_rv_g0.Equal(&commit_r_chal_txra))
-	fmt.Println("constraint5:", commit_gh.Equal(&rp_gh))
-	fmt.Println("constraint6:", (commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0])) && (commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1])))
-	fmt.Println("constraint7:", (commit_v1_chal_v1.Equal(&cipher_rp_v[0])) && (commit_v2_chal_v2.Equal(&cipher_rp_v[1])))
-
 	/*fmt.Println("rp_sr*h==commit_sh+challenge*txs.c2:", rp_sr_h.Equal(&commit_sh_chal_txsb))
 	fmt.Println("rp_sr*pk+rp_sv*g0==commit_s+challenge*txs.c1:", rp_sr_pk_rp_sv_g0.Equal(&commit_s_chal_txsa))
 	fmt.Println("rp_rr*h==commit_rh+challenge*txr.c2:", rp_rr_h.Equal(&commit_rh_chal_txrb))
@@ -170,11 +246,18 @@ func verifySenderSigmaProtocolwithFreqlimitRegulation(s sender, sigmaproof sigma
 	fmt.Println("Enc(rp_bal)==commit_bal+challenge*cipher_bal:", (commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0])) && (commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1])))
 	fmt.Println("Enc(rp_v)==commit_v+challenge*cipher_v:", (commit_v1_chal_v1.Equal(&cipher_rp_v[0])) && (commit_v2_chal_v2.Equal(&cipher_rp_v[1])))*/
 
-	//fmt.Println("verify:", endtime.Sub(starttime))
-	return endtime.Sub(starttime)
+	return endtime.Sub(starttime), rejected(ErrSigmaRejected, "sender freqlimit",
+		rp_sr_h.Equal(&commit_sh_chal_txsb),
+		rp_sr_pk_rp_sv_g0.Equal(&commit_s_chal_txsa),
+		rp_rr_h.Equal(&commit_rh_chal_txrb),
+		rp_rr_pk_rp_rv_g0.Equal(&commit_r_chal_txra),
+		commit_gh.Equal(&rp_gh),
+		commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0]) && commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1]),
+		commit_v1_chal_v1.Equal(&cipher_rp_v[0]) && commit_v2_chal_v2.Equal(&cipher_rp_v[1]),
+	)
 }
 
-func verifySenderSigmaProtocolwithNolimitRegulation(s sender, sigmaproof sigmaProof) time.Duration {
+func verifySenderSigmaProtocolwithNolimitRegulation(s sender, sigmaproof sigmaProof) (time.Duration, error) {
 	commit_s := sigmaproof.commit[0]
 	commit_sh := sigmaproof.commit[1]
 	commit_r := sigmaproof.commit[2]
@@ -192,11 +275,11 @@ func verifySenderSigmaProtocolwithNolimitRegulation(s sender, sigmaproof sigmaPr
 	rp_v := sigmaproof.response[6]
 	rp_v_r := sigmaproof.response[7]
 
-	challenge := sigmaproof.challenge
-
 	/* */
 	starttime := time.Now()
 
+	challenge := s.challenge(noLimit, sigmaproof)
+
 	var rp_sr_h curve.PointAffine
 	rp_sr_h.ScalarMultiplication(&s.dacc.H, &rp_sr.Rp)
 	var commit_sh_chal_txsb curve.PointAffine
@@ -233,15 +316,6 @@ func verifySenderSigmaProtocolwithNolimitRegulation(s sender, sigmaproof sigmaPr
 
 	endtime := time.Now()
 
-	/* debug */
-	fmt.Println("verifywithNolimitRegulation sender sigma:")
-	fmt.Println("constraint1:", rp_sr_h.Equal(&commit_sh_chal_txsb))
-	fmt.Println("constraint2:", rp_sr_pk_rp_sv_g0.Equal(&commit_s_chal_txsa))
-	fmt.Println("constraint3:", rp_rr_h.Equal(&commit_rh_chal_txrb))
-	fmt.Println("constraint4:", rp_rr_pk_rp_rv_g0.Equal(&commit_r_chal_txra))
-	fmt.Println("constraint5:", (commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0])) && (commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1])))
-	fmt.Println("constraint6:", (commit_v1_chal_v1.Equal(&cipher_rp_v[0])) && (commit_v2_chal_v2.Equal(&cipher_rp_v[1])))
-
 	/*fmt.Println("rp_sr*h==commit_sh+challenge*txs.c2:", rp_sr_h.Equal(&commit_sh_chal_txsb))
 	fmt.Println("rp_sr*pk+rp_sv*g0==commit_s+challenge*txs.c1:", rp_sr_pk_rp_sv_g0.Equal(&commit_s_chal_txsa))
 	fmt.Println("rp_rr*h==commit_rh+challenge*txr.c2:", rp_rr_h.Equal(&commit_rh_chal_txrb))
@@ -250,11 +324,17 @@ func verifySenderSigmaProtocolwithNolimitRegulation(s sender, sigmaproof sigmaPr
 	fmt.Println("Enc(rp_bal)==commit_bal+challenge*cipher_bal:", (commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0])) && (commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1])))
 	fmt.Println("Enc(rp_v)==commit_v+challenge*cipher_v:", (commit_v1_chal_v1.Equal(&cipher_rp_v[0])) && (commit_v2_chal_v2.Equal(&cipher_rp_v[1])))*/
 
-	//fmt.Println("verify:", endtime.Sub(starttime))
-	return endtime.Sub(starttime)
+	return endtime.Sub(starttime), rejected(ErrSigmaRejected, "sender nolimit",
+		rp_sr_h.Equal(&commit_sh_chal_txsb),
+		rp_sr_pk_rp_sv_g0.Equal(&commit_s_chal_txsa),
+		rp_rr_h.Equal(&commit_rh_chal_txrb),
+		rp_rr_pk_rp_rv_g0.Equal(&commit_r_chal_txra),
+		commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0]) && commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1]),
+		commit_v1_chal_v1.Equal(&cipher_rp_v[0]) && commit_v2_chal_v2.Equal(&cipher_rp_v[1]),
+	)
 }
 
-func verifySenderSigmaProtocolwithNoRegulation(s sender, sigmaproof sigmaProof) time.Duration {
+func verifySenderSigmaProtocolwithNoRegulation(s sender, sigmaproof sigmaProof) (time.Duration, error) {
 	commit_s := sigmaproof.commit[0]
 	commit_sh := sigmaproof.commit[1]
 	commit_r := sigmaproof.commit[2]
@@ -265,11 +345,11 @@ func verifySenderSigmaProtocolwithNoRegulation(s sender, sigmaproof sigmaProof)
 	rp_sv := sigmaproof.response[2]
 	rp_rv := sigmaproof.response[3]
 
-	challenge := sigmaproof.challenge
-
 	/* */
 	starttime := time.Now()
 
+	challenge := s.challenge(noRegulation, sigmaproof)
+
 	var rp_sr_h curve.PointAffine
 	rp_sr_h.ScalarMultiplication(&s.dacc.H, &rp_sr.Rp)
 	var commit_sh_chal_txsb curve.PointAffine
@@ -292,13 +372,6 @@ func verifySenderSigmaProtocolwithNoRegulation(s sender, sigmaproof sigmaProof)
 
 	endtime := time.Now()
 
-	/* debug */
-	fmt.Println("verifywithNoRegulation sender sigma:")
-	fmt.Println("constraint1:", rp_sr_h.Equal(&commit_sh_chal_txsb))
-	fmt.Println("constraint2:", rp_sr_pk_rp_sv_g0.Equal(&commit_s_chal_txsa))
-	fmt.Println("constraint3:", rp_rr_h.Equal(&commit_rh_chal_txrb))
-	fmt.Println("constraint4:", rp_rr_pk_rp_rv_g0.Equal(&commit_r_chal_txra))
-
 	/*fmt.Println("rp_sr*h==commit_sh+challenge*txs.c2:", rp_sr_h.Equal(&commit_sh_chal_txsb))
 	fmt.Println("rp_sr*pk+rp_sv*g0==commit_s+challenge*txs.c1:", rp_sr_pk_rp_sv_g0.Equal(&commit_s_chal_txsa))
 	fmt.Println("rp_rr*h==commit_rh+challenge*txr.c2:", rp_rr_h.Equal(&commit_rh_chal_txrb))
@@ -307,11 +380,15 @@ func verifySenderSigmaProtocolwithNoRegulation(s sender, sigmaproof sigmaProof)
 	fmt.Println("Enc(rp_bal)==commit_bal+challenge*cipher_bal:", (commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0])) && (commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1])))
 	fmt.Println("Enc(rp_v)==commit_v+challenge*cipher_v:", (commit_v1_chal_v1.Equal(&cipher_rp_v[0])) && (commit_v2_chal_v2.Equal(&cipher_rp_v[1])))*/
 
-	//fmt.Println("verify:", endtime.Sub(starttime))
-	return endtime.Sub(starttime)
+	return endtime.Sub(starttime), rejected(ErrSigmaRejected, "sender noregulation",
+		rp_sr_h.Equal(&commit_sh_chal_txsb),
+		rp_sr_pk_rp_sv_g0.Equal(&commit_s_chal_txsa),
+		rp_rr_h.Equal(&commit_rh_chal_txrb),
+		rp_rr_pk_rp_rv_g0.Equal(&commit_r_chal_txra),
+	)
 }
 
-func verifyReceiverSigmaProtocol(r receiver, sigmaproof sigmaProof) time.Duration {
+func verifyReceiverSigmaProtocol(r receiver, sigmaproof sigmaProof) (time.Duration, error) {
 	//commit_g0g1pk := sigmaproof.commit[0]
 	commit_h := sigmaproof.commit[0]
 	commit_date := sigmaproof.commit[1]
@@ -323,11 +400,11 @@ func verifyReceiverSigmaProtocol(r receiver, sigmaproof sigmaProof) time.Duratio
 	rp_date := sigmaproof.response[3]
 	rp_dater := sigmaproof.response[4]
 
-	challenge := sigmaproof.challenge
-
 	/* */
 	starttime := time.Now()
 
+	challenge := r.challenge(sigmaproof)
+
 	var rp_gh curve.PointAffine
 	rp_gh.Add(new(curve.PointAffine).ScalarMultiplication(&r.dateg, &rp_date.Rp), new(curve.PointAffine).ScalarMultiplication(&r.dateh, &rp_dater.Rp))
 	var commit_gh curve.PointAffine
@@ -349,90 +426,26 @@ func verifyReceiverSigmaProtocol(r receiver, sigmaproof sigmaProof) time.Duratio
 
 	endtime := time.Now()
 
-	/* debug */
-	fmt.Println("verify receiver sigma:")
-
-	fmt.Println("constraint1:", rp_h_h.Equal(&commit_h_pkbeta))
-	fmt.Println("constraint2:", commit_gh.Equal(&rp_gh))
-	fmt.Println("constraint3:", (commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0])) && (commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1])))
-
 	/*fmt.Println("rp_h*h==commit_h+challenge*acc.c2:", rp_h_h.Equal(&commit_h_pkbeta))
 	fmt.Println("verify comment_date:", commit_gh.Equal(&rp_gh))
 	fmt.Println("Enc(rp_bal)==commit_bal+challenge*cipher_bal:", (commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0])) && (commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1])))*/
 
-	//fmt.Println("verify:", endtime.Sub(starttime))
-	return endtime.Sub(starttime)
+	return endtime.Sub(starttime), rejected(ErrSigmaRejected, "receiver",
+		rp_h_h.Equal(&commit_h_pkbeta),
+		commit_gh.Equal(&rp_gh),
+		commit_bal1_chal_bal1.Equal(&cipher_rp_bal[0]) && commit_bal2_chal_bal2.Equal(&cipher_rp_bal[1]),
+	)
 }
 
-func verifyBulletProof(bpv bulletProof) time.Duration {
-	P := bpv.bpPara.P
-	n := bpv.bpPara.N
-	G := bpv.bpPara.G
-	H := bpv.bpPara.H
-	g := bpv.bpPara.Bg
-	h := bpv.bpPara.Bh
-
-	/* */
+// verifyRangeProof rebuilds the generators from the number of commitments
+// instead of trusting params from the prover.
+func verifyRangeProof(proof bulletproof.RangeProof) (time.Duration, error) {
 	starttime := time.Now()
-
-	//know lx,rx,tx
-	veritx := bulletproof.Calculate_tx(bpv.rp_lx, bpv.rp_rx)
-	//know y,z calculate δ(y,z)
-	veriyn := bulletproof.GenerateY(bpv.chall_y, n)
-	veriz2 := big.NewInt(0)
-	veriz2.Mul(&bpv.chall_z, &bpv.chall_z)
-	veriz2.Mod(veriz2, P)
-	veriz3 := big.NewInt(0)
-	veriz3.Mul(veriz2, &bpv.chall_z)
-	veriz3.Mod(veriz3, P)
-	z_z2 := big.NewInt(0)
-	z_z2.Sub(&bpv.chall_z, veriz2)
-	z_z2.Mod(z_z2, P)
-	y1n := bulletproof.Inner_produ(bulletproof.GenerateZ(*big.NewInt(1), n), veriyn)
-	y2n1 := bulletproof.Inner_produ(bulletproof.GenerateZ(*big.NewInt(1), n), bulletproof.Generate2n(n))
-	z_z2y1n := big.NewInt(0)
-	z_z2y1n.Mul(z_z2, y1n)
-	z_z2y1n.Mod(z_z2y1n, P)
-	z3y2n1 := big.NewInt(0)
-	z3y2n1.Mul(veriz3, y2n1)
-	z3y2n1.Mod(z3y2n1, P)
-	delta := big.NewInt(0)
-	delta.Sub(z_z2y1n, z3y2n1)
-	delta.Mod(delta, P)
-	//know tx,taux,V,x,T1,T2,delta(calculated)
-	verix2 := big.NewInt(0)
-	verix2.Mul(&bpv.chall_x, &bpv.chall_x)
-	verix2.Mod(verix2, P)
-	commit0 := bulletproof.Commit(g, h, bpv.rp_tx, bpv.rp_taux)
-	commitVg := bulletproof.Commit(bpv.commitV, g, veriz2, delta)
-	commitT := bulletproof.Commit(bpv.commitT1, bpv.commitT2, &bpv.chall_x, verix2)
-	var commitVgT eccfr.G1Affine
-	commitVgT.Add(&commitVg, &commitT)
-	//know A,S,x,z,y
-	H1 := bulletproof.GenerateH1(H, bpv.chall_y, n, P)
-	commitAS := bulletproof.Commit(bpv.commitA, bpv.commitS, big.NewInt(1), &bpv.chall_x)
-	vec := bulletproof.CalVectorAdd(bulletproof.CalVectorTimes(veriyn, &bpv.chall_z), bulletproof.CalVectorTimes(bulletproof.Generate2n(n), veriz2))
-	commitvec := bulletproof.CommitSingleVector(H1, vec)
-	z1 := bulletproof.GenerateZ1(bpv.chall_z, n)
-	commitz1 := bulletproof.CommitSingleVector(G, z1)
-	var _commitP eccfr.G1Affine
-	_commitP.Add(&commitAS, &commitz1)
-	var commitP eccfr.G1Affine
-	commitP.Add(&_commitP, &commitvec)
-	//know miu,lx,rx
-	commitmiu := bulletproof.CommitSingle(h, bpv.rp_miu)
-
-	commitlr := bulletproof.CommitVectors(G, H1, bpv.rp_lx, bpv.rp_rx)
-	var verip eccfr.G1Affine
-	verip.Add(&commitmiu, &commitlr)
-
+	bpPara := bulletproof.NewParams(bulletproof.Bits, int64(len(proof.V)))
+	err := bulletproof.VerifyRange(bpPara, proof)
 	endtime := time.Now()
-
-	/* debug */
-	fmt.Println("tx==<lx,rx>:(true is 0)", veritx.Cmp(bpv.rp_tx))
-	fmt.Println("commit0==commitVgT:", commit0.Equal(&commitVgT))
-	fmt.Println("veriP==commitP:", verip.Equal(&commitP))
-
-	//fmt.Println("verify:", endtime.Sub(starttime))
-	return endtime.Sub(starttime)
+	if err != nil {
+		return endtime.Sub(starttime), fmt.Errorf("%w: %w", ErrRangeRejected, err)
+	}
+	return endtime.Sub(starttime), nil
 }
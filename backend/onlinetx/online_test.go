@@ -1,7 +1,178 @@
 package onlinetx
 
-import "testing"
+import (
+	"Asyn_CBDC/backend/offlinetx"
+	"Asyn_CBDC/backend/onlinetx/bulletproof"
+	"Asyn_CBDC/backend/onlinetx/crossgroup"
+	"Asyn_CBDC/backend/regulator"
+	"Asyn_CBDC/backend/util"
+	"Asyn_CBDC/internal/testutil"
+	"errors"
+	"math/big"
+	"testing"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+)
+
+// testAccount is a fresh test account spent offline, signed by an issuer of
+// its own.
+func testAccount(t *testing.T) offlinetx.Offline {
+	t.Helper()
+	is, err := testutil.Issuer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	o, err := offlineAccount(params, ecctedwards.BN254, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return o
+}
 
 func TestOnlinetx(t *testing.T) {
-	Verify()
+	is, err := testutil.Issuer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := Measure(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 8 {
+		t.Fatalf("%d measurements, want 8", len(ms))
+	}
+	for _, m := range ms {
+		if m.Size == 0 || m.Prove <= 0 || m.Verify <= 0 {
+			t.Errorf("%s: %+v", m.Name, m)
+		}
+	}
+}
+
+func TestRejectsTamperedProof(t *testing.T) {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	var s sender
+	p, s, _ := s.sigmaprotocolwithNoRegulation(params, testAccount(t))
+	p.response[2].Rp.Add(&p.response[2].Rp, big.NewInt(1))
+	if _, err := verifySenderSigmaProtocolwithNoRegulation(s, p); !errors.Is(err, ErrSigmaRejected) {
+		t.Errorf("tampered sigma response: %v", err)
+	}
+
+	// the challenges of the modes are domain separated
+	nolimit, s, _ := s.sigmaprotocolwithNolimitRegulation(params, testAccount(t))
+	if _, err := verifySenderSigmaProtocolwithNolimitRegulation(s, nolimit); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifySenderSigmaProtocolwithNoRegulation(s, nolimit); !errors.Is(err, ErrSigmaRejected) {
+		t.Errorf("nolimit proof accepted as noregulation: %v", err)
+	}
+
+	var bpPara bulletproof.BulletParams
+	bpPara = bpPara.AggregateParamsGen(4)
+	proof, _, err := bulletproof.ProveRange(bpPara, []*big.Int{big.NewInt(100), big.NewInt(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proof.V) != 2 || len(proof.IPP.L) != 6 {
+		t.Errorf("%d values in %d inner product rounds, want 2 in 6", len(proof.V), len(proof.IPP.L))
+	}
+	tx := proof.Tx
+	proof.Tx = new(big.Int).Add(tx, big.NewInt(1))
+	if _, err := verifyRangeProof(proof); !errors.Is(err, ErrRangeRejected) {
+		t.Errorf("tampered range proof: %v", err)
+	}
+	proof.Tx = tx
+	proof.IPP.A = new(big.Int).Add(proof.IPP.A, big.NewInt(1))
+	if _, err := verifyRangeProof(proof); !errors.Is(err, bulletproof.ErrInnerProduct) {
+		t.Errorf("tampered inner product: %v", err)
+	}
+}
+
+func TestBindsRangeProofToCiphertexts(t *testing.T) {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	var s sender
+	_, s, _ = s.sigmaprotocolwithNolimitRegulation(params, testAccount(t))
+	bindings := []binding{s.amount(), s.change()}
+	bpPara := bulletproof.NewParams(bulletproof.Bits, 2)
+	prove := func(v *big.Int) (bulletproof.RangeProof, []crossgroup.Proof) {
+		nums := []*big.Int{v, new(big.Int).Sub(&s.bal, v)}
+		proof, gammas, err := bulletproof.ProveRange(bpPara, nums)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bound, err := proveBindings(proof, nums, gammas, bindings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return proof, bound
+	}
+
+	proof, bound := prove(&s.v)
+	if _, err := verifyBindings(proof, bindings, bound); err != nil {
+		t.Fatal(err)
+	}
+	// the range proof of another amount, which the ciphertexts do not hold
+	lie, lieBound := prove(big.NewInt(150))
+	if _, err := verifyRangeProof(lie); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verifyBindings(lie, bindings, lieBound); !errors.Is(err, ErrBindingRejected) || !errors.Is(err, crossgroup.ErrProof) {
+		t.Errorf("range proof of 150 bound to an amount of %v: %v", &s.v, err)
+	}
+	if _, err := verifyBindings(lie, bindings, bound); !errors.Is(err, ErrBindingRejected) {
+		t.Errorf("binding of another range proof: %v", err)
+	}
+}
+
+// TestThresholdRegulator decrypts the amounts an online sender encrypts to a
+// regulator key shared by 3 parties, 2 of which suffice.
+func TestThresholdRegulator(t *testing.T) {
+	rnd := util.NewSeededReader([]byte(t.Name()))
+	g, shares, err := regulator.Simulate(3, 2, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	is, err := testutil.Issuer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := offlinetx.Offline{Apk: g.PublicKey(), Issuer: is}
+	o, err = o.Execution(params, hash.MIMC_BN254, ecctedwards.BN254, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s sender
+	recipient := util.Publickey{Pk: o.Pk.Pk}
+	s = s.execution(params, util.RandomScalar(rnd, params.Order), util.RandomScalar(rnd, params.Order), recipient, *big.NewInt(100), o)
+
+	for _, c := range []struct {
+		name   string
+		cipher []curve.PointAffine
+		want   uint64
+	}{
+		{"cipher_bal", s.cipher_bal, 200},
+		{"cipher_v", s.cipher_v, 100},
+	} {
+		var pds []regulator.PartialDecryption
+		for _, sh := range shares[1:] {
+			pd, err := sh.Decrypt(c.cipher, rnd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pds = append(pds, pd)
+		}
+		m, err := g.Combine(c.cipher, pds)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		v, err := regulator.DecodeAmount(m, 1_000)
+		if err != nil || v != c.want {
+			t.Errorf("%s: %d, %v, want %d", c.name, v, err, c.want)
+		}
+	}
 }
@@ -1,8 +1,8 @@
 package onlinetx
 
 import (
+	"Asyn_CBDC/backend/generator"
 	"Asyn_CBDC/backend/offlinetx"
-	"Asyn_CBDC/backend/onlinetx/bulletproof"
 	"Asyn_CBDC/backend/onlinetx/sigma"
 	"Asyn_CBDC/backend/util"
 	"crypto/rand"
@@ -10,8 +10,6 @@ import (
 	"time"
 
 	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
-	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
-	"github.com/consensys/gnark-crypto/hash"
 	"github.com/consensys/gnark/std/algebra/native/twistededwards"
 )
 
@@ -46,6 +44,7 @@ func (r receiver) execution(params *twistededwards.CurveParams, s sender, o offl
 	r.beta = s.beta
 	r.dacc = o.Deriveacc
 	r.bal = o.Bal
+	r.apk = o.Apk
 	r.dateg = o.CommentG
 	r.dateh = o.CommentH
 	r.commentdate = *o.Comment
@@ -56,25 +55,16 @@ func (r receiver) execution(params *twistededwards.CurveParams, s sender, o offl
 	rb = rb.Add(rb, big.NewInt(10)).Mod(rb, params.Order)
 	r.r_bal = rb
 
-	var _trans curve.PointAffine
-	_trans.X.SetBigInt(params.Base[0])
-	_trans.Y.SetBigInt(params.Base[1])
+	_trans := generator.Trans
 	r._trans = _trans
 	aplain_bal := new(curve.PointAffine).ScalarMultiplication(&_trans, &r.bal)
-	var h curve.PointAffine
-	h.X.SetBigInt(params.Base[0])
-	h.Y.SetBigInt(params.Base[1])
+	h := generator.H
 	r.h = h
 	r.cipher_bal = r.apk.Encrypt(aplain_bal, r.r_bal, r.h)
 	return r
 }
 
-func (r receiver) sigmaprotocol(params *twistededwards.CurveParams, curveid ecctedwards.ID, s sender) (sigmaProof, receiver, time.Duration) {
-	hashFunc := hash.MIMC_BN254
-
-	var o offlinetx.Offline
-	o = o.Execution(params, hashFunc, curveid)
-
+func (r receiver) sigmaprotocol(params *twistededwards.CurveParams, o offlinetx.Offline, s sender) (sigmaProof, receiver, time.Duration) {
 	r = r.execution(params, s, o)
 
 	sk := r.sk
@@ -99,19 +89,15 @@ func (r receiver) sigmaprotocol(params *twistededwards.CurveParams, curveid ecct
 
 	commit_bal := commit.CommitencValid(para_bal, para_bal_r, r.apk, r.h, r._trans)
 
-	hash := hashFunc.New()
-
-	var data []byte
-	data = append(data, commit_h.Commit.Marshal()...)
-	data = append(data, commit_bal[0].Marshal()...)
-	data = append(data, commit_bal[1].Marshal()...)
-	data = append(data, commit_date.Commit.Marshal()...)
-	data = append(data, r.commentdate.Marshal()...)
-
-	hash.Write(data)
-	_challenge := hash.Sum(nil)
-	var challenge big.Int
-	challenge.SetBytes(_challenge)
+	proof := sigmaProof{
+		commit: []sigma.CommitMent{
+			commit_h, commit_date,
+		},
+		commitenc: [][]curve.PointAffine{
+			commit_bal,
+		},
+	}
+	challenge := r.challenge(proof)
 
 	var rp_h sigma.Response
 	rp_h = rp_h.Response(para_h, challenge, skbeta)
@@ -129,41 +115,8 @@ func (r receiver) sigmaprotocol(params *twistededwards.CurveParams, curveid ecct
 
 	//fmt.Println("sigma----generate commitment,challenge,response cost:", endtime.Sub(starttime))
 
-	return (sigmaProof{
-		commit: []sigma.CommitMent{
-			commit_h, commit_date,
-		},
-		commitenc: [][]curve.PointAffine{
-			commit_bal,
-		},
-		response: []sigma.Response{
-			rp_h, rp_bal, rp_bal_r, rp_date, rp_dater,
-		},
-		challenge: challenge,
-	}), r, endtime.Sub(starttime)
-}
-
-func (_ receiver) zkpProof(params *twistededwards.CurveParams, curveid ecctedwards.ID, frmodulus *big.Int, s sender) (receiver, sigmaProof, bulletProof, bulletProof, bulletProof, int64) {
-	var r receiver
-	var sigmaproof sigmaProof
-	var t_sigmagen time.Duration
-	sigmaproof, r, t_sigmagen = r.sigmaprotocol(params, curveid, s)
-	bal := r.bal
-
-	var bpPara bulletproof.BulletParams
-	bpPara = bpPara.ParamsGen()
-
-	var bp1 bulletProof
-	var t_bp1 time.Duration
-	bp1, t_bp1 = bp1.rangeproof(&bal, bpPara)
-	var holding bulletProof
-	var t_holding time.Duration
-	holding, t_holding = holding.rangeproof(big.NewInt(200), bpPara)
-	var date bulletProof
-	var t_date time.Duration
-	date, t_date = date.rangeproof(big.NewInt(200), bpPara)
-
-	var r_totalzkptime int64
-	r_totalzkptime = t_sigmagen.Microseconds() + t_bp1.Microseconds() + t_holding.Microseconds() + t_date.Microseconds()
-	return r, sigmaproof, bp1, holding, date, r_totalzkptime
+	proof.response = []sigma.Response{
+		rp_h, rp_bal, rp_bal_r, rp_date, rp_dater,
+	}
+	return proof, r, endtime.Sub(starttime)
 }
@@ -1,8 +1,9 @@
 package onlinetx
 
 import (
+	"Asyn_CBDC/backend/generator"
+	"Asyn_CBDC/backend/issuer"
 	"Asyn_CBDC/backend/offlinetx"
-	"Asyn_CBDC/backend/onlinetx/bulletproof"
 	"Asyn_CBDC/backend/onlinetx/sigma"
 	"Asyn_CBDC/backend/util"
 	"crypto/rand"
@@ -39,6 +40,12 @@ type sender struct {
 	date        *big.Int
 }
 
+// offlineAccount is a fresh test account spent offline, signed by is.
+func offlineAccount(params *twistededwards.CurveParams, curveid ecctedwards.ID, is *issuer.Issuer) (offlinetx.Offline, error) {
+	o := offlinetx.Offline{Issuer: is}
+	return o.Execution(params, hash.MIMC_BN254, curveid, rand.Reader)
+}
+
 func (s sender) execution(params *twistededwards.CurveParams, r_txr *big.Int, r_txs *big.Int, r_pk util.Publickey, v big.Int, o offlinetx.Offline) sender {
 	s.v = v
 	s.dacc = o.Deriveacc
@@ -78,28 +85,24 @@ func (s sender) execution(params *twistededwards.CurveParams, r_txr *big.Int, r_
 		B: _txr[1],
 	}
 
-	var _trans curve.PointAffine
-	_trans.X.SetBigInt(params.Base[0])
-	_trans.Y.SetBigInt(params.Base[1])
+	_trans := generator.Trans
 	s._trans = _trans
 	aplain_bal := new(curve.PointAffine).ScalarMultiplication(&_trans, &s.bal)
 	aplain_v := new(curve.PointAffine).ScalarMultiplication(&_trans, &s.v)
-	var h curve.PointAffine
-	h.X.SetBigInt(params.Base[0])
-	h.Y.SetBigInt(params.Base[1])
+	h := generator.H
 	s.h = h
 	s.cipher_bal = s.apk.Encrypt(aplain_bal, s.r_bal, s.h)
 	s.cipher_v = s.apk.Encrypt(aplain_v, s.r_v, s.h)
 	return s
 }
 
-func (s sender) sigmaprotocolwithFreqlimitRegulation(params *twistededwards.CurveParams, curveid ecctedwards.ID) (sigmaProof, sender, time.Duration) {
+func (s sender) sigmaprotocolwithFreqlimitRegulation(params *twistededwards.CurveParams, o offlinetx.Offline) (sigmaProof, sender, time.Duration) {
 	//simulation receiver
 	hashFunc := hash.MIMC_BN254
 	var receiver_bal big.Int
 	receiver_bal.SetString("200", 10)
 	var receiver offlinetx.PrimitiveAccount
-	receiver = receiver.GetAccount(params, hashFunc, receiver_bal, big.NewInt(1))
+	receiver = receiver.GetAccount(params, hashFunc, receiver_bal, big.NewInt(1), rand.Reader)
 	r_pk := receiver.Pk
 
 	/* */
@@ -111,9 +114,6 @@ func (s sender) sigmaprotocolwithFreqlimitRegulation(params *twistededwards.Curv
 	r_txs, _ := rand.Int(rand.Reader, params.Order)
 	r_txs = r_txs.Add(r_txs, big.NewInt(int64(10))).Mod(r_txs, params.Order)
 
-	var o offlinetx.Offline
-	o = o.Execution(params, hashFunc, curveid)
-
 	s = s.execution(params, r_txr, r_txs, r_pk, v, o)
 
 	/* */
@@ -141,28 +141,16 @@ func (s sender) sigmaprotocolwithFreqlimitRegulation(params *twistededwards.Curv
 	commit_bal := commit.CommitencValid(para_bal, para_bal_r, s.apk, s.h, s._trans)
 	commit_v := commit.CommitencValid(para_v, para_v_r, s.apk, s.h, s._trans)
 
-	hash := hashFunc.New()
-
-	var data []byte
-	data = append(data, commit_sh.Commit.Marshal()...)
-	data = append(data, commit_rh.Commit.Marshal()...)
-	data = append(data, commit_s.Commit.Marshal()...)
-	data = append(data, commit_r.Commit.Marshal()...)
-	data = append(data, s.txs.A.Marshal()...)
-	data = append(data, s.txs.B.Marshal()...)
-	data = append(data, s.txr.A.Marshal()...)
-	data = append(data, s.txr.B.Marshal()...)
-	data = append(data, commit_date.Commit.Marshal()...)
-	data = append(data, s.commentdate.Marshal()...)
-	data = append(data, commit_bal[0].Marshal()...)
-	data = append(data, commit_bal[1].Marshal()...)
-	data = append(data, commit_v[0].Marshal()...)
-	data = append(data, commit_v[1].Marshal()...)
-
-	hash.Write(data)
-	_challenge := hash.Sum(nil)
-	var challenge big.Int
-	challenge.SetBytes(_challenge)
+	proof := sigmaProof{
+		commit: []sigma.CommitMent{
+			commit_s, commit_sh, commit_r, commit_rh, commit_date,
+		},
+		commitenc: [][]curve.PointAffine{
+			commit_bal,
+			commit_v,
+		},
+	}
+	challenge := s.challenge(freqLimit, proof)
 
 	var rp_sr sigma.Response
 	rp_sr = rp_sr.Response(para_sh, challenge, s.r_txs)
@@ -190,28 +178,19 @@ func (s sender) sigmaprotocolwithFreqlimitRegulation(params *twistededwards.Curv
 
 	//fmt.Println("sigma----generate commitment,challenge,response cost:", endtime.Sub(starttime))
 
-	return (sigmaProof{
-		commit: []sigma.CommitMent{
-			commit_s, commit_sh, commit_r, commit_rh, commit_date,
-		},
-		commitenc: [][]curve.PointAffine{
-			commit_bal,
-			commit_v,
-		},
-		response: []sigma.Response{
-			rp_sr, rp_rr, rp_sv, rp_rv, rp_bal, rp_bal_r, rp_v, rp_v_r, rp_date, rp_dater,
-		},
-		challenge: challenge,
-	}), s, endtime.Sub(starttime)
+	proof.response = []sigma.Response{
+		rp_sr, rp_rr, rp_sv, rp_rv, rp_bal, rp_bal_r, rp_v, rp_v_r, rp_date, rp_dater,
+	}
+	return proof, s, endtime.Sub(starttime)
 }
 
-func (s sender) sigmaprotocolwithNolimitRegulation(params *twistededwards.CurveParams, curveid ecctedwards.ID) (sigmaProof, sender, time.Duration) {
+func (s sender) sigmaprotocolwithNolimitRegulation(params *twistededwards.CurveParams, o offlinetx.Offline) (sigmaProof, sender, time.Duration) {
 	//simulation receiver
 	hashFunc := hash.MIMC_BN254
 	var receiver_bal big.Int
 	receiver_bal.SetString("200", 10)
 	var receiver offlinetx.PrimitiveAccount
-	receiver = receiver.GetAccount(params, hashFunc, receiver_bal, big.NewInt(1))
+	receiver = receiver.GetAccount(params, hashFunc, receiver_bal, big.NewInt(1), rand.Reader)
 	r_pk := receiver.Pk
 
 	/* */
@@ -223,9 +202,6 @@ func (s sender) sigmaprotocolwithNolimitRegulation(params *twistededwards.CurveP
 	r_txs, _ := rand.Int(rand.Reader, params.Order)
 	r_txs = r_txs.Add(r_txs, big.NewInt(int64(10))).Mod(r_txs, params.Order)
 
-	var o offlinetx.Offline
-	o = o.Execution(params, hashFunc, curveid)
-
 	s = s.execution(params, r_txr, r_txs, r_pk, v, o)
 
 	/* */
@@ -250,26 +226,16 @@ func (s sender) sigmaprotocolwithNolimitRegulation(params *twistededwards.CurveP
 	commit_bal := commit.CommitencValid(para_bal, para_bal_r, s.apk, s.h, s._trans)
 	commit_v := commit.CommitencValid(para_v, para_v_r, s.apk, s.h, s._trans)
 
-	hash := hashFunc.New()
-
-	var data []byte
-	data = append(data, commit_sh.Commit.Marshal()...)
-	data = append(data, commit_rh.Commit.Marshal()...)
-	data = append(data, commit_s.Commit.Marshal()...)
-	data = append(data, commit_r.Commit.Marshal()...)
-	data = append(data, s.txs.A.Marshal()...)
-	data = append(data, s.txs.B.Marshal()...)
-	data = append(data, s.txr.A.Marshal()...)
-	data = append(data, s.txr.B.Marshal()...)
-	data = append(data, commit_bal[0].Marshal()...)
-	data = append(data, commit_bal[1].Marshal()...)
-	data = append(data, commit_v[0].Marshal()...)
-	data = append(data, commit_v[1].Marshal()...)
-
-	hash.Write(data)
-	_challenge := hash.Sum(nil)
-	var challenge big.Int
-	challenge.SetBytes(_challenge)
+	proof := sigmaProof{
+		commit: []sigma.CommitMent{
+			commit_s, commit_sh, commit_r, commit_rh,
+		},
+		commitenc: [][]curve.PointAffine{
+			commit_bal,
+			commit_v,
+		},
+	}
+	challenge := s.challenge(noLimit, proof)
 
 	var rp_sr sigma.Response
 	rp_sr = rp_sr.Response(para_sh, challenge, s.r_txs)
@@ -292,28 +258,19 @@ func (s sender) sigmaprotocolwithNolimitRegulation(params *twistededwards.CurveP
 
 	//fmt.Println("sigma----generate commitment,challenge,response cost:", endtime.Sub(starttime))
 
-	return (sigmaProof{
-		commit: []sigma.CommitMent{
-			commit_s, commit_sh, commit_r, commit_rh,
-		},
-		commitenc: [][]curve.PointAffine{
-			commit_bal,
-			commit_v,
-		},
-		response: []sigma.Response{
-			rp_sr, rp_rr, rp_sv, rp_rv, rp_bal, rp_bal_r, rp_v, rp_v_r,
-		},
-		challenge: challenge,
-	}), s, endtime.Sub(starttime)
+	proof.response = []sigma.Response{
+		rp_sr, rp_rr, rp_sv, rp_rv, rp_bal, rp_bal_r, rp_v, rp_v_r,
+	}
+	return proof, s, endtime.Sub(starttime)
 }
 
-func (s sender) sigmaprotocolwithNoRegulation(params *twistededwards.CurveParams, curveid ecctedwards.ID) (sigmaProof, sender, time.Duration) {
+func (s sender) sigmaprotocolwithNoRegulation(params *twistededwards.CurveParams, o offlinetx.Offline) (sigmaProof, sender, time.Duration) {
 	//simulation receiver
 	hashFunc := hash.MIMC_BN254
 	var receiver_bal big.Int
 	receiver_bal.SetString("200", 10)
 	var receiver offlinetx.PrimitiveAccount
-	receiver = receiver.GetAccount(params, hashFunc, receiver_bal, big.NewInt(1))
+	receiver = receiver.GetAccount(params, hashFunc, receiver_bal, big.NewInt(1), rand.Reader)
 	r_pk := receiver.Pk
 
 	/* */
@@ -325,9 +282,6 @@ func (s sender) sigmaprotocolwithNoRegulation(params *twistededwards.CurveParams
 	r_txs, _ := rand.Int(rand.Reader, params.Order)
 	r_txs = r_txs.Add(r_txs, big.NewInt(int64(10))).Mod(r_txs, params.Order)
 
-	var o offlinetx.Offline
-	o = o.Execution(params, hashFunc, curveid)
-
 	s = s.execution(params, r_txr, r_txs, r_pk, v, o)
 
 	/* */
@@ -344,22 +298,13 @@ func (s sender) sigmaprotocolwithNoRegulation(params *twistededwards.CurveParams
 	commit_s := commit.Commitmuladd(para_sh, para_s, s.dacc.Keypair.DPk.Pk, s.dacc.G0)
 	commit_r := commit.Commitmuladd(para_rh, para_r, s.r_derivepk.Pk, s.dacc.G0)
 
-	hash := hashFunc.New()
-
-	var data []byte
-	data = append(data, commit_sh.Commit.Marshal()...)
-	data = append(data, commit_rh.Commit.Marshal()...)
-	data = append(data, commit_s.Commit.Marshal()...)
-	data = append(data, commit_r.Commit.Marshal()...)
-	data = append(data, s.txs.A.Marshal()...)
-	data = append(data, s.txs.B.Marshal()...)
-	data = append(data, s.txr.A.Marshal()...)
-	data = append(data, s.txr.B.Marshal()...)
-
-	hash.Write(data)
-	_challenge := hash.Sum(nil)
-	var challenge big.Int
-	challenge.SetBytes(_challenge)
+	proof := sigmaProof{
+		commit: []sigma.CommitMent{
+			commit_s, commit_sh, commit_r, commit_rh,
+		},
+		commitenc: [][]curve.PointAffine{},
+	}
+	challenge := s.challenge(noRegulation, proof)
 
 	var rp_sr sigma.Response
 	rp_sr = rp_sr.Response(para_sh, challenge, s.r_txs)
@@ -374,60 +319,8 @@ func (s sender) sigmaprotocolwithNoRegulation(params *twistededwards.CurveParams
 
 	//fmt.Println("sigma----generate commitment,challenge,response cost:", endtime.Sub(starttime))
 
-	return (sigmaProof{
-		commit: []sigma.CommitMent{
-			commit_s, commit_sh, commit_r, commit_rh,
-		},
-		commitenc: [][]curve.PointAffine{},
-		response: []sigma.Response{
-			rp_sr, rp_rr, rp_sv, rp_rv,
-		},
-		challenge: challenge,
-	}), s, endtime.Sub(starttime)
-}
-
-func (_ sender) zkpProof(params *twistededwards.CurveParams, curveid ecctedwards.ID, frmodulus *big.Int) (sender, sigmaProof, bulletProof, bulletProof, bulletProof, bulletProof, int64, int64, int64, int64) {
-	var s sender
-	var sigmaproof sigmaProof
-	var t_sigmagenwithFreqlimitRegulation time.Duration
-	sigmaproof, s, t_sigmagenwithFreqlimitRegulation = s.sigmaprotocolwithFreqlimitRegulation(params, curveid)
-
-	var t_sigmagenwithNolimitRegulation time.Duration
-	_, _, t_sigmagenwithNolimitRegulation = s.sigmaprotocolwithNolimitRegulation(params, curveid)
-
-	var t_sigmagenwithNoRegulation time.Duration
-	_, _, t_sigmagenwithNoRegulation = s.sigmaprotocolwithNoRegulation(params, curveid)
-
-	v := s.v
-	bal_v := s.bal.Sub(&s.bal, &s.v)
-
-	var bpPara bulletproof.BulletParams
-	bpPara = bpPara.ParamsGen()
-
-	var bp1 bulletProof
-	var t_bp1 time.Duration
-	bp1, t_bp1 = bp1.rangeproof(&v, bpPara)
-	var bp2 bulletProof
-	var t_bp2 time.Duration
-	bp2, t_bp2 = bp2.rangeproof(bal_v, bpPara)
-	var holding bulletProof
-	var t_holding time.Duration
-	holding, t_holding = holding.rangeproof(big.NewInt(200), bpPara)
-	var date bulletProof
-	var t_date time.Duration
-	date, t_date = date.rangeproof(big.NewInt(200), bpPara)
-
-	var totalzkptimewithFreqlimitRegulation int64
-	totalzkptimewithFreqlimitRegulation = t_sigmagenwithFreqlimitRegulation.Microseconds() + t_bp1.Microseconds() + t_bp2.Microseconds() + t_holding.Microseconds() + t_date.Microseconds()
-
-	var totalzkptimewithNolimitRegulation int64
-	totalzkptimewithNolimitRegulation = t_sigmagenwithNolimitRegulation.Microseconds() + t_bp1.Microseconds() + t_bp2.Microseconds()
-
-	var totalzkptimewithHoldinglimitRegulation int64
-	totalzkptimewithHoldinglimitRegulation = t_sigmagenwithFreqlimitRegulation.Microseconds() + t_bp1.Microseconds() + t_bp2.Microseconds() + t_holding.Microseconds()
-
-	var totalzkptimewithNoRegulation int64
-	totalzkptimewithNoRegulation = t_sigmagenwithNoRegulation.Microseconds() + t_bp1.Microseconds() + t_bp2.Microseconds()
-
-	return s, sigmaproof, bp1, bp2, holding, date, totalzkptimewithFreqlimitRegulation, totalzkptimewithHoldinglimitRegulation, totalzkptimewithNolimitRegulation, totalzkptimewithNoRegulation
+	proof.response = []sigma.Response{
+		rp_sr, rp_rr, rp_sv, rp_rv,
+	}
+	return proof, s, endtime.Sub(starttime)
 }
@@ -0,0 +1,21 @@
+package regulator
+
+import (
+	"Asyn_CBDC/backend/generator"
+	"Asyn_CBDC/backend/util"
+	"errors"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+)
+
+var ErrAmountRange = errors.New("regulator: amount out of the searched range")
+
+// DecodeAmount returns v in [0,max] for m=trans*v, the plaintext of
+// cipher_bal and cipher_v. max is at most util.MaxDlog.
+func DecodeAmount(m curve.PointAffine, max uint64) (uint64, error) {
+	v, err := util.SmallDlog(generator.Trans, m, max)
+	if err != nil {
+		return 0, ErrAmountRange
+	}
+	return v, nil
+}
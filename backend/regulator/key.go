@@ -0,0 +1,105 @@
+package regulator
+
+import (
+	"Asyn_CBDC/backend/generator"
+	"Asyn_CBDC/backend/util"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark-crypto/ecc/bn254/twistededwards/eddsa"
+	"github.com/consensys/gnark-crypto/signature"
+)
+
+// Key is the regulator's secret: the ElGamal key whose public part Apk=h*sk
+// the wallets encrypt to, and the key signing the disclosure reports.
+type Key struct {
+	sk     *big.Int
+	pk     curve.PointAffine
+	signer signature.Signer
+}
+
+// GenerateKey draws a regulator key from rnd, crypto/rand when rnd is nil.
+func GenerateKey(rnd io.Reader) (*Key, error) {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	params := curve.GetEdwardsCurve()
+	sk := util.RandomScalar(rnd, &params.Order)
+	signer, err := eddsa.GenerateKey(rnd)
+	if err != nil {
+		return nil, err
+	}
+	return newKey(sk, signer), nil
+}
+
+func newKey(sk *big.Int, signer signature.Signer) *Key {
+	h := generator.H
+	k := &Key{sk: sk, signer: signer}
+	k.pk.ScalarMultiplication(&h, sk)
+	return k
+}
+
+// PublicKey returns Apk, the key of CipherPk.
+func (k *Key) PublicKey() util.Publickey {
+	return util.Publickey{Pk: k.pk}
+}
+
+// SigningKey returns the key verifying the reports of k.
+func (k *Key) SigningKey() signature.PublicKey {
+	return k.signer.Public()
+}
+
+// Decrypt returns c1-sk*c2, the point encrypted in cipher.
+func (k *Key) Decrypt(cipher []curve.PointAffine) (curve.PointAffine, error) {
+	var m curve.PointAffine
+	if err := checkCipher(cipher); err != nil {
+		return m, err
+	}
+	var s curve.PointAffine
+	s.ScalarMultiplication(&cipher[1], k.sk)
+	s.Neg(&s)
+	m.Add(&cipher[0], &s)
+	return m, nil
+}
+
+type keyFile struct {
+	Sk     []byte `json:"sk"`
+	Signer []byte `json:"signer"`
+}
+
+// Save writes k to path, readable by its owner only.
+func (k *Key) Save(path string) error {
+	sk := k.sk.FillBytes(make([]byte, 32))
+	data, err := json.MarshalIndent(keyFile{Sk: sk, Signer: k.signer.Bytes()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return util.WriteSecret(path, data)
+}
+
+// LoadKey reads a key written by Save.
+func LoadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f keyFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("regulator: %s: %w", path, err)
+	}
+	sk := new(big.Int).SetBytes(f.Sk)
+	params := curve.GetEdwardsCurve()
+	if sk.Sign() == 0 || sk.Cmp(&params.Order) >= 0 {
+		return nil, fmt.Errorf("regulator: %s: decryption key out of range", path)
+	}
+	signer := new(eddsa.PrivateKey)
+	if _, err := signer.SetBytes(f.Signer); err != nil {
+		return nil, fmt.Errorf("regulator: %s: signing key: %w", path, err)
+	}
+	return newKey(sk, signer), nil
+}
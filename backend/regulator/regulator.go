@@ -0,0 +1,154 @@
+// Package regulator de-anonymizes regulated offline transactions. A proof in
+// NoLimitRegulation mode carries CipherPk=Enc_Apk(pk); from HoldingLimit on it
+// carries RegTk=a*CipherPk, which decrypts to a*pk, and Aux=h*a, signed by the
+// bank for the holding key a enrolled with the wallet. The regulator
+// decrypts either form with its key, finds the wallet in the enrollment
+// registry and signs a disclosure report. The key may instead be shared among
+// n parties, t of which decrypt together; see GroupKey.
+package regulator
+
+import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/offlinetx"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark-crypto/signature"
+)
+
+var (
+	ErrNothingDisclosed = errors.New("regulator: transaction discloses nothing")
+	ErrOtherRegulator   = errors.New("regulator: encrypted to another regulator key")
+	ErrNotEnrolled      = errors.New("regulator: no enrolled wallet matches")
+	ErrBadReport        = errors.New("regulator: report signature invalid")
+)
+
+// forms of disclosure
+const (
+	FormCipherPk = "cipher-pk" // CipherPk, decrypts to pk
+	FormHolding  = "holding"   // RegTk and Aux, decrypts to a*pk
+)
+
+// Disclosure is what an offline transaction discloses to the regulator.
+type Disclosure struct {
+	Delta  *big.Int            // nullifier of the transaction
+	Apk    *curve.PointAffine  // key the sender encrypted to
+	Cipher []curve.PointAffine // CipherPk, or RegTk when Aux is set
+	Aux    *curve.PointAffine
+}
+
+// FromPublicInputs takes the disclosure out of the public inputs of an offline
+// proof. The proof is expected to be verified already.
+func FromPublicInputs(pub offlinetx.PublicInputs) (Disclosure, error) {
+	if pub.CPk == nil || pub.PublicKeyA == nil {
+		return Disclosure{}, ErrNothingDisclosed
+	}
+	return Disclosure{Delta: pub.Delta, Apk: pub.PublicKeyA, Cipher: pub.CPk, Aux: pub.Aux}, nil
+}
+
+// Form is FormHolding when d carries Aux, FormCipherPk otherwise.
+func (d Disclosure) Form() string {
+	if d.Aux != nil {
+		return FormHolding
+	}
+	return FormCipherPk
+}
+
+// Report names the wallet behind a transaction. It is signed by the
+// regulator's signing key.
+type Report struct {
+	Delta     string `json:"delta"`
+	Form      string `json:"form"`
+	Wallet    string `json:"wallet"`
+	PublicKey []byte `json:"public_key"`
+	Aux       []byte `json:"aux,omitempty"`
+	Date      uint64 `json:"date"`
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// Digest is the SHA-256 of the report without its signature.
+func (r Report) Digest() ([]byte, error) {
+	r.Signature = nil
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// Verify checks the signature of r under the regulator's signing key.
+func (r Report) Verify(pk signature.PublicKey) error {
+	digest, err := r.Digest()
+	if err != nil {
+		return err
+	}
+	ok, err := pk.Verify(r.Signature, digest, sha256.New())
+	if err != nil || !ok {
+		return ErrBadReport
+	}
+	return nil
+}
+
+// Identify decrypts d and returns the enrolled wallet it discloses.
+func (k *Key) Identify(reg *enroll.Registry, d Disclosure) (enroll.Entry, error) {
+	if d.Apk == nil || !d.Apk.Equal(&k.pk) {
+		return enroll.Entry{}, ErrOtherRegulator
+	}
+	m, err := k.Decrypt(d.Cipher)
+	if err != nil {
+		return enroll.Entry{}, err
+	}
+	return Match(reg, d, m)
+}
+
+// Match returns the enrolled wallet whose key is m, the decryption of d: pk
+// itself, or a*pk in the holding form.
+func Match(reg *enroll.Registry, d Disclosure, m curve.PointAffine) (enroll.Entry, error) {
+	var e enroll.Entry
+	var ok bool
+	if d.Aux != nil {
+		e, ok = reg.LookupHolding(*d.Aux, m)
+	} else {
+		e, ok = reg.Lookup(m)
+	}
+	if !ok {
+		return enroll.Entry{}, fmt.Errorf("%w the %s disclosure of delta %v", ErrNotEnrolled, d.Form(), d.Delta)
+	}
+	return e, nil
+}
+
+// Disclose identifies the wallet behind d and signs the report, dated date.
+func (k *Key) Disclose(reg *enroll.Registry, d Disclosure, date uint64) (Report, error) {
+	e, err := k.Identify(reg, d)
+	if err != nil {
+		return Report{}, err
+	}
+	pk := e.Pk.Bytes()
+	r := Report{
+		Form:      d.Form(),
+		Wallet:    e.ID,
+		PublicKey: pk[:],
+		Date:      date,
+	}
+	if d.Delta != nil {
+		r.Delta = d.Delta.String()
+	}
+	if d.Aux != nil {
+		aux := d.Aux.Bytes()
+		r.Aux = aux[:]
+	}
+	digest, err := r.Digest()
+	if err != nil {
+		return Report{}, err
+	}
+	r.Signature, err = k.signer.Sign(digest, sha256.New())
+	if err != nil {
+		return Report{}, err
+	}
+	return r, nil
+}
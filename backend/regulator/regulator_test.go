@@ -0,0 +1,407 @@
+package regulator
+
+import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/generator"
+	"Asyn_CBDC/backend/offlinetx"
+	"Asyn_CBDC/backend/util"
+	"Asyn_CBDC/internal/testutil"
+	"errors"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+)
+
+const testDate = 1_760_000_000
+
+func testKey(t *testing.T) *Key {
+	t.Helper()
+	k, err := GenerateKey(util.NewSeededReader([]byte(t.Name())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// transaction runs an offline transaction encrypted to k, by a wallet with
+// holding key a, and enrolls the wallet as id.
+func transaction(t *testing.T, k *Key, reg *enroll.Registry, id string, a *big.Int, mode offlinetx.RegulationMode) offlinetx.PublicInputs {
+	t.Helper()
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	is, err := testutil.Issuer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := offlinetx.Offline{Mode: mode, Apk: k.PublicKey(), A: a, Issuer: is}
+	o, err = o.Execution(params, hash.MIMC_BN254, ecctedwards.BN254, util.NewSeededReader([]byte(t.Name()+id)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Register(id, o.Pk, a, testDate); err != nil {
+		t.Fatal(err)
+	}
+	return o.PublicInputs(mode)
+}
+
+func TestDisclose(t *testing.T) {
+	k := testKey(t)
+	reg := enroll.NewRegistry()
+
+	for _, c := range []struct {
+		id   string
+		a    *big.Int
+		mode offlinetx.RegulationMode
+		form string
+	}{
+		{"alice", nil, offlinetx.NoLimitRegulation, FormCipherPk},
+		{"bob", big.NewInt(12345), offlinetx.HoldingLimit, FormHolding},
+		{"carol", big.NewInt(67890), offlinetx.FreqLimit, FormHolding},
+	} {
+		pub := transaction(t, k, reg, c.id, c.a, c.mode)
+		d, err := FromPublicInputs(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := k.Disclose(reg, d, testDate)
+		if err != nil {
+			t.Fatalf("%s: %v", c.id, err)
+		}
+		if r.Wallet != c.id || r.Form != c.form || r.Delta != pub.Delta.String() {
+			t.Errorf("%s: report %+v", c.id, r)
+		}
+		if err := r.Verify(k.SigningKey()); err != nil {
+			t.Errorf("%s: %v", c.id, err)
+		}
+		r.Wallet = "mallory"
+		if err := r.Verify(k.SigningKey()); !errors.Is(err, ErrBadReport) {
+			t.Errorf("%s: altered report: %v", c.id, err)
+		}
+	}
+}
+
+func TestDiscloseRejects(t *testing.T) {
+	k := testKey(t)
+	reg := enroll.NewRegistry()
+	pub := transaction(t, k, reg, "alice", big.NewInt(7), offlinetx.HoldingLimit)
+
+	if _, err := FromPublicInputs(transaction(t, k, reg, "bob", nil, offlinetx.NoRegulation)); !errors.Is(err, ErrNothingDisclosed) {
+		t.Errorf("no regulation: %v", err)
+	}
+
+	other, err := GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := FromPublicInputs(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Disclose(reg, d, testDate); !errors.Is(err, ErrOtherRegulator) {
+		t.Errorf("other regulator: %v", err)
+	}
+
+	// a*pk decrypts to no enrolled key: without Aux it is not found
+	plain := d
+	plain.Aux = nil
+	if _, err := k.Disclose(reg, plain, testDate); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("holding form without aux: %v", err)
+	}
+	// nor with the Aux of another wallet's holding key
+	pub2 := transaction(t, k, reg, "carol", big.NewInt(8), offlinetx.HoldingLimit)
+	mixed := d
+	mixed.Aux = pub2.Aux
+	if _, err := k.Disclose(reg, mixed, testDate); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("aux of another wallet: %v", err)
+	}
+	if _, err := k.Disclose(enroll.NewRegistry(), d, testDate); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("empty registry: %v", err)
+	}
+
+	short := d
+	short.Cipher = d.Cipher[:1]
+	if _, err := k.Disclose(reg, short, testDate); err == nil {
+		t.Error("short ciphertext accepted")
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	k := testKey(t)
+	path := filepath.Join(t.TempDir(), "regulator.json")
+	if err := k.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("stat %v, %v", fi, err)
+	}
+	loaded, err := LoadKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk := loaded.PublicKey()
+	if !pk.Pk.Equal(&k.pk) || !loaded.SigningKey().Equal(k.SigningKey()) {
+		t.Fatal("loaded key differs")
+	}
+
+	reg := enroll.NewRegistry()
+	d, err := FromPublicInputs(transaction(t, k, reg, "alice", nil, offlinetx.NoLimitRegulation))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := loaded.Disclose(reg, d, testDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Verify(k.SigningKey()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func encryptTo(t *testing.T, pk util.Publickey, m curve.PointAffine) []curve.PointAffine {
+	t.Helper()
+	r := util.RandomScalar(util.NewSeededReader([]byte(t.Name())), order())
+	return pk.Encrypt(&m, r, generator.H)
+}
+
+func TestThreshold(t *testing.T) {
+	rnd := util.NewSeededReader([]byte(t.Name()))
+	g, shares, err := Simulate(5, 3, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range shares[1:] {
+		if !s.Group.Pk.Equal(&g.Pk) {
+			t.Fatal("parties disagree on the group key")
+		}
+	}
+
+	m := generator.Trans
+	cipher := encryptTo(t, g.PublicKey(), m)
+	partial := func(i int) PartialDecryption {
+		pd, err := shares[i-1].Decrypt(cipher, rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pd
+	}
+	for _, set := range [][]int{{1, 2, 3}, {2, 4, 5}, {5, 1, 3, 4}, {1, 2, 3, 4, 5}} {
+		var pds []PartialDecryption
+		for _, i := range set {
+			pds = append(pds, partial(i))
+		}
+		got, err := g.Combine(cipher, pds)
+		if err != nil {
+			t.Fatalf("%v: %v", set, err)
+		}
+		if !got.Equal(&m) {
+			t.Errorf("%v: wrong plaintext", set)
+		}
+	}
+
+	p1, p2 := partial(1), partial(2)
+	if _, err := g.Combine(cipher, []PartialDecryption{p1, p2}); !errors.Is(err, ErrTooFew) {
+		t.Errorf("two partials: %v", err)
+	}
+	if _, err := g.Combine(cipher, []PartialDecryption{p1, p2, p1}); !errors.Is(err, ErrTooFew) {
+		t.Errorf("a partial counted twice: %v", err)
+	}
+
+	// a wrong share, even with a proof made for it, does not verify
+	forged := p1
+	forged.D.Add(&forged.D, &m)
+	if err := g.VerifyPartial(cipher, forged); !errors.Is(err, ErrBadPartial) {
+		t.Errorf("forged D: %v", err)
+	}
+	wrong := &Share{Index: 1, x: big.NewInt(7), Group: g}
+	pd, err := wrong.Decrypt(cipher, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// it is skipped while T valid partials remain
+	got, err := g.Combine(cipher, []PartialDecryption{pd, p2, partial(3), partial(4)})
+	if err != nil || !got.Equal(&m) {
+		t.Errorf("partial of a wrong share among enough valid ones: %v", err)
+	}
+	_, err = g.Combine(cipher, []PartialDecryption{pd, p2, partial(3)})
+	if !errors.Is(err, ErrTooFew) || !errors.Is(err, ErrBadPartial) || !strings.Contains(err.Error(), "[1]") {
+		t.Errorf("partial of a wrong share: %v", err)
+	}
+	g2, shares2, err := Simulate(3, 2, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher2 := encryptTo(t, g2.PublicKey(), m)
+	var pds []PartialDecryption
+	for _, s := range append([]*Share{{Index: 1, x: big.NewInt(7), Group: g2}}, shares2[1:]...) {
+		pd, err := s.Decrypt(cipher2, rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pds = append(pds, pd)
+	}
+	if got, err := g2.Combine(cipher2, pds); err != nil || !got.Equal(&m) {
+		t.Errorf("{bad, p2, p3} of 2-of-3: %v", err)
+	}
+	other := encryptTo(t, g.PublicKey(), generator.G0)
+	other[1] = generator.G1
+	if err := g.VerifyPartial(other, p1); !errors.Is(err, ErrBadPartial) {
+		t.Errorf("partial of another ciphertext: %v", err)
+	}
+	moved := p1
+	moved.Index = 4
+	if err := g.VerifyPartial(cipher, moved); !errors.Is(err, ErrBadPartial) {
+		t.Errorf("partial claimed by another party: %v", err)
+	}
+
+	// D shifted by the point of order 2, with a proof that holds for it
+	shifted := torsionShifted(shares[0], cipher, rnd)
+	if err := g.VerifyPartial(cipher, shifted); !errors.Is(err, ErrBadPartial) {
+		t.Errorf("torsion-shifted D: %v", err)
+	}
+	if _, err := g.Combine(cipher, []PartialDecryption{shifted, p2, partial(3)}); !errors.Is(err, ErrTooFew) {
+		t.Errorf("torsion-shifted partial combined: %v", err)
+	}
+	torsionCipher := []curve.PointAffine{cipher[0], cipher[1]}
+	torsionCipher[1].Add(&torsionCipher[1], &torsion)
+	if err := g.VerifyPartial(torsionCipher, p1); err == nil {
+		t.Error("torsion-shifted ciphertext accepted")
+	}
+}
+
+// torsion is (0,-1), the point of order 2.
+var torsion = func() curve.PointAffine {
+	var p curve.PointAffine
+	p.Y.SetOne()
+	p.Y.Neg(&p.Y)
+	return p
+}()
+
+// torsionShifted is the partial decryption of cipher by s shifted by torsion,
+// with a proof drawn until its challenge is even: then e*torsion vanishes and
+// the proof checks out on every point but D's subgroup.
+func torsionShifted(s *Share, cipher []curve.PointAffine, rnd io.Reader) PartialDecryption {
+	c2 := cipher[1]
+	h := generator.H
+	y := s.Group.Verification[s.Index-1]
+	for {
+		pd := PartialDecryption{Index: s.Index}
+		pd.D.ScalarMultiplication(&c2, s.x)
+		pd.D.Add(&pd.D, &torsion)
+		w := util.RandomScalar(rnd, order())
+		var a1, a2 curve.PointAffine
+		a1.ScalarMultiplication(&h, w)
+		a2.ScalarMultiplication(&c2, w)
+		pd.E = dleqChallenge(y, c2, pd.D, a1, a2)
+		if pd.E.Bit(0) != 0 {
+			continue
+		}
+		pd.Z = new(big.Int).Mul(pd.E, s.x)
+		pd.Z.Add(pd.Z, w).Mod(pd.Z, order())
+		return pd
+	}
+}
+
+func TestDealingRejects(t *testing.T) {
+	if _, err := NewParty(1, 3, 4, nil); err == nil {
+		t.Error("threshold above n accepted")
+	}
+	a, err := NewParty(1, 3, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewParty(2, 3, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	share := a.Share(2)
+	if err := b.Receive(a.Dealing(), new(big.Int).Add(share, big.NewInt(1))); !errors.Is(err, ErrBadDealing) {
+		t.Errorf("bad share: %v", err)
+	}
+	d := a.Dealing()
+	d.Commitments = d.Commitments[:1]
+	if err := b.Receive(d, share); !errors.Is(err, ErrBadDealing) {
+		t.Errorf("short dealing: %v", err)
+	}
+	// a torsion shift of C_1 cancels in h*f(2), 2*torsion being 0
+	d = a.Dealing()
+	d.Commitments = append([]curve.PointAffine(nil), d.Commitments...)
+	d.Commitments[1].Add(&d.Commitments[1], &torsion)
+	if want := d.eval(2); !want.Equal(new(curve.PointAffine).ScalarMultiplication(&generator.H, share)) {
+		t.Fatal("the torsion shift does not cancel")
+	}
+	if err := b.Receive(d, share); !errors.Is(err, ErrBadDealing) {
+		t.Errorf("torsion-shifted commitment: %v", err)
+	}
+	if err := b.Receive(a.Dealing(), share); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Finish(); err == nil {
+		t.Error("finished without every dealing")
+	}
+}
+
+func TestThresholdDisclose(t *testing.T) {
+	rnd := util.NewSeededReader([]byte(t.Name()))
+	g, shares, err := Simulate(3, 2, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := enroll.NewRegistry()
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	is, err := testutil.Issuer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := offlinetx.Offline{Mode: offlinetx.HoldingLimit, Apk: g.PublicKey(), A: big.NewInt(99), Issuer: is}
+	o, err = o.Execution(params, hash.MIMC_BN254, ecctedwards.BN254, rnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reg.Register("alice", o.Pk, o.A, testDate); err != nil {
+		t.Fatal(err)
+	}
+	d, err := FromPublicInputs(o.PublicInputs(offlinetx.HoldingLimit))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pds []PartialDecryption
+	for _, s := range []*Share{shares[2], shares[0]} {
+		pd, err := s.Decrypt(d.Cipher, rnd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pds = append(pds, pd)
+	}
+	m, err := g.Combine(d.Cipher, pds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, err := Match(reg, d, m); err != nil || e.ID != "alice" {
+		t.Errorf("matched %q, %v", e.ID, err)
+	}
+}
+
+func TestDecodeAmount(t *testing.T) {
+	trans := generator.Trans
+	for _, v := range []uint64{0, 1, 99, 100, 1000, 4095} {
+		var m curve.PointAffine
+		m.ScalarMultiplication(&trans, new(big.Int).SetUint64(v))
+		got, err := DecodeAmount(m, 4095)
+		if err != nil || got != v {
+			t.Errorf("%d: got %d, %v", v, got, err)
+		}
+	}
+	var m curve.PointAffine
+	m.ScalarMultiplication(&trans, big.NewInt(4096))
+	if _, err := DecodeAmount(m, 4095); !errors.Is(err, ErrAmountRange) {
+		t.Errorf("out of range: %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package regulator
+
+import (
+	"Asyn_CBDC/backend/generator"
+	"Asyn_CBDC/backend/util"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark-crypto/hash"
+)
+
+// The threshold regulator key is generated by n parties with Feldman's
+// verifiable secret sharing: party i deals f_i, of degree t-1, by sending
+// f_i(j) to party j and broadcasting h*f_i's coefficients. Party j's share is
+// x_j = sum_i f_i(j), the group key sk = sum_i f_i(0) is never assembled, and
+// Apk = h*sk. Any t parties decrypt together: each publishes x_j*c2 with a
+// Chaum-Pedersen proof that it used its share, and Lagrange interpolation in
+// the exponent gives sk*c2.
+
+var (
+	ErrBadDealing = errors.New("regulator: share does not match the dealer's commitments")
+	ErrBadPartial = errors.New("regulator: invalid partial decryption")
+	ErrTooFew     = errors.New("regulator: not enough partial decryptions")
+)
+
+// dleqLabel separates the Chaum-Pedersen challenges from any other MiMC use.
+const dleqLabel = "Asyn_CBDC/regulator/dleq/v1"
+
+func order() *big.Int {
+	params := curve.GetEdwardsCurve()
+	return &params.Order
+}
+
+// Dealing is what party From broadcasts: h times the coefficients of its
+// polynomial, constant term first.
+type Dealing struct {
+	From        int
+	Commitments []curve.PointAffine
+}
+
+// eval returns sum_k C_k*j^k, h*f(j) for the dealt polynomial f.
+func (d Dealing) eval(j int) curve.PointAffine {
+	var res curve.PointAffine
+	res.X.SetZero()
+	res.Y.SetOne()
+	x := big.NewInt(int64(j))
+	pow := big.NewInt(1)
+	for _, c := range d.Commitments {
+		var term curve.PointAffine
+		term.ScalarMultiplication(&c, pow)
+		res.Add(&res, &term)
+		pow.Mul(pow, x).Mod(pow, order())
+	}
+	return res
+}
+
+// Party is one of the n key holders during the key generation.
+type Party struct {
+	Index int // 1..n
+	n, t  int
+
+	poly     []*big.Int
+	dealings map[int]Dealing
+	shares   map[int]*big.Int
+}
+
+// NewParty starts the key generation for party index of n, threshold t. Its
+// polynomial is drawn from rnd, crypto/rand when rnd is nil.
+func NewParty(index, n, t int, rnd io.Reader) (*Party, error) {
+	if t < 1 || t > n {
+		return nil, fmt.Errorf("regulator: threshold %d out of [1,%d]", t, n)
+	}
+	if index < 1 || index > n {
+		return nil, fmt.Errorf("regulator: party %d out of [1,%d]", index, n)
+	}
+	p := &Party{Index: index, n: n, t: t, dealings: make(map[int]Dealing), shares: make(map[int]*big.Int)}
+	for k := 0; k < t; k++ {
+		p.poly = append(p.poly, util.RandomScalar(rnd, order()))
+	}
+	return p, nil
+}
+
+// Dealing returns the commitments p broadcasts to every party.
+func (p *Party) Dealing() Dealing {
+	h := generator.H
+	d := Dealing{From: p.Index}
+	for _, a := range p.poly {
+		var c curve.PointAffine
+		c.ScalarMultiplication(&h, a)
+		d.Commitments = append(d.Commitments, c)
+	}
+	return d
+}
+
+// Share returns f_p(to), sent to party to over a private channel.
+func (p *Party) Share(to int) *big.Int {
+	x := big.NewInt(int64(to))
+	res := new(big.Int)
+	for k := len(p.poly) - 1; k >= 0; k-- {
+		res.Mul(res, x).Add(res, p.poly[k]).Mod(res, order())
+	}
+	return res
+}
+
+// Receive checks the share sent by d.From against its dealing, whose
+// commitments must be in the prime-order subgroup. A dealer whose share fails
+// is to be excluded and the key generation restarted without it.
+func (p *Party) Receive(d Dealing, share *big.Int) error {
+	if d.From < 1 || d.From > p.n {
+		return fmt.Errorf("regulator: dealer %d out of [1,%d]", d.From, p.n)
+	}
+	if len(d.Commitments) != p.t {
+		return fmt.Errorf("%w: dealer %d committed to degree %d, want %d", ErrBadDealing, d.From, len(d.Commitments)-1, p.t-1)
+	}
+	for _, c := range d.Commitments {
+		if !inSubgroup(&c) {
+			return fmt.Errorf("%w: dealer %d commitment not in the prime-order subgroup", ErrBadDealing, d.From)
+		}
+	}
+	h := generator.H
+	var hs curve.PointAffine
+	hs.ScalarMultiplication(&h, share)
+	want := d.eval(p.Index)
+	if !hs.Equal(&want) {
+		return fmt.Errorf("%w: dealer %d", ErrBadDealing, d.From)
+	}
+	p.dealings[d.From] = d
+	p.shares[d.From] = new(big.Int).Mod(share, order())
+	return nil
+}
+
+// Finish returns p's key share once it received a valid dealing from every
+// party.
+func (p *Party) Finish() (*Share, error) {
+	if len(p.dealings) != p.n {
+		return nil, fmt.Errorf("regulator: party %d has %d dealings of %d", p.Index, len(p.dealings), p.n)
+	}
+	g := GroupKey{N: p.n, T: p.t}
+	g.Pk.X.SetZero()
+	g.Pk.Y.SetOne()
+	g.Verification = make([]curve.PointAffine, p.n)
+	x := new(big.Int)
+	for i := 1; i <= p.n; i++ {
+		d := p.dealings[i]
+		g.Pk.Add(&g.Pk, &d.Commitments[0])
+		x.Add(x, p.shares[i])
+	}
+	for j := 1; j <= p.n; j++ {
+		y := &g.Verification[j-1]
+		y.X.SetZero()
+		y.Y.SetOne()
+		for i := 1; i <= p.n; i++ {
+			e := p.dealings[i].eval(j)
+			y.Add(y, &e)
+		}
+	}
+	return &Share{Index: p.Index, x: x.Mod(x, order()), Group: g}, nil
+}
+
+// GroupKey is the public outcome of the key generation: Apk and the
+// verification key h*x_j of every party j.
+type GroupKey struct {
+	N, T         int
+	Pk           curve.PointAffine
+	Verification []curve.PointAffine
+}
+
+// PublicKey returns Apk, to encrypt to the parties.
+func (g GroupKey) PublicKey() util.Publickey {
+	return util.Publickey{Pk: g.Pk}
+}
+
+// Share is a party's share x_j of the regulator key.
+type Share struct {
+	Index int
+	x     *big.Int
+	Group GroupKey
+}
+
+// PartialDecryption is x_j*c2 and the proof that log_h(Y_j)=log_c2(D).
+type PartialDecryption struct {
+	Index int
+	D     curve.PointAffine
+	E, Z  *big.Int
+}
+
+// Decrypt returns s's partial decryption of cipher. The proof nonce is drawn
+// from rnd, crypto/rand when rnd is nil.
+func (s *Share) Decrypt(cipher []curve.PointAffine, rnd io.Reader) (PartialDecryption, error) {
+	if err := checkCipher(cipher); err != nil {
+		return PartialDecryption{}, err
+	}
+	c2 := cipher[1]
+	pd := PartialDecryption{Index: s.Index}
+	pd.D.ScalarMultiplication(&c2, s.x)
+
+	h := generator.H
+	w := util.RandomScalar(rnd, order())
+	var a1, a2 curve.PointAffine
+	a1.ScalarMultiplication(&h, w)
+	a2.ScalarMultiplication(&c2, w)
+	y := s.Group.Verification[s.Index-1]
+	pd.E = dleqChallenge(y, c2, pd.D, a1, a2)
+	pd.Z = new(big.Int).Mul(pd.E, s.x)
+	pd.Z.Add(pd.Z, w).Mod(pd.Z, order())
+	return pd, nil
+}
+
+// VerifyPartial checks the proof of pd for cipher. D and both points of
+// cipher must be in the prime-order subgroup.
+func (g GroupKey) VerifyPartial(cipher []curve.PointAffine, pd PartialDecryption) error {
+	if err := checkCipher(cipher); err != nil {
+		return err
+	}
+	if pd.Index < 1 || pd.Index > g.N || pd.E == nil || pd.Z == nil || !inSubgroup(&pd.D) {
+		return fmt.Errorf("%w: malformed share %d", ErrBadPartial, pd.Index)
+	}
+	c2 := cipher[1]
+	y := g.Verification[pd.Index-1]
+	h := generator.H
+
+	// a1=h*z-e*Y, a2=c2*z-e*D
+	a1 := mulSub(h, pd.Z, y, pd.E)
+	a2 := mulSub(c2, pd.Z, pd.D, pd.E)
+	if dleqChallenge(y, c2, pd.D, a1, a2).Cmp(pd.E) != 0 {
+		return fmt.Errorf("%w: share %d", ErrBadPartial, pd.Index)
+	}
+	return nil
+}
+
+// Combine checks the partial decryptions and interpolates the first T valid
+// ones from distinct parties into the plaintext of cipher. Invalid partials
+// are skipped, so that a faulty party cannot block the others; only when
+// fewer than T valid ones remain is the error ErrTooFew, naming the parties
+// whose partials were rejected.
+func (g GroupKey) Combine(cipher []curve.PointAffine, partials []PartialDecryption) (curve.PointAffine, error) {
+	var m curve.PointAffine
+	valid := make(map[int]PartialDecryption)
+	var rejected []int
+	for _, pd := range partials {
+		if _, dup := valid[pd.Index]; dup {
+			continue
+		}
+		if err := g.VerifyPartial(cipher, pd); err != nil {
+			rejected = append(rejected, pd.Index)
+			continue
+		}
+		valid[pd.Index] = pd
+		if len(valid) == g.T {
+			break
+		}
+	}
+	if len(valid) < g.T {
+		if len(rejected) > 0 {
+			return m, fmt.Errorf("%w: %d of %d, %w of %v", ErrTooFew, len(valid), g.T, ErrBadPartial, rejected)
+		}
+		return m, fmt.Errorf("%w: %d of %d", ErrTooFew, len(valid), g.T)
+	}
+
+	// sk*c2 = sum_j lambda_j*D_j, lambda_j = prod_{m!=j} m/(m-j)
+	var skc2 curve.PointAffine
+	skc2.X.SetZero()
+	skc2.Y.SetOne()
+	for j, pd := range valid {
+		num, den := big.NewInt(1), big.NewInt(1)
+		for k := range valid {
+			if k == j {
+				continue
+			}
+			num.Mul(num, big.NewInt(int64(k)))
+			den.Mul(den, big.NewInt(int64(k-j)))
+		}
+		den.Mod(den, order())
+		lambda := num.Mul(num, den.ModInverse(den, order()))
+		lambda.Mod(lambda, order())
+		var term curve.PointAffine
+		term.ScalarMultiplication(&pd.D, lambda)
+		skc2.Add(&skc2, &term)
+	}
+	skc2.Neg(&skc2)
+	m.Add(&cipher[0], &skc2)
+	return m, nil
+}
+
+// Simulate runs the key generation of n parties, threshold t, in process and
+// returns the group key and every share.
+func Simulate(n, t int, rnd io.Reader) (GroupKey, []*Share, error) {
+	parties := make([]*Party, n)
+	for i := range parties {
+		p, err := NewParty(i+1, n, t, rnd)
+		if err != nil {
+			return GroupKey{}, nil, err
+		}
+		parties[i] = p
+	}
+	for _, dealer := range parties {
+		d := dealer.Dealing()
+		for _, p := range parties {
+			if err := p.Receive(d, dealer.Share(p.Index)); err != nil {
+				return GroupKey{}, nil, err
+			}
+		}
+	}
+	shares := make([]*Share, n)
+	for i, p := range parties {
+		s, err := p.Finish()
+		if err != nil {
+			return GroupKey{}, nil, err
+		}
+		shares[i] = s
+	}
+	return shares[0].Group, shares, nil
+}
+
+func checkCipher(cipher []curve.PointAffine) error {
+	if len(cipher) != 2 {
+		return fmt.Errorf("regulator: ciphertext has %d points, want 2", len(cipher))
+	}
+	for _, p := range cipher {
+		if !inSubgroup(&p) {
+			return errors.New("regulator: ciphertext point not in the prime-order subgroup")
+		}
+	}
+	return nil
+}
+
+// inSubgroup reports whether p is on the curve and in its prime-order
+// subgroup: a point shifted by a torsion point of the cofactor is neither.
+func inSubgroup(p *curve.PointAffine) bool {
+	if !p.IsOnCurve() {
+		return false
+	}
+	return new(curve.PointAffine).ScalarMultiplication(p, order()).IsZero()
+}
+
+// mulSub is p*a-q*b.
+func mulSub(p curve.PointAffine, a *big.Int, q curve.PointAffine, b *big.Int) curve.PointAffine {
+	var pa, qb curve.PointAffine
+	pa.ScalarMultiplication(&p, a)
+	qb.ScalarMultiplication(&q, b)
+	qb.Neg(&qb)
+	pa.Add(&pa, &qb)
+	return pa
+}
+
+// dleqChallenge is mimc(label,h,Y,c2,D,a1,a2) mod the group order.
+func dleqChallenge(y, c2, d, a1, a2 curve.PointAffine) *big.Int {
+	mimc := hash.MIMC_BN254.New()
+	var label fr.Element
+	label.SetBytes([]byte(dleqLabel))
+	b := label.Bytes()
+	mimc.Write(b[:])
+	h := generator.H
+	for _, p := range []curve.PointAffine{h, y, c2, d, a1, a2} {
+		x, y := p.X.Bytes(), p.Y.Bytes()
+		mimc.Write(x[:])
+		mimc.Write(y[:])
+	}
+	e := new(big.Int).SetBytes(mimc.Sum(nil))
+	return e.Mod(e, order())
+}
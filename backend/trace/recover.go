@@ -0,0 +1,103 @@
+package trace
+
+import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/generator"
+	"Asyn_CBDC/backend/offlinetx"
+	"Asyn_CBDC/backend/util"
+	"errors"
+	"fmt"
+	"math/big"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+)
+
+var ErrForeignEntry = errors.New("trace: delta matches but the derived key is not of this Sk")
+
+// Account is an account recovered from the ledger.
+type Account struct {
+	Seq     uint64
+	Delta   *big.Int // published when the account is spent
+	Acc     []curve.PointAffine
+	Keypair offlinetx.DeriveKeypair
+	Value   curve.PointAffine // the plaintext less g1*delta: g0*bal+gc*state
+	TxID    string            // the spend that created the account
+	Spent   bool
+}
+
+// Balance returns the balance of a, given the frequency state it carries:
+// the zero state unless the wallet spent in FreqLimit mode. The balance is
+// searched in [0,max], max at most util.MaxDlog.
+func (a Account) Balance(state offlinetx.FreqState, max uint64) (uint64, error) {
+	gc := generator.Gc
+	var p curve.PointAffine
+	p.ScalarMultiplication(&gc, state.Scalar())
+	p.Neg(&p)
+	p.Add(&a.Value, &p)
+	return util.SmallDlog(generator.G0, p, max)
+}
+
+// Recovery is the account list of a wallet rebuilt from its keys.
+type Recovery struct {
+	Accounts []Account // seq order, only the last one unspent
+	Wallet   *offlinetx.Wallet
+}
+
+// Current returns the unspent account, if the wallet ever spent.
+func (r Recovery) Current() (Account, bool) {
+	if len(r.Accounts) == 0 {
+		return Account{}, false
+	}
+	return r.Accounts[len(r.Accounts)-1], true
+}
+
+// Recover rebuilds the wallet of trace key tk and secret key sk from the
+// ledger. It follows the spends from the enrolled account: the spend at seq
+// derives the account at seq+1 with the key alpha*sk, alpha recomputed from
+// sk, and so on until an account is unspent. The enrolled account itself is
+// issued by the bank and is not in the ledger; with no spend the recovery has
+// no account and its wallet is at enroll.FirstSeq.
+func Recover(s *Snapshot, tk, sk *big.Int) (Recovery, error) {
+	params := curve.GetEdwardsCurve()
+	order := &params.Order
+	h := generator.H
+	var pk curve.PointAffine
+	pk.ScalarMultiplication(&h, sk)
+
+	var rec Recovery
+	seq := uint64(enroll.FirstSeq)
+	for {
+		e, ok := s.Lookup(delta(tk, seq))
+		if !ok {
+			break
+		}
+		if n := len(rec.Accounts); n > 0 {
+			rec.Accounts[n-1].Spent = true
+		}
+		seq++
+		acc, err := derived(e, seq, tk, sk, pk, order)
+		if err != nil {
+			return Recovery{}, err
+		}
+		rec.Accounts = append(rec.Accounts, acc)
+	}
+	rec.Wallet = offlinetx.NewWallet(new(big.Int).SetUint64(seq))
+	return rec, nil
+}
+
+// derived decrypts the account at seq created by e.
+func derived(e Entry, seq uint64, tk, sk *big.Int, pk curve.PointAffine, order *big.Int) (Account, error) {
+	bigseq := new(big.Int).SetUint64(seq)
+	kp := offlinetx.DeriveKeypair{}.DkeypairGen(order, util.Publickey{Pk: pk}, util.Privatekey{Sk: sk}, bigseq, Hash)
+	if !kp.DPk.Pk.Equal(&e.DPublicKey) {
+		return Account{}, fmt.Errorf("%w: %s", ErrForeignEntry, e.TxID)
+	}
+	if len(e.DAcc) != 2 {
+		return Account{}, fmt.Errorf("trace: %s: derived account has %d points", e.TxID, len(e.DAcc))
+	}
+
+	acc := Account{Seq: seq, Delta: delta(tk, seq), Acc: e.DAcc, Keypair: kp, TxID: e.TxID}
+	plain := kp.DSk.Decryptacc(e.DAcc, new(curve.PointAffine).ScalarMultiplication(&generator.G1, acc.Delta))
+	acc.Value = *plain
+	return acc, nil
+}
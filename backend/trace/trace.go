@@ -0,0 +1,96 @@
+// Package trace links the accounts of a wallet through its trace key.
+// Spending the account at seq publishes delta=mimc(tk,seq) together with the
+// derived account at seq+1, so whoever holds tk recomputes the deltas and
+// finds them in the ledger: Scan does so for an auditor holding a Tracesk,
+// Recover for a wallet that also holds its Sk and lost everything else.
+package trace
+
+import (
+	"Asyn_CBDC/backend/offlinetx"
+	"Asyn_CBDC/backend/util"
+	"fmt"
+	"math/big"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+	"github.com/consensys/gnark-crypto/hash"
+)
+
+// Hash is the hash of the deltas, the MiMC of the circuits.
+const Hash = hash.MIMC_BN254
+
+// Entry is a settled offline spend as the ledger keeps it: the delta of the
+// spent account and the derived account with its key.
+type Entry struct {
+	TxID       string
+	Delta      *big.Int
+	DAcc       []curve.PointAffine
+	DPublicKey curve.PointAffine
+}
+
+// EntryOf is the ledger entry of a verified offline proof.
+func EntryOf(pub offlinetx.PublicInputs, txid string) Entry {
+	return Entry{TxID: txid, Delta: pub.Delta, DAcc: pub.DAcc, DPublicKey: *pub.DPublicKey}
+}
+
+// Snapshot is the ledger at some point, indexed by delta.
+type Snapshot struct {
+	entries []Entry
+	byDelta map[string]int
+}
+
+// NewSnapshot indexes entries. A delta appears at most once: the nullifier
+// store rejected any second spend.
+func NewSnapshot(entries []Entry) (*Snapshot, error) {
+	s := &Snapshot{byDelta: make(map[string]int, len(entries))}
+	for _, e := range entries {
+		if e.Delta == nil {
+			return nil, fmt.Errorf("trace: entry %s without delta", e.TxID)
+		}
+		if i, dup := s.byDelta[e.Delta.String()]; dup {
+			return nil, fmt.Errorf("trace: delta of %s spent again by %s", s.entries[i].TxID, e.TxID)
+		}
+		s.byDelta[e.Delta.String()] = len(s.entries)
+		s.entries = append(s.entries, e)
+	}
+	return s, nil
+}
+
+// Len returns the number of entries.
+func (s *Snapshot) Len() int {
+	return len(s.entries)
+}
+
+// Lookup returns the entry spending delta.
+func (s *Snapshot) Lookup(delta *big.Int) (Entry, bool) {
+	i, ok := s.byDelta[delta.String()]
+	if !ok {
+		return Entry{}, false
+	}
+	return s.entries[i], true
+}
+
+// Match is a spend of the traced wallet: Entry spent its account at Seq and
+// created the one at Seq+1.
+type Match struct {
+	Seq   uint64
+	Entry Entry
+}
+
+// Scan returns the spends of the wallet of trace key tk among the accounts at
+// seq in [from,to], in seq order.
+func Scan(s *Snapshot, tk *big.Int, from, to uint64) []Match {
+	var matches []Match
+	for seq := from; seq <= to; seq++ {
+		if e, ok := s.Lookup(delta(tk, seq)); ok {
+			matches = append(matches, Match{Seq: seq, Entry: e})
+		}
+		if seq == ^uint64(0) {
+			break
+		}
+	}
+	return matches
+}
+
+func delta(tk *big.Int, seq uint64) *big.Int {
+	return util.Calculate_delta(tk, new(big.Int).SetUint64(seq), Hash)
+}
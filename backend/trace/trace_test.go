@@ -0,0 +1,161 @@
+package trace
+
+import (
+	"Asyn_CBDC/backend/enroll"
+	"Asyn_CBDC/backend/offlinetx"
+	"Asyn_CBDC/backend/util"
+	"Asyn_CBDC/internal/testutil"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	ecctedwards "github.com/consensys/gnark-crypto/ecc/twistededwards"
+	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+)
+
+// chain spends the enrolled account of a wallet n times, leaving balances[i]
+// in the account at seq i+1, and returns the ledger entries.
+func chain(t *testing.T, name string, balances []int64, freq offlinetx.FreqState) (enroll.Enroll, []Entry) {
+	t.Helper()
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	rnd := util.NewSeededReader([]byte(name))
+	e := enroll.NewEnroll().Init(params, Hash, rnd)
+	priacc := offlinetx.PrimitiveAccount{
+		G0: e.G0, G1: e.G1, H: e.H,
+		Tracesk: e.Tracesk, Sk: e.Sk, Pk: e.Pk,
+	}
+	var entries []Entry
+	for i, bal := range balances {
+		seq := uint64(enroll.FirstSeq + i)
+		d := offlinetx.DeriveAccount{Freq: freq}
+		d = d.ChangeGen(params, Hash, new(big.Int).SetUint64(seq+1), priacc, *big.NewInt(bal), rnd)
+		entries = append(entries, Entry{
+			TxID:       fmt.Sprintf("%s-%d", name, seq),
+			Delta:      delta(e.Tracesk.Sk, seq),
+			DAcc:       d.Acc,
+			DPublicKey: d.Keypair.DPk.Pk,
+		})
+	}
+	return e, entries
+}
+
+func snapshot(t *testing.T, entries ...[]Entry) *Snapshot {
+	t.Helper()
+	var all []Entry
+	for _, e := range entries {
+		all = append(all, e...)
+	}
+	s, err := NewSnapshot(all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestScan(t *testing.T) {
+	alice, a := chain(t, "alice", []int64{150, 120, 100}, offlinetx.FreqState{})
+	bob, b := chain(t, "bob", []int64{10, 5}, offlinetx.FreqState{})
+	s := snapshot(t, a, b)
+
+	got := Scan(s, alice.Tracesk.Sk, 0, 10)
+	if len(got) != 3 {
+		t.Fatalf("%d matches, want 3", len(got))
+	}
+	for i, m := range got {
+		if m.Seq != uint64(i) || m.Entry.TxID != a[i].TxID {
+			t.Errorf("match %d: seq %d, %s", i, m.Seq, m.Entry.TxID)
+		}
+	}
+	if got := Scan(s, alice.Tracesk.Sk, 1, 1); len(got) != 1 || got[0].Entry.TxID != "alice-1" {
+		t.Errorf("range [1,1]: %+v", got)
+	}
+	if got := Scan(s, bob.Tracesk.Sk, 2, 100); len(got) != 0 {
+		t.Errorf("bob beyond his spends: %+v", got)
+	}
+
+	if _, err := NewSnapshot(append(a, a[0])); err == nil {
+		t.Error("delta spent twice accepted")
+	}
+}
+
+func TestRecover(t *testing.T) {
+	alice, a := chain(t, "alice", []int64{150, 120, 100}, offlinetx.FreqState{})
+	_, b := chain(t, "bob", []int64{10}, offlinetx.FreqState{})
+	s := snapshot(t, b, a)
+
+	rec, err := Recover(s, alice.Tracesk.Sk, alice.Sk.Sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Accounts) != 3 || rec.Wallet.Seq().Int64() != 3 {
+		t.Fatalf("%d accounts, wallet at %v", len(rec.Accounts), rec.Wallet.Seq())
+	}
+	for i, acc := range rec.Accounts {
+		if acc.Seq != uint64(i+1) || acc.Spent != (i < 2) || acc.TxID != a[i].TxID {
+			t.Errorf("account %d: %+v", i, acc)
+		}
+		bal, err := acc.Balance(offlinetx.FreqState{}, 1_000)
+		if err != nil || bal != []uint64{150, 120, 100}[i] {
+			t.Errorf("account %d: balance %d, %v", i, bal, err)
+		}
+	}
+	if cur, ok := rec.Current(); !ok || cur.Seq != 3 || cur.Spent {
+		t.Errorf("current %+v", cur)
+	}
+
+	// the trace key alone links the spends, the wrong Sk cannot open them
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	carol := enroll.NewEnroll().Init(params, Hash, util.NewSeededReader([]byte("carol")))
+	if _, err := Recover(s, alice.Tracesk.Sk, carol.Sk.Sk); !errors.Is(err, ErrForeignEntry) {
+		t.Errorf("wrong Sk: %v", err)
+	}
+	rec, err = Recover(s, carol.Tracesk.Sk, carol.Sk.Sk)
+	if err != nil || len(rec.Accounts) != 0 || rec.Wallet.Seq().Int64() != enroll.FirstSeq {
+		t.Errorf("wallet that never spent: %+v, %v", rec, err)
+	}
+}
+
+func TestRecoverFreqState(t *testing.T) {
+	state := offlinetx.FreqState{Period: 1_759_968_000, Counter: 3}
+	alice, a := chain(t, "alice", []int64{42}, state)
+	rec, err := Recover(snapshot(t, a), alice.Tracesk.Sk, alice.Sk.Sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal, err := rec.Accounts[0].Balance(state, 1_000); err != nil || bal != 42 {
+		t.Errorf("balance %d, %v", bal, err)
+	}
+	if _, err := rec.Accounts[0].Balance(offlinetx.FreqState{}, 1_000); err == nil {
+		t.Error("balance found without the frequency state")
+	}
+}
+
+func TestRecoverOffline(t *testing.T) {
+	params, _ := twistededwards.GetCurveParams(ecctedwards.BN254)
+	is, err := testutil.Issuer(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := offlinetx.Offline{Mode: offlinetx.NoRegulation, Issuer: is}
+	o, err = o.Execution(params, Hash, ecctedwards.BN254, util.NewSeededReader([]byte(t.Name())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := snapshot(t, []Entry{EntryOf(o.PublicInputs(offlinetx.NoRegulation), "tx")})
+
+	rec, err := Recover(s, o.Tracesk.Sk, o.Sk.Sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, ok := rec.Current()
+	if !ok || cur.Seq != 1 {
+		t.Fatalf("current %+v", cur)
+	}
+	if bal, err := cur.Balance(o.NewFreq, 1_000); err != nil || bal != 200 {
+		t.Errorf("balance %d, %v", bal, err)
+	}
+	if cur.Keypair.Deriver.Cmp(o.Deriveacc.Keypair.Deriver) != 0 {
+		t.Error("recovered key differs from the wallet's")
+	}
+}
@@ -0,0 +1,96 @@
+// Package transcript derives the Fiat-Shamir challenges of the online proofs.
+// A Transcript absorbs the statement and the prover's messages under labels,
+// and squeezes each challenge from everything absorbed before it. Transcripts
+// of different protocols, or of another Version, never share a challenge.
+package transcript
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math/big"
+)
+
+// Version of the challenge derivation. Bump it whenever what a protocol
+// absorbs changes, so that old and new proofs cannot be mixed.
+const Version = 1
+
+const domain = "Asyn_CBDC/transcript"
+
+// Point is a curve point of either BabyJubjub or BN254.
+type Point interface {
+	Marshal() []byte
+}
+
+// Transcript is the running state of one proof. Prover and verifier must
+// append the same messages in the same order.
+type Transcript struct {
+	state [sha256.Size]byte
+}
+
+// New starts the transcript of protocol, e.g. "bulletproof/range".
+func New(protocol string) *Transcript {
+	t := new(Transcript)
+	t.Append("domain", []byte(domain))
+	t.AppendInt("version", Version)
+	t.Append("protocol", []byte(protocol))
+	return t
+}
+
+// Append absorbs data under label. Both are length-prefixed, so no two
+// sequences of appends absorb the same bytes.
+func (t *Transcript) Append(label string, data []byte) {
+	h := sha256.New()
+	h.Write(t.state[:])
+	writeLen(h, len(label))
+	h.Write([]byte(label))
+	writeLen(h, len(data))
+	h.Write(data)
+	h.Sum(t.state[:0])
+}
+
+// AppendPoint absorbs the encoding of p under label.
+func (t *Transcript) AppendPoint(label string, p Point) {
+	t.Append(label, p.Marshal())
+}
+
+// AppendPoints absorbs ps under label, their number first.
+func (t *Transcript) AppendPoints(label string, ps ...Point) {
+	t.AppendInt(label, int64(len(ps)))
+	for _, p := range ps {
+		t.AppendPoint(label, p)
+	}
+}
+
+// AppendScalar absorbs the big-endian bytes of s under label, with its sign.
+func (t *Transcript) AppendScalar(label string, s *big.Int) {
+	t.Append(label, append([]byte{byte(s.Sign() + 1)}, s.Bytes()...))
+}
+
+// AppendInt absorbs n under label.
+func (t *Transcript) AppendInt(label string, n int64) {
+	t.Append(label, binary.BigEndian.AppendUint64(nil, uint64(n)))
+}
+
+// Challenge squeezes the challenge label in [0,order) and absorbs it. It
+// reduces 512 bits, so its bias is below 2^-256 for any order up to 256 bits.
+func (t *Transcript) Challenge(label string, order *big.Int) *big.Int {
+	var wide []byte
+	for i := byte(0); i < 2; i++ {
+		h := sha256.New()
+		h.Write(t.state[:])
+		h.Write([]byte("challenge"))
+		writeLen(h, len(label))
+		h.Write([]byte(label))
+		h.Write([]byte{i})
+		wide = h.Sum(wide)
+	}
+	c := new(big.Int).SetBytes(wide)
+	c.Mod(c, order)
+	t.AppendScalar(label, c)
+	return c
+}
+
+func writeLen(h io.Writer, n int) {
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(n)))
+}
@@ -0,0 +1,54 @@
+package transcript
+
+import (
+	"math/big"
+	"testing"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254"
+)
+
+func TestChallenge(t *testing.T) {
+	order := big.NewInt(1000003)
+	_, _, g, _ := curve.Generators()
+	run := func(protocol string, label string, p *curve.G1Affine) *big.Int {
+		tr := New(protocol)
+		tr.AppendPoint(label, p)
+		tr.AppendScalar("s", big.NewInt(7))
+		return tr.Challenge("c", order)
+	}
+
+	c := run("a", "p", &g)
+	if c.Cmp(run("a", "p", &g)) != 0 {
+		t.Fatal("same transcript, different challenges")
+	}
+	if c.Sign() < 0 || c.Cmp(order) >= 0 {
+		t.Fatalf("challenge %v out of [0,%v)", c, order)
+	}
+	var g2 curve.G1Affine
+	g2.Double(&g)
+	for name, other := range map[string]*big.Int{
+		"protocol": run("b", "p", &g),
+		"label":    run("a", "q", &g),
+		"point":    run("a", "p", &g2),
+	} {
+		if c.Cmp(other) == 0 {
+			t.Errorf("another %s gave the same challenge", name)
+		}
+	}
+
+	// a challenge is absorbed: the next one differs even with the same label
+	tr := New("a")
+	if tr.Challenge("c", order).Cmp(tr.Challenge("c", order)) == 0 {
+		t.Error("two challenges in a row are equal")
+	}
+
+	// lengths are absorbed too, so moving bytes between appends shows
+	t1, t2 := New("a"), New("a")
+	t1.Append("x", []byte("ab"))
+	t1.Append("x", []byte("c"))
+	t2.Append("x", []byte("a"))
+	t2.Append("x", []byte("bc"))
+	if t1.Challenge("c", order).Cmp(t2.Challenge("c", order)) == 0 {
+		t.Error("different splits gave the same challenge")
+	}
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"github.com/consensys/gnark/frontend"
 	"github.com/consensys/gnark/std/algebra/native/twistededwards"
+	"github.com/consensys/gnark/std/hash/mimc"
 )
 
 type Account struct {
@@ -10,18 +11,56 @@ type Account struct {
 	B twistededwards.Point
 }
 
-func CalculateTK(curve twistededwards.Curve, g *twistededwards.CurveParams, tk frontend.Variable) twistededwards.Point {
-	G := twistededwards.Point{X: g.Base[0], Y: g.Base[1]}
+// AssertPointEqual checks both coordinates. X alone is not enough: (x,-y) is
+// on the curve too, it is P plus the point (0,-1) of order two.
+func AssertPointEqual(api frontend.API, p, q twistededwards.Point) {
+	api.AssertIsEqual(p.X, q.X)
+	api.AssertIsEqual(p.Y, q.Y)
+}
+
+func AssertAccountEqual(api frontend.API, a, b Account) {
+	AssertPointEqual(api, a.A, b.A)
+	AssertPointEqual(api, a.B, b.B)
+}
 
-	TK := curve.ScalarMul(G, tk)
+// AssertIsUint64 constrains v to [0,2^64): amounts are 64-bit values, not
+// field elements that may wrap around.
+func AssertIsUint64(api frontend.API, v frontend.Variable) {
+	api.ToBinary(v, 64)
+}
+
+// TK=g2*tk
+func CalculateTK(curve twistededwards.Curve, g2 twistededwards.Point, tk frontend.Variable) twistededwards.Point {
+	TK := curve.ScalarMul(g2, tk)
 	return TK
 }
 
+// delta=mimc(tk,seq), equal to Calculate_delta outside the circuit
+func CalculateDelta(api frontend.API, tk, seq frontend.Variable) (frontend.Variable, error) {
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(tk, seq)
+	return h.Sum(), nil
+}
+
+// AccountDigest is mimc(A.X,A.Y,B.X,B.Y,seq,expiry,limit,aux.X,aux.Y), the
+// message signed by the bank for an account, equal to Account_digest outside
+// the circuit
+func AccountDigest(api frontend.API, acc Account, seq, expiry, limit frontend.Variable, aux twistededwards.Point) (frontend.Variable, error) {
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return nil, err
+	}
+	h.Write(acc.A.X, acc.A.Y, acc.B.X, acc.B.Y, seq, expiry, limit, aux.X, aux.Y)
+	return h.Sum(), nil
+}
+
 // acc=(g0*bal+(g1*delta0)+r*pk,r*h),bal=_
-func EncryptAcc(curve twistededwards.Curve, plain twistededwards.Point, pk twistededwards.Point, r frontend.Variable, g *twistededwards.CurveParams) Account {
+func EncryptAcc(curve twistededwards.Curve, plain twistededwards.Point, pk twistededwards.Point, r frontend.Variable, h twistededwards.Point) Account {
 	c1 := curve.Add(plain, curve.ScalarMul(pk, r))
-	H := twistededwards.Point{X: g.Base[0], Y: g.Base[1]}
-	c2 := curve.ScalarMul(H, r)
+	c2 := curve.ScalarMul(h, r)
 	return Account{c1, c2}
 }
 
@@ -33,27 +72,22 @@ func DecryptAcc(curve twistededwards.Curve, cipher Account, sk frontend.Variable
 	return _plain
 }
 
-func EncryptPk(curve twistededwards.Curve, m twistededwards.Point, pk twistededwards.Point, r frontend.Variable, g *twistededwards.CurveParams) []twistededwards.Point {
+func EncryptPk(curve twistededwards.Curve, m twistededwards.Point, pk twistededwards.Point, r frontend.Variable, h twistededwards.Point) []twistededwards.Point {
 	c1 := curve.Add(m, curve.ScalarMul(pk, r))
-
-	h := twistededwards.Point{X: g.Base[0], Y: g.Base[1]}
 	c2 := curve.ScalarMul(h, r)
 	return []twistededwards.Point{c1, c2}
 }
 
-func RegulationTK(curve twistededwards.Curve, g *twistededwards.CurveParams, cipher []twistededwards.Point, a frontend.Variable) ([]twistededwards.Point, twistededwards.Point) {
+func RegulationTK(curve twistededwards.Curve, h twistededwards.Point, cipher []twistededwards.Point, a frontend.Variable) ([]twistededwards.Point, twistededwards.Point) {
 	c1 := curve.ScalarMul(cipher[0], a)
 	c2 := curve.ScalarMul(cipher[1], a)
 
-	h := twistededwards.Point{X: g.Base[0], Y: g.Base[1]}
 	aux := curve.ScalarMul(h, a)
 
 	return []twistededwards.Point{c1, c2}, aux
 }
 
-func Pedersen(curve twistededwards.Curve, G, H *twistededwards.CurveParams, date, r frontend.Variable) twistededwards.Point {
-	h := twistededwards.Point{X: H.Base[0], Y: H.Base[1]}
-	g := twistededwards.Point{X: G.Base[0], Y: G.Base[1]}
+func Pedersen(curve twistededwards.Curve, g, h twistededwards.Point, date, r frontend.Variable) twistededwards.Point {
 	res := curve.Add(curve.ScalarMul(g, date), curve.ScalarMul(h, r))
 	return res
 }
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/hash"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+type deltaCircuit struct {
+	TacSk frontend.Variable
+	Seq   frontend.Variable
+	Delta frontend.Variable `gnark:",public"`
+}
+
+func (c *deltaCircuit) Define(api frontend.API) error {
+	delta, err := CalculateDelta(api, c.TacSk, c.Seq)
+	if err != nil {
+		return err
+	}
+	api.AssertIsEqual(delta, c.Delta)
+	return nil
+}
+
+func TestDeltaMatchesCircuit(t *testing.T) {
+	modulus := ecc.BN254.ScalarField()
+	cases := []struct{ tk, seq *big.Int }{
+		{big.NewInt(5), big.NewInt(7)}, // short encodings
+		{new(big.Int).Sub(modulus, big.NewInt(2)), big.NewInt(1)},
+		{big.NewInt(9), new(big.Int).Add(modulus, big.NewInt(3))}, // seq reduced mod r
+	}
+	for _, c := range cases {
+		delta := Calculate_delta(c.tk, c.seq, hash.MIMC_BN254)
+		assignment := deltaCircuit{TacSk: c.tk, Seq: c.seq, Delta: delta}
+		if err := test.IsSolved(&deltaCircuit{}, &assignment, modulus); err != nil {
+			t.Fatalf("tk=%s seq=%s: %v", c.tk, c.seq, err)
+		}
+	}
+}
+
+func TestDeltaEncodingIsUnambiguous(t *testing.T) {
+	// 0x01||0x0203 and 0x0102||0x03 concatenate to the same bytes
+	d1 := Calculate_delta(big.NewInt(0x01), big.NewInt(0x0203), hash.MIMC_BN254)
+	d2 := Calculate_delta(big.NewInt(0x0102), big.NewInt(0x03), hash.MIMC_BN254)
+	if d1.Cmp(d2) == 0 {
+		t.Fatal("different (tk, seq) pairs gave the same delta")
+	}
+}
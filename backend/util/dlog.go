@@ -0,0 +1,47 @@
+package util
+
+import (
+	"errors"
+	"math/big"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+)
+
+var ErrDlogRange = errors.New("util: discrete log out of the searched range")
+
+// MaxDlog bounds the range of SmallDlog, whose 2^20 baby steps are then all
+// held in memory.
+const MaxDlog = 1 << 40
+
+// SmallDlog returns v in [0,max] with m=base*v, by baby-step giant-step in
+// O(sqrt(max)) time and memory. Amounts are 64-bit but small in practice; a
+// max above MaxDlog fails with ErrDlogRange.
+func SmallDlog(base, m curve.PointAffine, max uint64) (uint64, error) {
+	if max > MaxDlog {
+		return 0, ErrDlogRange
+	}
+	step := new(big.Int).Sqrt(new(big.Int).SetUint64(max)).Uint64() + 1
+
+	// baby steps: base*j for j in [0,step)
+	baby := make(map[curve.PointAffine]uint64, step)
+	var p curve.PointAffine
+	p.X.SetZero()
+	p.Y.SetOne()
+	for j := uint64(0); j < step; j++ {
+		baby[p] = j
+		p.Add(&p, &base)
+	}
+
+	// giant steps: m - base*step*i
+	var giant curve.PointAffine
+	giant.ScalarMultiplication(&base, new(big.Int).SetUint64(step))
+	giant.Neg(&giant)
+	q := m
+	for i := uint64(0); i*step <= max; i++ {
+		if j, ok := baby[q]; ok && i*step+j <= max {
+			return i*step + j, nil
+		}
+		q.Add(&q, &giant)
+	}
+	return 0, ErrDlogRange
+}
@@ -0,0 +1,35 @@
+package util
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
+)
+
+func TestSmallDlog(t *testing.T) {
+	base := curve.GetEdwardsCurve().Base
+	for _, c := range []struct{ v, max uint64 }{
+		{0, 0}, {0, 1}, {1, 1}, {3, 3}, {15, 15}, {16, 16}, {17, 17}, {99, 1000}, {1000, 1000},
+	} {
+		var m curve.PointAffine
+		m.ScalarMultiplication(&base, new(big.Int).SetUint64(c.v))
+		got, err := SmallDlog(base, m, c.max)
+		if err != nil || got != c.v {
+			t.Errorf("v=%d max=%d: got %d, %v", c.v, c.max, got, err)
+		}
+	}
+
+	var m curve.PointAffine
+	m.ScalarMultiplication(&base, big.NewInt(17))
+	if _, err := SmallDlog(base, m, 16); !errors.Is(err, ErrDlogRange) {
+		t.Errorf("v above max: %v", err)
+	}
+	// a max near 2^64 used to overflow step*step and loop forever
+	for _, max := range []uint64{MaxDlog + 1, 1<<64 - 1} {
+		if _, err := SmallDlog(base, m, max); !errors.Is(err, ErrDlogRange) {
+			t.Errorf("max=%d: %v", max, err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package util
 import (
 	"math/big"
 
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
 	curve "github.com/consensys/gnark-crypto/ecc/bn254/twistededwards"
 	"github.com/consensys/gnark-crypto/hash"
 	"github.com/consensys/gnark-crypto/signature"
@@ -36,14 +37,70 @@ func Calculate_TK(g *curve.PointAffine, tk *big.Int) *curve.PointAffine {
 	return TK
 }
 
-func Calculate_delta(data []byte, hash hash.Hash) *big.Int {
+// DeltaData encodes (tk, seq) as two canonical 32-byte field elements, the
+// same inputs mimc.Write(tk, seq) absorbs in a circuit.
+func DeltaData(tk, seq *big.Int) []byte {
+	var e fr.Element
+	e.SetBigInt(tk)
+	_tk := e.Bytes()
+	e.SetBigInt(seq)
+	_seq := e.Bytes()
+	return append(_tk[:], _seq[:]...)
+}
+
+// delta=mimc(tk,seq), equal to CalculateDelta in a circuit
+func Calculate_delta(tk, seq *big.Int, hash hash.Hash) *big.Int {
 	hashfunc := hash.New()
-	hashfunc.Write(data)
+	hashfunc.Write(DeltaData(tk, seq))
 	_delta := hashfunc.Sum(nil)
 	delta := new(big.Int).SetBytes(_delta)
 	return delta
 }
 
+// alphaLabel separates the derivation factor from delta=mimc(tk,seq).
+const alphaLabel = "Asyn_CBDC/derive-alpha/v1"
+
+// Derive_alpha is mimc(sk,seq,label) mod order, never 0: the factor of the
+// derived key of the account at seq, dpk=alpha*pk. The wallet recomputes it
+// from sk alone.
+func Derive_alpha(sk, seq, order *big.Int, hash hash.Hash) *big.Int {
+	hashfunc := hash.New()
+	hashfunc.Write(DeltaData(sk, seq))
+	var label fr.Element
+	label.SetBytes([]byte(alphaLabel))
+	b := label.Bytes()
+	hashfunc.Write(b[:])
+	alpha := new(big.Int).SetBytes(hashfunc.Sum(nil))
+	alpha.Mod(alpha, order)
+	if alpha.Sign() == 0 {
+		alpha.SetInt64(1)
+	}
+	return alpha
+}
+
+// Account_digest is mimc(A.X,A.Y,B.X,B.Y,seq,expiry,limit,aux.X,aux.Y), the
+// message the bank signs to certify an account; equal to AccountDigest in a
+// circuit.
+func Account_digest(acc []curve.PointAffine, seq, expiry, limit *big.Int, aux *curve.PointAffine, hash hash.Hash) []byte {
+	hashfunc := hash.New()
+	for _, p := range acc[:2] {
+		x, y := p.X.Bytes(), p.Y.Bytes()
+		hashfunc.Write(x[:])
+		hashfunc.Write(y[:])
+	}
+	var e fr.Element
+	_seq := e.SetBigInt(seq).Bytes()
+	hashfunc.Write(_seq[:])
+	_expiry := e.SetBigInt(expiry).Bytes()
+	hashfunc.Write(_expiry[:])
+	_limit := e.SetBigInt(limit).Bytes()
+	hashfunc.Write(_limit[:])
+	x, y := aux.X.Bytes(), aux.Y.Bytes()
+	hashfunc.Write(x[:])
+	hashfunc.Write(y[:])
+	return hashfunc.Sum(nil)
+}
+
 func Regulation_PK(cipher []curve.PointAffine, a *big.Int) []curve.PointAffine {
 	c1 := new(curve.PointAffine).ScalarMultiplication(&cipher[0], a)
 	c2 := new(curve.PointAffine).ScalarMultiplication(&cipher[1], a)
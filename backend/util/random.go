@@ -0,0 +1,53 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"math/big"
+)
+
+// RandomScalar draws a uniform scalar in [1, order) from rnd. A nil rnd means
+// crypto/rand. rnd is expected not to fail, a short read panics.
+func RandomScalar(rnd io.Reader, order *big.Int) *big.Int {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	max := new(big.Int).Sub(order, big.NewInt(1))
+	k, err := rand.Int(rnd, max)
+	if err != nil {
+		panic("util: reading randomness: " + err.Error())
+	}
+	return k.Add(k, big.NewInt(1))
+}
+
+// seededReader expands a seed with SHA-256 in counter mode.
+type seededReader struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+// NewSeededReader returns a deterministic reader for reproducible tests.
+// Never use it for real keys.
+func NewSeededReader(seed []byte) io.Reader {
+	return &seededReader{seed: append([]byte(nil), seed...)}
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			var ctr [8]byte
+			binary.BigEndian.PutUint64(ctr[:], r.counter)
+			r.counter++
+			block := sha256.Sum256(append(append([]byte(nil), r.seed...), ctr[:]...))
+			r.buf = block[:]
+		}
+		c := copy(p[n:], r.buf)
+		r.buf = r.buf[c:]
+		n += c
+	}
+	return n, nil
+}
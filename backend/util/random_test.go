@@ -0,0 +1,31 @@
+package util
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+)
+
+func TestRandomScalarRange(t *testing.T) {
+	order := big.NewInt(5)
+	for i := 0; i < 200; i++ {
+		k := RandomScalar(nil, order)
+		if k.Sign() <= 0 || k.Cmp(order) >= 0 {
+			t.Fatalf("scalar %s out of [1, %s)", k, order)
+		}
+	}
+}
+
+func TestSeededReaderIsReproducible(t *testing.T) {
+	a := make([]byte, 100)
+	b := make([]byte, 100)
+	NewSeededReader([]byte("seed")).Read(a)
+	NewSeededReader([]byte("seed")).Read(b)
+	if !bytes.Equal(a, b) {
+		t.Fatal("same seed gave different streams")
+	}
+	NewSeededReader([]byte("other")).Read(b)
+	if bytes.Equal(a, b) {
+		t.Fatal("different seeds gave the same stream")
+	}
+}
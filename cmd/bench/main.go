@@ -0,0 +1,77 @@
+// Command bench measures the circuits and the online proofs, writes the
+// report as JSON and fails if a constraint count exceeds the budget.
+//
+//	go run ./cmd/bench -o bench.json
+//	go run ./cmd/bench -backend plonk -count
+package main
+
+import (
+	"Asyn_CBDC/backend/bench"
+	"Asyn_CBDC/backend/keys"
+	"Asyn_CBDC/internal/testutil"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/consensys/gnark/logger"
+)
+
+func main() {
+	out := flag.String("o", "", "write the report to this file instead of stdout")
+	budget := flag.String("budget", bench.BudgetFile, "budget of constraint counts")
+	count := flag.Bool("count", false, "only compile the circuits and check their counts")
+	name := flag.String("backend", string(keys.Groth16), "proof backend, groth16 or plonk")
+	flag.Parse()
+	// gnark logs to stdout, where the report goes
+	logger.Disable()
+
+	backend, err := keys.ParseBackend(*name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	b, err := bench.LoadBudget(*budget)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var counts map[string]int
+	if *count {
+		counts, err = bench.Constraints(backend)
+		if err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		// the test accounts are signed by an issuer of the run's own
+		is, err := testutil.Issuer(nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r, err := bench.Run(backend, is)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := write(*out, r); err != nil {
+			log.Fatal(err)
+		}
+		counts = r.Counts()
+	}
+
+	if err := b.Check(backend, counts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func write(path string, r bench.Report) error {
+	if path == "" {
+		return r.WriteJSON(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
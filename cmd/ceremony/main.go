@@ -0,0 +1,190 @@
+// Command ceremony runs the multi-party Groth16 setup of the circuits from
+// files. The coordinator initializes each phase; participants in turn read
+// the last state, contribute and publish the next one; anyone verifies the
+// transcript; the coordinator finally extracts the keys into a key store.
+//
+//	ceremony phase1-init -o phase1.0
+//	ceremony phase1-contribute -i phase1.0 -o phase1.1
+//	ceremony phase1-verify phase1.0 phase1.1 ...
+//	ceremony phase2-init -circuit offline_freqlimit -phase1 phase1.n -o freqlimit.0
+//	ceremony phase2-contribute -i freqlimit.0 -o freqlimit.1
+//	ceremony phase2-verify -circuit offline_freqlimit -phase1 phase1.n freqlimit.0 freqlimit.1 ...
+//	ceremony extract -circuit offline_freqlimit -phase1 phase1.0,...,phase1.n -keys keys freqlimit.0 ...
+package main
+
+import (
+	"Asyn_CBDC/backend/ceremony"
+	"Asyn_CBDC/backend/keys"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/consensys/gnark/logger"
+)
+
+func main() {
+	log.SetFlags(0)
+	logger.Disable()
+	if len(os.Args) < 2 {
+		usage()
+	}
+	cmd, args := os.Args[1], os.Args[2:]
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	var err error
+	switch cmd {
+	case "phase1-init":
+		power := fs.Int("power", ceremony.MaxPower, "log2 of the number of powers of tau")
+		out := fs.String("o", "", "output state")
+		fs.Parse(args)
+		err = ceremony.WritePhase1(required(fs, *out), ceremony.InitPhase1(*power))
+	case "phase1-contribute":
+		in := fs.String("i", "", "last state")
+		out := fs.String("o", "", "output state")
+		fs.Parse(args)
+		err = phase1Contribute(required(fs, *in), required(fs, *out))
+	case "phase1-verify":
+		fs.Parse(args)
+		err = phase1Verify(fs.Args())
+	case "phase2-init":
+		circuit := fs.String("circuit", "", "circuit, one of "+strings.Join(ceremony.Circuits(), ", "))
+		phase1 := fs.String("phase1", "", "final phase 1 state")
+		out := fs.String("o", "", "output state")
+		fs.Parse(args)
+		err = phase2Init(required(fs, *circuit), required(fs, *phase1), required(fs, *out))
+	case "phase2-contribute":
+		in := fs.String("i", "", "last state")
+		out := fs.String("o", "", "output state")
+		fs.Parse(args)
+		err = phase2Contribute(required(fs, *in), required(fs, *out))
+	case "phase2-verify":
+		circuit := fs.String("circuit", "", "circuit")
+		phase1 := fs.String("phase1", "", "final phase 1 state")
+		fs.Parse(args)
+		err = phase2Verify(required(fs, *circuit), required(fs, *phase1), fs.Args())
+	case "extract":
+		circuit := fs.String("circuit", "", "circuit")
+		phase1 := fs.String("phase1", "", "comma-separated phase 1 transcript")
+		dir := fs.String("keys", keys.DefaultStore().Dir, "key store")
+		fs.Parse(args)
+		err = extract(required(fs, *circuit), strings.Split(required(fs, *phase1), ","), fs.Args(), keys.NewStore(*dir))
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: ceremony phase1-init|phase1-contribute|phase1-verify|phase2-init|phase2-contribute|phase2-verify|extract [flags]")
+	os.Exit(2)
+}
+
+func required(fs *flag.FlagSet, v string) string {
+	if v == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return v
+}
+
+func phase1Contribute(in, out string) error {
+	chain, err := ceremony.ReadPhase1(in)
+	if err != nil {
+		return err
+	}
+	chain[0].Contribute()
+	if err := ceremony.WritePhase1(out, chain[0]); err != nil {
+		return err
+	}
+	// the participant publishes this hash to attest its contribution
+	fmt.Printf("%x\n", chain[0].Hash)
+	return nil
+}
+
+func phase1Verify(paths []string) error {
+	chain, err := ceremony.ReadPhase1(paths...)
+	if err != nil {
+		return err
+	}
+	if err := ceremony.VerifyPhase1(chain); err != nil {
+		return err
+	}
+	for i, p := range chain[1:] {
+		fmt.Printf("%d %x\n", i+1, p.Hash)
+	}
+	return nil
+}
+
+func phase2Init(circuit, phase1, out string) error {
+	r1cs, err := ceremony.Compile(circuit)
+	if err != nil {
+		return err
+	}
+	srs1, err := ceremony.ReadPhase1(phase1)
+	if err != nil {
+		return err
+	}
+	p, _, err := ceremony.InitPhase2(r1cs, srs1[0])
+	if err != nil {
+		return err
+	}
+	return ceremony.WritePhase2(out, p)
+}
+
+func phase2Contribute(in, out string) error {
+	chain, err := ceremony.ReadPhase2(in)
+	if err != nil {
+		return err
+	}
+	chain[0].Contribute()
+	if err := ceremony.WritePhase2(out, chain[0]); err != nil {
+		return err
+	}
+	fmt.Printf("%x\n", chain[0].Hash)
+	return nil
+}
+
+func phase2Verify(circuit, phase1 string, paths []string) error {
+	r1cs, err := ceremony.Compile(circuit)
+	if err != nil {
+		return err
+	}
+	srs1, err := ceremony.ReadPhase1(phase1)
+	if err != nil {
+		return err
+	}
+	chain, err := ceremony.ReadPhase2(paths...)
+	if err != nil {
+		return err
+	}
+	if err := ceremony.VerifyPhase2(r1cs, srs1[0], chain); err != nil {
+		return err
+	}
+	for i, p := range chain[1:] {
+		fmt.Printf("%d %x\n", i+1, p.Hash)
+	}
+	return nil
+}
+
+func extract(circuit string, phase1, phase2 []string, store keys.Store) error {
+	r1cs, err := ceremony.Compile(circuit)
+	if err != nil {
+		return err
+	}
+	chain1, err := ceremony.ReadPhase1(phase1...)
+	if err != nil {
+		return err
+	}
+	chain2, err := ceremony.ReadPhase2(phase2...)
+	if err != nil {
+		return err
+	}
+	k, err := ceremony.Extract(r1cs, chain1, chain2)
+	if err != nil {
+		return err
+	}
+	return store.Write(circuit, k)
+}
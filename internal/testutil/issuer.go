@@ -0,0 +1,24 @@
+// Package testutil holds the fixtures the tests and the benchmarks of several
+// packages share.
+package testutil
+
+import (
+	"Asyn_CBDC/backend/issuer"
+	"Asyn_CBDC/backend/offlinetx"
+	"io"
+)
+
+// Issuer returns an issuer with fresh keys drawn from rnd, crypto/rand when
+// rnd is nil, and one subkey able to sign the test accounts of
+// offlinetx.Offline.Execution and Pay.
+func Issuer(rnd io.Reader) (*issuer.Issuer, error) {
+	is, err := issuer.New(rnd)
+	if err != nil {
+		return nil, err
+	}
+	notBefore, notAfter := offlinetx.SimulationValidity()
+	if _, err := is.Rotate(notBefore, notAfter, rnd); err != nil {
+		return nil, err
+	}
+	return is, nil
+}